@@ -10,49 +10,80 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"os"
+	"path/filepath"
 	goruntime "runtime"
 	"strings"
 	"sync"
 	"text/template"
 	"time"
 
-	"github.com/sashabaranov/go-openai"
-	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"github.com/mitchellh/go-homedir"
 	"golang.org/x/exp/slices"
 
 	"cuttlefish/database"
+	"cuttlefish/llm"
+	"cuttlefish/llm/anthropic"
+	"cuttlefish/llm/ollama"
+	"cuttlefish/llm/openai"
 	"cuttlefish/tools"
+	"cuttlefish/tools/assistant"
 	"cuttlefish/tools/chart"
 	"cuttlefish/tools/dalle2"
+	"cuttlefish/tools/duckdb"
+	"cuttlefish/tools/files"
 	"cuttlefish/tools/geturl"
 	"cuttlefish/tools/python"
 	"cuttlefish/tools/search"
+	sqltool "cuttlefish/tools/sql"
 	"cuttlefish/tools/terminal"
 )
 
 // App struct
 type App struct {
-	ctx      context.Context
-	queries  *database.Queries
-	tools    map[string]tools.Tool
-	settings database.Settings
+	ctx       context.Context
+	queries   *database.Queries
+	events    EventEmitter
+	providers map[string]llm.Provider
+	tools     map[string]tools.Tool
+	settings  database.Settings
+	// Held while saving the settings, so that concurrent changes don't get lost.
+	saveSettingsM sync.Mutex
 
 	m                       sync.Mutex
 	generationContextCancel map[int]context.CancelFunc
 	pendingApprovalRequests map[int]approvalRequest
+	// Tool instances live as long as the conversation, so that i.e. the terminal's shell keeps its state between messages.
+	toolInstances map[int]map[string]tools.ToolInstance
+	// The parent conversations of running sub-conversations.
+	subConversationParents map[int]int
+	// The tools of the configured MCP servers, by ID, and a channel that's closed once they've been looked up.
+	mcpTools       map[string]tools.Tool
+	mcpToolsLoaded chan struct{}
 }
 
 type approvalRequest struct {
 	approvalID   string
-	approvalChan chan struct{}
-	message      string
+	approvalChan chan tools.Approval
+	// The conversation the tool runs in, which may be a sub-conversation of the one the request is shown in.
+	conversationID int
+	request        tools.ApprovalRequest
+	// The tool call asking for approval, which always allowing it makes an approval rule of.
+	toolCall *database.ToolCall
+	toolName string
 }
 
 // NewApp creates a new App application struct
-func NewApp(ctx context.Context, queries *database.Queries) *App {
+func NewApp(ctx context.Context, queries *database.Queries, events EventEmitter) *App {
 	out := &App{
 		ctx:     ctx,
 		queries: queries,
+		events:  events,
+		providers: map[string]llm.Provider{
+			"openai":    &openai.Provider{},
+			"anthropic": &anthropic.Provider{},
+			"ollama":    &ollama.Provider{},
+		},
 		tools: map[string]tools.Tool{
 			"terminal":       &terminal.Tool{},
 			"generate_image": &dalle2.Tool{},
@@ -60,16 +91,24 @@ func NewApp(ctx context.Context, queries *database.Queries) *App {
 			"get_url":        &geturl.Tool{},
 			"chart":          &chart.Tool{},
 			"python":         &python.Tool{},
+			"sql":            &sqltool.Tool{},
+			"duckdb":         &duckdb.Tool{},
+			"files":          &files.Tool{},
+			"assistant":      &assistant.Tool{},
 		},
 		generationContextCancel: map[int]context.CancelFunc{},
 		pendingApprovalRequests: map[int]approvalRequest{},
+		toolInstances:           map[int]map[string]tools.ToolInstance{},
+		subConversationParents:  map[int]int{},
 	}
 
 	settings, err := out.getSettingsRaw()
 	if err != nil {
-		log.Printf("couldn't load settings: %w", err)
+		log.Printf("couldn't load settings: %v", err)
 	}
 	out.settings = settings
+	out.loadPlugins()
+	out.refreshMCPTools(settings.MCP.Servers)
 
 	return out
 }
@@ -80,14 +119,39 @@ func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 }
 
-func (a *App) openAICli() *openai.Client {
-	a.m.Lock()
-	defer a.m.Unlock()
-	return openai.NewClient(a.settings.OpenAIAPIKey)
+// shutdown stops whatever the tools left running, like the terminal's shells.
+func (a *App) shutdown(ctx context.Context) {
+	a.shutdownToolInstances()
+}
+
+// llmClient returns a client for the provider, and the model, configured for the conversation.
+// Conversation settings take precedence over the app settings.
+func (a *App) llmClient(settings database.Settings, conversationSettings database.ConversationSetting) (llm.Client, string, error) {
+	providerName := settings.Provider
+	if conversationSettings.Provider != "" {
+		providerName = conversationSettings.Provider
+	}
+	if providerName == "" {
+		providerName = "openai"
+	}
+	model := settings.Model
+	if conversationSettings.Model != "" {
+		model = conversationSettings.Model
+	}
+
+	provider, ok := a.providers[providerName]
+	if !ok {
+		return nil, "", fmt.Errorf("provider `%s` not found", providerName)
+	}
+	client, err := provider.Instantiate(settings)
+	if err != nil {
+		return nil, "", fmt.Errorf("couldn't instantiate provider `%s`: %w", providerName, err)
+	}
+	return client, model, nil
 }
 
 func (a *App) Messages(conversationID int) ([]database.Message, error) {
-	return a.queries.ListMessages(a.ctx, conversationID)
+	return a.activeBranchMessages(a.ctx, conversationID)
 }
 
 func (a *App) GetConversation(conversationID int) (database.Conversation, error) {
@@ -102,40 +166,75 @@ func (a *App) DeleteConversation(conversationID int) error {
 	if err := a.queries.DeleteConversation(a.ctx, conversationID); err != nil {
 		return fmt.Errorf("coudn't delete conversation: %w", err)
 	}
-	runtime.EventsEmit(a.ctx, "conversations-updated")
+	a.shutdownToolInstances(conversationID)
+	a.events.Emit("conversations-updated")
 	return nil
 }
 
 func (a *App) SendMessage(conversationID int, content string) (database.Message, error) {
 	if conversationID == -1 {
-		title := content
-		if len(title) > 20 {
-			title = title[:13] + "..."
-		}
-		defaultConversationSettings, err := a.GetDefaultConversationSettings()
-		if err != nil {
-			return database.Message{}, fmt.Errorf("couldn't get default conversation settings: %w", err)
-		}
-		settings, err := a.queries.CreateConversationSettings(a.ctx, database.CreateConversationSettingsParams{
-			SystemPromptTemplate: defaultConversationSettings.SystemPromptTemplate,
-			ToolsEnabled:         defaultConversationSettings.ToolsEnabled,
-		})
+		var err error
+		conversationID, err = a.createConversationWithDefaultSettings(content)
 		if err != nil {
-			return database.Message{}, fmt.Errorf("couldn't create conversation settings: %w", err)
+			return database.Message{}, err
 		}
-		conversation, err := a.queries.CreateConversation(a.ctx, database.CreateConversationParams{
-			ConversationSettingsID: settings.ID,
-			Title:                  title,
-			LastMessageTime:        time.Now(),
-		})
-		if err != nil {
-			return database.Message{}, fmt.Errorf("couldn't create conversation: %w", err)
-		}
-		conversationID = conversation.ID
-		runtime.EventsEmit(a.ctx, "conversations-updated")
 	}
 
-	msg, err := a.queries.CreateMessage(a.ctx, database.CreateMessageParams{
+	return a.sendMessage(conversationID, content)
+}
+
+func (a *App) createConversationWithDefaultSettings(firstMessage string) (int, error) {
+	defaultConversationSettings, err := a.GetDefaultConversationSettings()
+	if err != nil {
+		return 0, fmt.Errorf("couldn't get default conversation settings: %w", err)
+	}
+	// The default settings might not be saved yet, so they're copied by value.
+	settings, err := a.copyConversationSettings(a.ctx, defaultConversationSettings)
+	if err != nil {
+		return 0, fmt.Errorf("couldn't create conversation settings: %w", err)
+	}
+	return a.createConversation(firstMessage, settings.ID)
+}
+
+func (a *App) createConversation(firstMessage string, conversationSettingsID int) (int, error) {
+	conversation, err := a.queries.CreateConversation(a.ctx, database.CreateConversationParams{
+		ConversationSettingsID: conversationSettingsID,
+		Title:                  conversationTitle(firstMessage),
+		LastMessageTime:        time.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't create conversation: %w", err)
+	}
+	a.events.Emit("conversations-updated")
+	return conversation.ID, nil
+}
+
+func conversationTitle(firstMessage string) string {
+	title := firstMessage
+	if len(title) > 20 {
+		title = title[:13] + "..."
+	}
+	return title
+}
+
+func (a *App) copyConversationSettings(ctx context.Context, settings database.ConversationSetting) (database.ConversationSetting, error) {
+	return a.queries.CreateConversationSettings(ctx, database.CreateConversationSettingsParams{
+		SystemPromptTemplate:       settings.SystemPromptTemplate,
+		ToolsEnabled:               settings.ToolsEnabled,
+		Provider:                   settings.Provider,
+		Model:                      settings.Model,
+		MaxConsecutiveToolFailures: settings.MaxConsecutiveToolFailures,
+		ContextWindow:              settings.ContextWindow,
+		Temperature:                settings.Temperature,
+		MaxTokens:                  settings.MaxTokens,
+		TopP:                       settings.TopP,
+		Stop:                       settings.Stop,
+		Sandbox:                    settings.Sandbox,
+	})
+}
+
+func (a *App) sendMessage(conversationID int, content string) (database.Message, error) {
+	msg, err := a.addMessage(a.ctx, database.CreateMessageParams{
 		ConversationID: conversationID,
 		Content:        content,
 		Author:         "user",
@@ -143,25 +242,31 @@ func (a *App) SendMessage(conversationID int, content string) (database.Message,
 	if err != nil {
 		return database.Message{}, fmt.Errorf("couldn't create message: %w", err)
 	}
-	runtime.EventsEmit(a.ctx, fmt.Sprintf("conversation-%d-updated", conversationID))
+	a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
 
 	go func() {
 		if err := a.runChainOfMessages(conversationID); err != nil && !errors.Is(err, context.Canceled) {
-			runtime.EventsEmit(a.ctx, "async-error", err.Error())
+			a.events.Emit("async-error", err.Error())
 		}
 	}()
 
 	return msg, nil
 }
 
-func (a *App) runChainOfMessages(conversationID int) (err error) {
+func (a *App) runChainOfMessages(conversationID int) error {
+	return a.runChainOfMessagesWithLimit(a.ctx, conversationID, 0)
+}
+
+// runChainOfMessagesWithLimit generates responses until the Assistant gives a final answer, or gives up after maxSteps responses, if it's set.
+// The generation gets cancelled along with ctx, which is how sub-conversations stop along with their parent.
+func (a *App) runChainOfMessagesWithLimit(ctx context.Context, conversationID int, maxSteps int) (err error) {
 	defer func() {
 		if msg := recover(); msg != nil {
 			err = fmt.Errorf("panic caught: %v", msg)
 		}
 	}()
 
-	genCtx, cancelGeneration := context.WithCancel(a.ctx)
+	genCtx, cancelGeneration := context.WithCancel(ctx)
 	defer cancelGeneration()
 
 	a.m.Lock()
@@ -171,7 +276,7 @@ func (a *App) runChainOfMessages(conversationID int) (err error) {
 	if err := a.queries.MarkGenerationStarted(genCtx, conversationID); err != nil {
 		return err
 	}
-	runtime.EventsEmit(genCtx, fmt.Sprintf("conversation-%d-updated", conversationID))
+	a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
 
 	curConversation, err := a.queries.GetConversation(genCtx, conversationID)
 	if err != nil {
@@ -184,48 +289,66 @@ func (a *App) runChainOfMessages(conversationID int) (err error) {
 
 	defer func() {
 		if err := a.queries.MarkGenerationDone(a.ctx, conversationID); err != nil {
-			runtime.EventsEmit(a.ctx, "async-error", fmt.Errorf("couldn't mark conversation as done generating: %w", err).Error())
+			a.events.Emit("async-error", fmt.Errorf("couldn't mark conversation as done generating: %w", err).Error())
 		}
-		runtime.EventsEmit(a.ctx, fmt.Sprintf("conversation-%d-updated", conversationID))
+		a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
 	}()
 
-	cachedToolInstances := map[string]tools.ToolInstance{}
-	defer func() {
-		for name, instance := range cachedToolInstances {
-			if err := instance.Shutdown(); err != nil {
-				runtime.EventsEmit(a.ctx, "async-error", fmt.Errorf("couldn't shut down tool `%s`: %w", name, err).Error())
-			}
-		}
-	}()
+	// The conversation may use tools of MCP servers, which are only known once they've started.
+	if err := a.waitForMCPTools(genCtx); err != nil {
+		return err
+	}
 
 	settings, err := a.getSettingsRaw()
 	if err != nil {
 		return fmt.Errorf("couldn't get settings: %w", err)
 	}
+	llmClient, model, err := a.llmClient(settings, curConversationSettings)
+	if err != nil {
+		return err
+	}
 
-	stop := []string{"Observation", "Response"}
 	retries := 0
+	// Once a response comes back empty, the stop sequences are left out, in case they're what cut it off.
+	retryWithoutStop := false
+	consecutiveToolFailures := 0
+	steps := 0
 	for {
-		allMessages, err := a.queries.ListMessages(genCtx, conversationID)
-		if err != nil {
-			return fmt.Errorf("couldn't list conversation messages: %w", err)
+		if maxSteps > 0 && steps >= maxSteps {
+			return fmt.Errorf("stopping after %d steps without a final answer", steps)
 		}
-		gptMessages, err := a.messagesToGPTMessages(curConversationSettings, allMessages)
+		steps++
+		functionCalling := llmClient.SupportsFunctionCalling(model)
+		var toolDefinitions []llm.ToolDefinition
+		stop := curConversationSettings.Stop
+		if functionCalling {
+			toolDefinitions = a.toolDefinitions(curConversationSettings)
+		} else {
+			// The text-based tool protocol relies on these, so the model doesn't make up tool responses.
+			stop = append([]string{"Observation", "Response"}, stop...)
+		}
+		if retryWithoutStop {
+			stop = []string{}
+		}
+		maxTokens := curConversationSettings.MaxTokens
+		llmMessages, err := a.fitMessagesIntoContext(genCtx, conversationID, llmClient, model, curConversationSettings, functionCalling, toolDefinitions, maxTokens)
 		if err != nil {
-			return fmt.Errorf("couldn't convert messages to GPT messages: %w", err)
-		}
-		stream, err := a.openAICli().CreateChatCompletionStream(genCtx, openai.ChatCompletionRequest{
-			Model:       settings.Model,
-			MaxTokens:   500,
-			Temperature: 0.7,
-			TopP:        1,
-			Messages:    gptMessages,
+			return err
+		}
+		req := llm.ChatCompletionRequest{
+			Model:       model,
+			MaxTokens:   maxTokens,
+			Temperature: float32(curConversationSettings.Temperature),
+			TopP:        float32(curConversationSettings.TopP),
+			Messages:    llmMessages,
 			Stop:        stop,
-		})
+			Tools:       toolDefinitions,
+		}
+		stream, err := llmClient.CreateChatCompletionStream(genCtx, req)
 		if err != nil {
 			return fmt.Errorf("couldn't create chat completion stream: %w", err)
 		}
-		gptMessage, err := a.queries.CreateMessage(genCtx, database.CreateMessageParams{
+		gptMessage, err := a.addMessage(genCtx, database.CreateMessageParams{
 			ConversationID: conversationID,
 			Content:        "",
 			Author:         "assistant",
@@ -233,185 +356,563 @@ func (a *App) runChainOfMessages(conversationID int) (err error) {
 		if err != nil {
 			return fmt.Errorf("couldn't create response message: %w", err)
 		}
-		for {
-			res, err := stream.Recv()
-			if err == io.EOF {
-				break
-			} else if err != nil {
-				return fmt.Errorf("couldn't receive from chat completion stream: %w", err)
-			}
+		var streamedToolCalls []llm.ToolCall
+		// Arguments which couldn't be decoded are reported back to the model, indexed like the tool calls.
+		var argumentErrs []error
+		if err := func() error {
+			defer stream.Close()
+			for {
+				chunk, err := stream.Recv()
+				if err == io.EOF {
+					return nil
+				} else if err != nil {
+					return fmt.Errorf("couldn't receive from chat completion stream: %w", err)
+				}
 
-			if len(res.Choices) > 0 {
+				streamedToolCalls = append(streamedToolCalls, chunk.ToolCalls...)
+				if chunk.Content == "" {
+					continue
+				}
 				if _, err := a.queries.AppendMessage(genCtx, database.AppendMessageParams{
 					ID:      gptMessage.ID,
-					Content: res.Choices[0].Delta.Content,
+					Content: chunk.Content,
 				}); err != nil {
 					return fmt.Errorf("couldn't append to message: %w", err)
 				}
-				runtime.EventsEmit(genCtx, fmt.Sprintf("conversation-%d-updated", conversationID))
+				a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
 			}
+		}(); err != nil {
+			return err
+		}
+		if len(streamedToolCalls) > 0 {
+			toolCalls := make(database.ToolCallArray, len(streamedToolCalls))
+			argumentErrs = make([]error, len(streamedToolCalls))
+			for i, toolCall := range streamedToolCalls {
+				args := map[string]interface{}{}
+				if strings.TrimSpace(toolCall.Arguments) != "" {
+					if err := json.Unmarshal([]byte(toolCall.Arguments), &args); err != nil {
+						argumentErrs[i] = fmt.Errorf("couldn't decode arguments: %w", err)
+					}
+				}
+				toolCalls[i] = database.ToolCall{
+					ID:   toolCall.ID,
+					Tool: toolCall.Name,
+					Args: args,
+				}
+			}
+			if _, err := a.queries.SetMessageToolCalls(genCtx, database.SetMessageToolCallsParams{
+				ToolCalls: toolCalls,
+				ID:        gptMessage.ID,
+			}); err != nil {
+				return fmt.Errorf("couldn't save tool calls: %w", err)
+			}
+			a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
 		}
 		gptMessage, err = a.queries.GetMessage(genCtx, gptMessage.ID)
 		if err != nil {
 			return fmt.Errorf("couldn't get response message: %w", err)
 		}
-		if strings.TrimSpace(gptMessage.Content) == "" {
-			stop = []string{}
+		if strings.TrimSpace(gptMessage.Content) == "" && len(gptMessage.ToolCalls) == 0 {
+			retryWithoutStop = true
 			if retries > 2 {
 				return fmt.Errorf("couldn't generate a response after %d retries", retries)
 			}
 			retries++
 			continue
 		}
-		if strings.Contains(gptMessage.Content, "```action") || strings.Contains(gptMessage.Content, "Action:") {
-			// TODO: Make it so that each tool use can be approved by the user.
 
-			// A tool has been called upon!
-			// We match on either, cause ChatGPT doesn't always use the same format.
-			content := gptMessage.Content
-			if strings.Contains(gptMessage.Content, "```action") {
-				content = content[strings.Index(content, "```action")+len("```action"):]
-				content = content[:strings.Index(content, "```")]
-				content = strings.TrimSpace(content)
-			} else {
-				content = content[strings.Index(content, "Action:"):]
-				content = content[strings.Index(content, "```"):]
-				content = content[strings.Index(content, "\n"):]
-				content = content[:strings.Index(content, "```")]
-				content = strings.TrimSpace(content)
+		var actions []database.ToolCall
+		var actionErrs []error
+		if len(gptMessage.ToolCalls) > 0 {
+			actions = gptMessage.ToolCalls
+			actionErrs = argumentErrs
+			if len(actionErrs) != len(actions) {
+				actionErrs = make([]error, len(actions))
 			}
-
+		} else if content, ok := extractTextAction(gptMessage.Content); ok {
+			// Models without function calling describe the action in the message itself.
 			var action Action
+			var actionErr error
 			if err := json.Unmarshal([]byte(content), &action); err != nil {
-				// TODO: respond as observation
-				return fmt.Errorf("couldn't decode action: %w", err)
+				actionErr = fmt.Errorf("couldn't decode action, make sure it's valid JSON: %w", err)
 			}
+			actions = append(actions, database.ToolCall{
+				Tool: action.Tool,
+				Args: action.Args,
+			})
+			actionErrs = append(actionErrs, actionErr)
+		} else {
+			break
+		}
 
-			toolInstance, ok := cachedToolInstances[action.Tool]
-			if !ok {
-				tool, ok := a.tools[action.Tool]
-				if !ok {
-					// TODO: respond as observation
-					return fmt.Errorf("tool `%s` not found", action.Tool)
-				}
-				toolInstance, err = tool.Instantiate(genCtx, a.settings, &AppRuntime{conversationID: conversationID, app: a})
-				if err != nil {
-					// TODO: respond as observation
-					return fmt.Errorf("couldn't instantiate tool `%s`: %w", action.Tool, err)
-				}
+		for i, action := range actions {
+			author := "system"
+			if tool, ok := a.availableTools()[action.Tool]; ok {
+				author = tool.Name()
 			}
-
-			result, err := toolInstance.Run(genCtx, action.Args)
-			if err != nil {
-				// TODO: respond as observation
-				return fmt.Errorf("couldn't run tool `%s`: %w", action.Tool, err)
+			output := a.newToolOutput(genCtx, conversationID, author, action.ID)
+			var result *tools.RunResult
+			err := actionErrs[i]
+			if err == nil {
+				result, err = a.runTool(context.WithValue(genCtx, toolOutputKey{}, output), conversationID, gptMessage.ID, action)
 			}
-			observationString := "Observation: "
-			observationString += result.Result
-			observationString += "\n"
-			observationString += "```"
-			if result.CustomResultTag != "" {
-				observationString += result.CustomResultTag
+			if genCtx.Err() != nil {
+				output.cancel()
+				a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
+				return genCtx.Err()
 			}
-			observationString += "\n"
-			observationString += result.Output + "\n```"
 
-			if _, err := a.queries.CreateMessage(genCtx, database.CreateMessageParams{
-				ConversationID: conversationID,
-				Content:        observationString,
-				Author:         a.tools[action.Tool].Name(),
-			}); err != nil {
+			var observation string
+			var rejected *tools.RejectedError
+			if errors.As(err, &rejected) {
+				// The user rejecting a tool call doesn't mean the model's struggling to use the tool.
+				observation = formatRejectedObservation(rejected)
+			} else if err != nil {
+				consecutiveToolFailures++
+				observation = formatErrorObservation(err)
+			} else {
+				consecutiveToolFailures = 0
+				observation = formatObservation(result)
+			}
+
+			if err := output.finish(genCtx, observation); err != nil {
 				return fmt.Errorf("couldn't create observation message: %w", err)
 			}
-		} else {
-			break
+			a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
+
+			if maxFailures := curConversationSettings.MaxConsecutiveToolFailures; maxFailures > 0 && consecutiveToolFailures >= maxFailures {
+				return fmt.Errorf("stopping after %d consecutive tool failures, last one: %w", consecutiveToolFailures, err)
+			}
 		}
 	}
 	return nil
 }
 
+type toolCallMessageKey struct{}
+
+// The running tool call's *toolCallState, so that it can be always allowed or edited from its approval request.
+type toolCallKey struct{}
+
+type toolCallState struct {
+	// The tool call with its validated arguments, which are replaced if the user edits them.
+	toolCall database.ToolCall
+	// Tools don't ask for approval again once the rules or the user already decided.
+	approved bool
+	edited   bool
+	// How it got approved, and by whom, for the log of tool executions.
+	approval   string
+	approvedBy string
+}
+
+// runTool runs the action, instantiating its tool if this conversation hasn't used it yet.
+// The tool gets the ID of the message with the tool call in its context, so sub-conversations can be linked to it.
+func (a *App) runTool(ctx context.Context, conversationID int, messageID int, action database.ToolCall) (result *tools.RunResult, err error) {
+	ctx = context.WithValue(ctx, toolCallMessageKey{}, messageID)
+	tool, ok := a.availableTools()[action.Tool]
+	if !ok {
+		return nil, fmt.Errorf("tool `%s` not found", action.Tool)
+	}
+	// Tools can rely on their arguments having the right types, with the defaults of missing ones filled in.
+	args, err := tools.ValidateArguments(tool.ArgumentSchema(), action.Args)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't run tool `%s`: %w", action.Tool, err)
+	}
+	state := &toolCallState{
+		toolCall: database.ToolCall{
+			ID:   action.ID,
+			Tool: action.Tool,
+			Args: args,
+		},
+		approval: executionApprovalNotRequired,
+	}
+	ctx = context.WithValue(ctx, toolCallKey{}, state)
+
+	executionID, err := a.startToolExecution(ctx, conversationID, messageID, state.toolCall)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		a.finishToolExecution(ctx, executionID, state, result, err)
+	}()
+
+	a.m.Lock()
+	rules := a.settings.Approvals.Rules
+	a.m.Unlock()
+	decision, err := decideApproval(rules, action.Tool, args)
+	if err != nil {
+		return nil, err
+	}
+	switch decision.decision {
+	case approvalDeny:
+		state.approval, state.approvedBy = executionApprovalDeniedByRule, "approval rule "+formatApprovalRule(decision.rule)
+		return nil, fmt.Errorf("couldn't run tool `%s`: denied by approval rule %s", action.Tool, formatApprovalRule(decision.rule))
+	case approvalPrompt:
+		argsJSON, err := json.MarshalIndent(args, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("couldn't encode arguments: %w", err)
+		}
+		runtime := &AppRuntime{conversationID: conversationID, app: a}
+		approval, err := runtime.WaitForApproval(ctx, tools.ApprovalRequest{
+			Message:     fmt.Sprintf("run `%s`, as approval rule %s asks for", action.Tool, formatApprovalRule(decision.rule)),
+			Preview:     string(argsJSON),
+			PreviewType: tools.PreviewTypeJSON,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't run tool `%s`: user did not approve: %w", action.Tool, err)
+		}
+		if err := approval.Rejected(); err != nil {
+			return nil, fmt.Errorf("couldn't run tool `%s`: %w", action.Tool, err)
+		}
+		state.approved = true
+	case approvalAllow:
+		state.approved = true
+		state.approval, state.approvedBy = executionApprovalAllowedByRule, "approval rule "+formatApprovalRule(decision.rule)
+	}
+
+	toolInstance, err := a.toolInstance(conversationID, action.Tool)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = toolInstance.Run(ctx, state.toolCall.Args)
+	if err != nil {
+		if state.edited {
+			return nil, fmt.Errorf("couldn't run tool `%s` with %s: %w", action.Tool, editedArgumentsNote(state.toolCall.Args), err)
+		}
+		return nil, fmt.Errorf("couldn't run tool `%s`: %w", action.Tool, err)
+	}
+	if state.edited {
+		// Otherwise the model would assume the tool ran with the arguments it asked for.
+		result.Result = fmt.Sprintf("ran with %s, %s", editedArgumentsNote(state.toolCall.Args), result.Result)
+	}
+	return result, nil
+}
+
+func editedArgumentsNote(args map[string]interface{}) string {
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return "arguments edited by the user"
+	}
+	return fmt.Sprintf("arguments edited by the user to %s", argsJSON)
+}
+
+func (a *App) toolInstance(conversationID int, name string) (tools.ToolInstance, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+	if toolInstance, ok := a.toolInstances[conversationID][name]; ok {
+		return toolInstance, nil
+	}
+
+	tool, ok := a.tools[name]
+	if !ok {
+		tool, ok = a.mcpTools[name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("tool `%s` not found", name)
+	}
+	// The instance outlives the generation, so it doesn't get the generation's context.
+	toolInstance, err := tool.Instantiate(a.ctx, a.settings, &AppRuntime{conversationID: conversationID, app: a})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't instantiate tool `%s`: %w", name, err)
+	}
+	if a.toolInstances[conversationID] == nil {
+		a.toolInstances[conversationID] = map[string]tools.ToolInstance{}
+	}
+	a.toolInstances[conversationID][name] = toolInstance
+	return toolInstance, nil
+}
+
+// shutdownToolInstances shuts down the tool instances of the given conversations, or of all of them if none are given.
+func (a *App) shutdownToolInstances(conversationIDs ...int) {
+	a.m.Lock()
+	var toShutdown []map[string]tools.ToolInstance
+	if len(conversationIDs) == 0 {
+		for conversationID := range a.toolInstances {
+			conversationIDs = append(conversationIDs, conversationID)
+		}
+	}
+	for _, conversationID := range conversationIDs {
+		if instances, ok := a.toolInstances[conversationID]; ok {
+			toShutdown = append(toShutdown, instances)
+			delete(a.toolInstances, conversationID)
+		}
+	}
+	a.m.Unlock()
+
+	for _, instances := range toShutdown {
+		for name, instance := range instances {
+			if err := instance.Shutdown(); err != nil {
+				a.events.Emit("async-error", fmt.Errorf("couldn't shut down tool `%s`: %w", name, err).Error())
+			}
+		}
+	}
+}
+
+// workspace returns the conversation's directory for the files tool.
+// Workspaces are kept when the conversation is deleted, so nothing the user still needs gets lost.
+func (a *App) workspace(conversationID int) (string, error) {
+	a.m.Lock()
+	dir := a.settings.Files.WorkspacesDirectory
+	a.m.Unlock()
+	if dir == "" {
+		home, err := homedir.Dir()
+		if err != nil {
+			return "", fmt.Errorf("couldn't get user home directory: %w", err)
+		}
+		dir = filepath.Join(home, ".cuttlefish", "workspaces")
+	}
+	dir, err := homedir.Expand(dir)
+	if err != nil {
+		return "", fmt.Errorf("couldn't expand workspaces directory: %w", err)
+	}
+	dir = filepath.Join(dir, fmt.Sprintf("conversation-%d", conversationID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("couldn't create workspace: %w", err)
+	}
+	return dir, nil
+}
+
 type Action struct {
 	Tool string                 `json:"tool"`
 	Args map[string]interface{} `json:"args"`
 }
 
-func (a *App) messagesToGPTMessages(conversationSettings database.ConversationSetting, messages []database.Message) ([]openai.ChatCompletionMessage, error) {
-	generatedSystemPrompt, err := a.generateSystemPrompt(conversationSettings)
+// extractTextAction finds the action JSON in a message written using the text-based tool protocol.
+func extractTextAction(content string) (string, bool) {
+	// We match on either, cause ChatGPT doesn't always use the same format.
+	if strings.Contains(content, "```action") {
+		content = content[strings.Index(content, "```action")+len("```action"):]
+		if end := strings.Index(content, "```"); end != -1 {
+			content = content[:end]
+		}
+		return strings.TrimSpace(content), true
+	} else if strings.Contains(content, "Action:") {
+		content = content[strings.Index(content, "Action:"):]
+		if start := strings.Index(content, "```"); start != -1 {
+			content = content[start:]
+		}
+		if start := strings.Index(content, "\n"); start != -1 {
+			content = content[start:]
+		}
+		if end := strings.Index(content, "```"); end != -1 {
+			content = content[:end]
+		}
+		return strings.TrimSpace(content), true
+	}
+	return "", false
+}
+
+func formatErrorObservation(err error) string {
+	return "Observation: error: " + err.Error() + "\nPlease correct your approach and try again."
+}
+
+func formatRejectedObservation(err *tools.RejectedError) string {
+	if err.Reason == "" {
+		return "Observation: the user rejected this tool call.\nPlease try a different approach."
+	}
+	return "Observation: the user rejected this tool call, saying: " + err.Reason + "\nPlease try a different approach."
+}
+
+func formatObservation(result *tools.RunResult) string {
+	observationString := "Observation: "
+	observationString += result.Result
+	observationString += "\n"
+	observationString += "```"
+	if result.CustomResultTag != "" {
+		observationString += result.CustomResultTag
+	}
+	observationString += "\n"
+	observationString += result.Output + "\n```"
+	return observationString
+}
+
+func (a *App) toolDefinitions(conversationSettings database.ConversationSetting) []llm.ToolDefinition {
+	var out []llm.ToolDefinition
+	for toolName, tool := range a.availableTools() {
+		if !slices.Contains(conversationSettings.ToolsEnabled, toolName) {
+			continue
+		}
+		out = append(out, llm.ToolDefinition{
+			Name:        toolName,
+			Description: tool.Description(),
+			Parameters:  tool.ArgumentSchema().Map(),
+		})
+	}
+	slices.SortFunc(out, func(a, b llm.ToolDefinition) bool {
+		return a.Name < b.Name
+	})
+	return out
+}
+
+func (a *App) messagesToLLMMessages(conversationSettings database.ConversationSetting, messages []database.Message, functionCalling bool) ([]llm.Message, error) {
+	generatedSystemPrompt, err := a.generateSystemPrompt(conversationSettings, functionCalling)
 	if err != nil {
 		return nil, fmt.Errorf("couldn't generate system prompt: %w", err)
 	}
 
-	var gptMessages []openai.ChatCompletionMessage
-	gptMessages = append(gptMessages, openai.ChatCompletionMessage{
-		Role:    openai.ChatMessageRoleSystem,
+	var llmMessages []llm.Message
+	llmMessages = append(llmMessages, llm.Message{
+		Role:    llm.RoleSystem,
 		Content: generatedSystemPrompt,
 	})
 	for _, message := range messages {
-		if strings.TrimSpace(message.Content) == "" {
+		if message.ContextStatus != contextStatusSummarized && message.Author == summaryAuthor {
+			// Summaries replace the messages they summarize, so they go right after the system prompt.
+			llmMessages = append(llmMessages, llm.Message{
+				Role:    llm.RoleSystem,
+				Content: "Summary of the earlier conversation:\n" + message.Content,
+			})
+		}
+	}
+	for _, message := range messages {
+		if message.ContextStatus == contextStatusSummarized || message.Author == summaryAuthor {
+			continue
+		}
+		if strings.TrimSpace(message.Content) == "" && len(message.ToolCalls) == 0 {
 			continue
 		}
-		gptMessage := openai.ChatCompletionMessage{
+		llmMessage := llm.Message{
 			Content: message.Content,
 		}
+		if message.ContextStatus == contextStatusTrimmed {
+			llmMessage.Content = trimToolOutput(message.Content)
+		}
 		if message.Author == "assistant" {
-			gptMessage.Role = openai.ChatMessageRoleAssistant
+			llmMessage.Role = llm.RoleAssistant
+			for _, toolCall := range message.ToolCalls {
+				if !functionCalling {
+					// The model can't receive native tool calls, so we describe them using the text-based protocol.
+					data, err := json.MarshalIndent(Action{Tool: toolCall.Tool, Args: toolCall.Args}, "", "  ")
+					if err != nil {
+						return nil, fmt.Errorf("couldn't encode tool call: %w", err)
+					}
+					llmMessage.Content += "\nAction:\n```action\n" + string(data) + "\n```"
+					continue
+				}
+				args, err := json.Marshal(toolCall.Args)
+				if err != nil {
+					return nil, fmt.Errorf("couldn't encode tool call arguments: %w", err)
+				}
+				llmMessage.ToolCalls = append(llmMessage.ToolCalls, llm.ToolCall{
+					ID:        toolCall.ID,
+					Name:      toolCall.Tool,
+					Arguments: string(args),
+				})
+			}
 		} else if message.Author == "user" {
-			gptMessage.Role = openai.ChatMessageRoleUser
+			llmMessage.Role = llm.RoleUser
+		} else if functionCalling && message.ToolCallID != "" {
+			llmMessage.Role = llm.RoleTool
+			llmMessage.ToolCallID = message.ToolCallID
 		} else {
-			gptMessage.Role = openai.ChatMessageRoleUser
-			gptMessage.Content = fmt.Sprintf("`%s` response:", message.Author) + gptMessage.Content
+			llmMessage.Role = llm.RoleUser
+			llmMessage.Content = fmt.Sprintf("`%s` response:", message.Author) + llmMessage.Content
 			// gptMessage.Content += "\nMake sure not to start your next response with `Observation:`, nor by thanking for this reminder."
 		}
-		gptMessages = append(gptMessages, gptMessage)
+		llmMessages = append(llmMessages, llmMessage)
 	}
-	return gptMessages, nil
+	return llmMessages, nil
 }
 
 func (a *App) RerunFromMessage(conversationID int, messageID int) error {
+	msg, err := a.queries.GetMessage(a.ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("couldn't get message: %w", err)
+	}
+	if msg.ConversationID != conversationID {
+		return fmt.Errorf("message %d doesn't belong to conversation %d", messageID, conversationID)
+	}
+
 	// A generation could still be happening.
 	a.CancelGeneration(conversationID)
 
-	if err := a.queries.ResetConversationFrom(a.ctx, database.ResetConversationFromParams{
-		ConversationID: conversationID,
-		ID:             messageID,
+	// The new response becomes a sibling of the previous one, which stays available as a separate branch.
+	if err := a.queries.SetActiveMessage(a.ctx, database.SetActiveMessageParams{
+		ActiveMessageID: sql.NullInt64{Int64: int64(messageID), Valid: true},
+		ID:              conversationID,
 	}); err != nil {
-		return fmt.Errorf("couldn't reset conversation: %w", err)
+		return fmt.Errorf("couldn't set active message: %w", err)
 	}
+	a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
 
 	go func() {
 		if err := a.runChainOfMessages(conversationID); err != nil && !errors.Is(err, context.Canceled) {
-			runtime.EventsEmit(a.ctx, "async-error", err.Error())
+			a.events.Emit("async-error", err.Error())
 		}
 	}()
 
 	return nil
 }
 
+// EditMessage creates an edited copy of a user message as its sibling, and continues the conversation from there.
+// The original message and its responses stay available as a separate branch.
+func (a *App) EditMessage(conversationID int, messageID int, content string) (database.Message, error) {
+	// A generation could still be happening.
+	a.CancelGeneration(conversationID)
+
+	original, err := a.queries.GetMessage(a.ctx, messageID)
+	if err != nil {
+		return database.Message{}, fmt.Errorf("couldn't get message: %w", err)
+	}
+	if original.ConversationID != conversationID {
+		return database.Message{}, fmt.Errorf("message %d doesn't belong to conversation %d", messageID, conversationID)
+	}
+	if original.Author != "user" {
+		return database.Message{}, fmt.Errorf("only user messages can be edited")
+	}
+
+	msg, err := a.queries.CreateMessage(a.ctx, database.CreateMessageParams{
+		ConversationID:  conversationID,
+		Content:         content,
+		Author:          "user",
+		ParentMessageID: original.ParentMessageID,
+	})
+	if err != nil {
+		return database.Message{}, fmt.Errorf("couldn't create message: %w", err)
+	}
+	if err := a.queries.SetActiveMessage(a.ctx, database.SetActiveMessageParams{
+		ActiveMessageID: sql.NullInt64{Int64: int64(msg.ID), Valid: true},
+		ID:              conversationID,
+	}); err != nil {
+		return database.Message{}, fmt.Errorf("couldn't set active message: %w", err)
+	}
+	a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
+
+	go func() {
+		if err := a.runChainOfMessages(conversationID); err != nil && !errors.Is(err, context.Canceled) {
+			a.events.Emit("async-error", err.Error())
+		}
+	}()
+
+	return msg, nil
+}
+
 //go:embed default_system_prompt.gotmpl
 var defaultSystemPromptTemplate string
 
-func (a *App) generateSystemPrompt(conversationSettings database.ConversationSetting) (string, error) {
+func (a *App) generateSystemPrompt(conversationSettings database.ConversationSetting, functionCalling bool) (string, error) {
 	var params struct {
 		ToolsDescription string
 		AnyToolsEnabled  bool
+		FunctionCalling  bool
 		OperatingSystem  string
 	}
 
 	type toolDescription struct {
-		Tool        string            `json:"tool"`
-		Description string            `json:"description"`
-		Args        map[string]string `json:"args"`
+		Tool        string        `json:"tool"`
+		Description string        `json:"description"`
+		Args        *tools.Schema `json:"args"`
 	}
 
 	toolsDescription := []toolDescription{}
-	for toolName, tool := range a.tools {
+	for toolName, tool := range a.availableTools() {
 		if !slices.Contains(conversationSettings.ToolsEnabled, toolName) {
 			continue
 		}
 		toolsDescription = append(toolsDescription, toolDescription{
 			Tool:        toolName,
 			Description: tool.Description(),
-			Args:        tool.ArgumentDescriptions(),
+			Args:        tool.ArgumentSchema(),
 		})
 	}
 	data, err := json.MarshalIndent(toolsDescription, "", "  ")
@@ -420,6 +921,7 @@ func (a *App) generateSystemPrompt(conversationSettings database.ConversationSet
 	}
 	params.ToolsDescription = string(data)
 	params.AnyToolsEnabled = len(toolsDescription) > 0
+	params.FunctionCalling = functionCalling
 
 	params.OperatingSystem = "MacOS"
 	if goruntime.GOOS == "windows" {
@@ -456,16 +958,43 @@ func (a *App) GetConversationSettings(conversationSettingsID int) (database.Conv
 }
 
 func (a *App) UpdateConversationSettings(params database.UpdateConversationSettingsParams) (database.ConversationSetting, error) {
+	if err := a.validateStopSequences(params.Provider, params.Stop); err != nil {
+		return database.ConversationSetting{}, err
+	}
 	return a.queries.UpdateConversationSettings(a.ctx, params)
 }
 
+// OpenAI allows up to 4 stop sequences, 2 of which the text-based tool protocol needs.
+const maxOpenAIStopSequences = 2
+
+// validateStopSequences checks that there aren't more stop sequences than the provider accepts,
+// which would otherwise make every request of the conversation fail.
+func (a *App) validateStopSequences(provider string, stop []string) error {
+	if provider == "" {
+		settings, err := a.getSettingsRaw()
+		if err != nil {
+			return fmt.Errorf("couldn't get settings: %w", err)
+		}
+		provider = settings.Provider
+	}
+	if (provider == "" || provider == "openai") && len(stop) > maxOpenAIStopSequences {
+		return fmt.Errorf("OpenAI allows at most %d additional stop sequences, got %d", maxOpenAIStopSequences, len(stop))
+	}
+	return nil
+}
+
 func (a *App) GetDefaultConversationSettings() (database.ConversationSetting, error) {
 	conversationSettings, err := a.queries.GetDefaultConversationSettings(a.ctx)
 	if errors.Is(err, sql.ErrNoRows) {
 		return database.ConversationSetting{
-			ID:                   -1,
-			SystemPromptTemplate: defaultSystemPromptTemplate,
-			ToolsEnabled:         []string{"terminal", "get_url", "chart"},
+			ID:                         -1,
+			SystemPromptTemplate:       defaultSystemPromptTemplate,
+			ToolsEnabled:               []string{"terminal", "get_url", "chart", "python"},
+			MaxConsecutiveToolFailures: 3,
+			Temperature:                0.7,
+			MaxTokens:                  1024,
+			TopP:                       1,
+			Stop:                       []string{},
 		}, nil
 	} else if err != nil {
 		return database.ConversationSetting{}, fmt.Errorf("couldn't get default conversation settings: %w", err)
@@ -474,6 +1003,9 @@ func (a *App) GetDefaultConversationSettings() (database.ConversationSetting, er
 }
 
 func (a *App) SetDefaultConversationSettings(params database.CreateDefaultConversationSettingsParams) (database.ConversationSetting, error) {
+	if err := a.validateStopSequences(params.Provider, params.Stop); err != nil {
+		return database.ConversationSetting{}, err
+	}
 	defaultConversationSettings, err := a.queries.GetDefaultConversationSettings(a.ctx)
 	if errors.Is(err, sql.ErrNoRows) {
 		return a.queries.CreateDefaultConversationSettings(a.ctx, params)
@@ -482,9 +1014,18 @@ func (a *App) SetDefaultConversationSettings(params database.CreateDefaultConver
 	}
 
 	return a.queries.UpdateConversationSettings(a.ctx, database.UpdateConversationSettingsParams{
-		ID:                   defaultConversationSettings.ID,
-		SystemPromptTemplate: params.SystemPromptTemplate,
-		ToolsEnabled:         params.ToolsEnabled,
+		ID:                         defaultConversationSettings.ID,
+		SystemPromptTemplate:       params.SystemPromptTemplate,
+		ToolsEnabled:               params.ToolsEnabled,
+		Provider:                   params.Provider,
+		Model:                      params.Model,
+		MaxConsecutiveToolFailures: params.MaxConsecutiveToolFailures,
+		ContextWindow:              params.ContextWindow,
+		Temperature:                params.Temperature,
+		MaxTokens:                  params.MaxTokens,
+		TopP:                       params.TopP,
+		Stop:                       params.Stop,
+		Sandbox:                    params.Sandbox,
 	})
 }
 
@@ -499,13 +1040,25 @@ func (a *App) getSettingsRaw() (database.Settings, error) {
 	keyValue, err := a.queries.GetKeyValue(a.ctx, "settings")
 	if errors.Is(err, sql.ErrNoRows) {
 		return database.Settings{
-			Model: "gpt-3.5-turbo",
+			Provider: "openai",
+			Model:    "gpt-3.5-turbo",
 			Terminal: database.TerminalSettings{
 				RequireApproval: true,
 			},
 			Python: database.PythonSettings{
 				InterpreterPath: "python3",
 			},
+			SQL: database.SQLSettings{
+				MaxRows: 100,
+			},
+			DuckDB: database.DuckDBSettings{
+				ExecutablePath: "duckdb",
+				MaxRows:        200,
+			},
+			Assistant: database.AssistantSettings{
+				MaxDepth: 2,
+				MaxSteps: 10,
+			},
 		}, nil
 	} else if err != nil {
 		return database.Settings{}, err
@@ -529,16 +1082,41 @@ func (a *App) GetSettings() (database.Settings, error) {
 	if settings.OpenAIAPIKey != "" {
 		settings.OpenAIAPIKey = "*****"
 	}
+	if settings.Anthropic.APIKey != "" {
+		settings.Anthropic.APIKey = "*****"
+	}
+	// DSNs usually contain passwords.
+	databases := make([]database.SQLDatabase, len(settings.SQL.Databases))
+	for i, db := range settings.SQL.Databases {
+		if db.DSN != "" {
+			db.DSN = "*****"
+		}
+		databases[i] = db
+	}
+	settings.SQL.Databases = databases
+	// Environment variables and headers of MCP servers usually contain API keys.
+	servers := make([]database.MCPServer, len(settings.MCP.Servers))
+	for i, server := range settings.MCP.Servers {
+		if len(server.Env) > 0 {
+			server.Env = []string{"*****"}
+		}
+		if len(server.Headers) > 0 {
+			server.Headers = []string{"*****"}
+		}
+		servers[i] = server
+	}
+	settings.MCP.Servers = servers
 	return settings, nil
 }
 
 func (a *App) SaveSettings(settings database.Settings) (database.Settings, error) {
-	_, err := a.queries.GetKeyValue(a.ctx, "settings")
-	if err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return database.Settings{}, fmt.Errorf("couldn't check if settings keyvalue exists: %w", err)
+	a.saveSettingsM.Lock()
+	defer a.saveSettingsM.Unlock()
+	for _, rule := range settings.Approvals.Rules {
+		if approvalPrecedence[rule.Decision] == 0 {
+			return database.Settings{}, fmt.Errorf("approval rule %s has an invalid decision `%s`, it has to be one of allow, deny or prompt", formatApprovalRule(rule), rule.Decision)
+		}
 	}
-	settingsExist := !errors.Is(err, sql.ErrNoRows)
-
 	oldSettings, err := a.getSettingsRaw()
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return database.Settings{}, err
@@ -546,32 +1124,75 @@ func (a *App) SaveSettings(settings database.Settings) (database.Settings, error
 	if settings.OpenAIAPIKey == "*****" {
 		settings.OpenAIAPIKey = oldSettings.OpenAIAPIKey
 	}
+	if settings.Anthropic.APIKey == "*****" {
+		settings.Anthropic.APIKey = oldSettings.Anthropic.APIKey
+	}
+	for i, db := range settings.SQL.Databases {
+		if db.DSN != "*****" {
+			continue
+		}
+		settings.SQL.Databases[i].DSN = ""
+		for _, oldDB := range oldSettings.SQL.Databases {
+			if oldDB.Name == db.Name {
+				settings.SQL.Databases[i].DSN = oldDB.DSN
+			}
+		}
+	}
+	for i, server := range settings.MCP.Servers {
+		for _, oldServer := range oldSettings.MCP.Servers {
+			if oldServer.Name != server.Name {
+				continue
+			}
+			if slices.Equal(server.Env, []string{"*****"}) {
+				settings.MCP.Servers[i].Env = oldServer.Env
+			}
+			if slices.Equal(server.Headers, []string{"*****"}) {
+				settings.MCP.Servers[i].Headers = oldServer.Headers
+			}
+		}
+	}
+
+	if err := a.storeSettings(settings); err != nil {
+		return database.Settings{}, err
+	}
+
+	a.m.Lock()
+	a.settings = settings
+	a.m.Unlock()
+	// Tools are configured when instantiated, so they're started again with the new settings.
+	a.shutdownToolInstances()
+	a.refreshMCPTools(settings.MCP.Servers)
+
+	return settings, nil
+}
+
+func (a *App) storeSettings(settings database.Settings) error {
+	_, err := a.queries.GetKeyValue(a.ctx, "settings")
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("couldn't check if settings keyvalue exists: %w", err)
+	}
+	settingsExist := !errors.Is(err, sql.ErrNoRows)
 
 	settingsJSON, err := json.Marshal(settings)
 	if err != nil {
-		return database.Settings{}, err
+		return err
 	}
 	if settingsExist {
 		if err := a.queries.UpdateKeyValue(a.ctx, database.UpdateKeyValueParams{
 			Key:   "settings",
 			Value: string(settingsJSON),
 		}); err != nil {
-			return database.Settings{}, fmt.Errorf("couldn't save settings: %w", err)
+			return fmt.Errorf("couldn't save settings: %w", err)
 		}
 	} else {
 		if err := a.queries.CreateKeyValue(a.ctx, database.CreateKeyValueParams{
 			Key:   "settings",
 			Value: string(settingsJSON),
 		}); err != nil {
-			return database.Settings{}, fmt.Errorf("couldn't save settings: %w", err)
+			return fmt.Errorf("couldn't save settings: %w", err)
 		}
 	}
-
-	a.m.Lock()
-	defer a.m.Unlock()
-	a.settings = settings
-
-	return settings, nil
+	return nil
 }
 
 type AvailableTool struct {
@@ -581,7 +1202,7 @@ type AvailableTool struct {
 
 func (a *App) GetAvailableTools() []AvailableTool {
 	var out []AvailableTool
-	for id, tool := range a.tools {
+	for id, tool := range a.availableTools() {
 		out = append(out, AvailableTool{
 			Name: tool.Name(),
 			ID:   id,
@@ -593,9 +1214,45 @@ func (a *App) GetAvailableTools() []AvailableTool {
 	return out
 }
 
+type AvailableProvider struct {
+	Name string `json:"name"`
+	ID   string `json:"ID"`
+}
+
+func (a *App) GetAvailableProviders() []AvailableProvider {
+	var out []AvailableProvider
+	for id, provider := range a.providers {
+		out = append(out, AvailableProvider{
+			Name: provider.Name(),
+			ID:   id,
+		})
+	}
+	slices.SortFunc(out, func(a, b AvailableProvider) bool {
+		return a.Name < b.Name
+	})
+	return out
+}
+
+// ApprovalRequest is a tool asking for approval, with everything the user needs to know to decide.
 type ApprovalRequest struct {
 	ID      string `json:"id"`
 	Message string `json:"message"`
+	// ConversationID is the conversation the tool runs in, which is a sub-conversation if it isn't the one the request is listed for.
+	ConversationID int `json:"conversationID"`
+	// The tool call, if the request is about one. Its arguments are what ApproveWithEdits takes edited.
+	ToolID           string                 `json:"toolID,omitempty"`
+	ToolName         string                 `json:"toolName,omitempty"`
+	Args             map[string]interface{} `json:"args,omitempty"`
+	WorkingDirectory string                 `json:"workingDirectory,omitempty"`
+	// Risk is low, medium or high, or empty if the tool can't tell, with RiskReason explaining it.
+	Risk       string `json:"risk,omitempty"`
+	RiskReason string `json:"riskReason,omitempty"`
+	// Preview shows what exactly is going to happen, i.e. the command or a diff.
+	// PreviewType is one of shell, diff, sql, json or url, or empty for plain text.
+	Preview     string `json:"preview,omitempty"`
+	PreviewType string `json:"previewType,omitempty"`
+	// CanApproveAlways is set for requests about tool calls, which ApproveAlways can make an approval rule of.
+	CanApproveAlways bool `json:"canApproveAlways"`
 }
 
 func (a *App) ListApprovalRequests(conversationID int) ([]ApprovalRequest, error) {
@@ -606,29 +1263,92 @@ func (a *App) ListApprovalRequests(conversationID int) ([]ApprovalRequest, error
 		// Empty array and nil are *not the same* for the frontend side.
 		return []ApprovalRequest{}, nil
 	}
-	return []ApprovalRequest{
-		{
-			ID:      req.approvalID,
-			Message: req.message,
-		},
-	}, nil
+	out := ApprovalRequest{
+		ID:               req.approvalID,
+		Message:          req.request.Message,
+		ConversationID:   req.conversationID,
+		WorkingDirectory: req.request.WorkingDirectory,
+		Risk:             string(req.request.Risk),
+		RiskReason:       req.request.RiskReason,
+		Preview:          req.request.Preview,
+		PreviewType:      req.request.PreviewType,
+		CanApproveAlways: req.toolCall != nil,
+	}
+	if req.toolCall != nil {
+		out.ToolID = req.toolCall.Tool
+		out.ToolName = req.toolName
+		out.Args = req.toolCall.Args
+	}
+	return []ApprovalRequest{out}, nil
 }
 
-func (a *App) Approve(conversationID int, approvalID string) error {
+func (a *App) pendingApprovalRequest(conversationID int, approvalID string) (approvalRequest, error) {
 	a.m.Lock()
 	req, ok := a.pendingApprovalRequests[conversationID]
 	a.m.Unlock()
 	if !ok {
-		return fmt.Errorf("no pending approval request for conversation %d", conversationID)
+		return approvalRequest{}, fmt.Errorf("no pending approval request for conversation %d", conversationID)
 	}
 	if req.approvalID != approvalID {
-		return fmt.Errorf("no pending approval request with ID %d for conversation %d", approvalID, conversationID)
+		return approvalRequest{}, fmt.Errorf("no pending approval request with ID %s for conversation %d", approvalID, conversationID)
+	}
+	return req, nil
+}
+
+func (a *App) decideApprovalRequest(conversationID int, approvalID string, approval tools.Approval) error {
+	req, err := a.pendingApprovalRequest(conversationID, approvalID)
+	if err != nil {
+		return err
 	}
 	select {
-	case req.approvalChan <- struct{}{}:
+	case req.approvalChan <- approval:
 	default:
 		// I.e. because a user approved repeatedly in quick succession.
 		// The channel should be buffered, so at least one message will go through.
 	}
 	return nil
 }
+
+func (a *App) Approve(conversationID int, approvalID string) error {
+	return a.decideApprovalRequest(conversationID, approvalID, tools.Approval{Approved: true})
+}
+
+// Reject rejects the request, with the reason becoming the tool call's observation, so the model can try something else.
+func (a *App) Reject(conversationID int, approvalID string, reason string) error {
+	return a.decideApprovalRequest(conversationID, approvalID, tools.Approval{Reason: strings.TrimSpace(reason)})
+}
+
+// ApproveWithEdits approves the tool call the request is about, but with the given arguments instead of the model's.
+func (a *App) ApproveWithEdits(conversationID int, approvalID string, args map[string]interface{}) error {
+	req, err := a.pendingApprovalRequest(conversationID, approvalID)
+	if err != nil {
+		return err
+	}
+	if req.toolCall == nil {
+		return fmt.Errorf("approval request %s isn't about a tool call, so its arguments can't be edited", approvalID)
+	}
+	tool, ok := a.availableTools()[req.toolCall.Tool]
+	if !ok {
+		return fmt.Errorf("tool `%s` not found", req.toolCall.Tool)
+	}
+	args, err = tools.ValidateArguments(tool.ArgumentSchema(), args)
+	if err != nil {
+		return err
+	}
+	return a.decideApprovalRequest(conversationID, approvalID, tools.Approval{Approved: true, Args: args})
+}
+
+// ApproveAlways approves the request, and adds an approval rule allowing the same tool call from now on.
+func (a *App) ApproveAlways(conversationID int, approvalID string) error {
+	req, err := a.pendingApprovalRequest(conversationID, approvalID)
+	if err != nil {
+		return err
+	}
+	if req.toolCall == nil {
+		return fmt.Errorf("approval request %s isn't about a tool call, so it can't be always allowed", approvalID)
+	}
+	if err := a.addApprovalRule(exactApprovalRule(req.toolCall.Tool, req.toolCall.Args)); err != nil {
+		return fmt.Errorf("couldn't add approval rule: %w", err)
+	}
+	return a.Approve(conversationID, approvalID)
+}
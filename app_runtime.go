@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 
-	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"golang.org/x/exp/rand"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
 )
 
 type AppRuntime struct {
@@ -13,29 +16,99 @@ type AppRuntime struct {
 	app            *App
 }
 
-func (r *AppRuntime) WaitForApproval(ctx context.Context, message string) error {
-	approvalChan := make(chan struct{}, 1)
+func (r *AppRuntime) WaitForApproval(ctx context.Context, request tools.ApprovalRequest) (tools.Approval, error) {
+	state, _ := ctx.Value(toolCallKey{}).(*toolCallState)
+	if state != nil && state.approved {
+		return tools.Approval{Approved: true}, nil
+	}
+	var toolCall *database.ToolCall
+	toolName := ""
+	if state != nil {
+		call := state.toolCall
+		toolCall = &call
+		if tool, ok := r.app.availableTools()[call.Tool]; ok {
+			toolName = tool.Name()
+		}
+	}
+	conversationID := r.app.rootConversationID(r.conversationID)
+	if conversationID != r.conversationID {
+		request.Message = fmt.Sprintf("%s (in sub-conversation %d)", request.Message, r.conversationID)
+	}
+	if state != nil {
+		state.approval = executionApprovalPending
+	}
+	approvalChan := make(chan tools.Approval, 1)
 	approvalID := make([]byte, 8)
 	rand.Read(approvalID)
 	r.app.m.Lock()
-	r.app.pendingApprovalRequests[r.conversationID] = approvalRequest{
-		approvalID:   fmt.Sprintf("%x", approvalID),
-		approvalChan: approvalChan,
-		message:      message,
+	r.app.pendingApprovalRequests[conversationID] = approvalRequest{
+		approvalID:     fmt.Sprintf("%x", approvalID),
+		approvalChan:   approvalChan,
+		conversationID: r.conversationID,
+		request:        request,
+		toolCall:       toolCall,
+		toolName:       toolName,
 	}
 	r.app.m.Unlock()
-	runtime.EventsEmit(ctx, fmt.Sprintf("conversation-%d-approvals-updated", r.conversationID))
+	r.app.events.Emit(fmt.Sprintf("conversation-%d-approvals-updated", conversationID))
 	defer func() {
 		r.app.m.Lock()
-		delete(r.app.pendingApprovalRequests, r.conversationID)
+		delete(r.app.pendingApprovalRequests, conversationID)
 		r.app.m.Unlock()
-		runtime.EventsEmit(ctx, fmt.Sprintf("conversation-%d-approvals-updated", r.conversationID))
+		r.app.events.Emit(fmt.Sprintf("conversation-%d-approvals-updated", conversationID))
 	}()
 
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
-	case <-approvalChan:
-		return nil
+		return tools.Approval{}, ctx.Err()
+	case approval := <-approvalChan:
+		if state == nil {
+			return approval, nil
+		}
+		state.approvedBy = approver()
+		switch {
+		case !approval.Approved:
+			state.approval = executionApprovalRejected
+		case approval.Args != nil:
+			// The tool runs again with the edited arguments, which the user has already approved.
+			state.toolCall.Args = approval.Args
+			state.approved = true
+			state.edited = true
+			state.approval = executionApprovalApprovedWithEdits
+		default:
+			state.approval = executionApprovalApproved
+		}
+		return approval, nil
+	}
+}
+
+func (r *AppRuntime) RunSubConversation(ctx context.Context, subConversation tools.SubConversation) (string, error) {
+	return r.app.runSubConversation(ctx, r.conversationID, subConversation)
+}
+
+func (r *AppRuntime) Workspace() (string, error) {
+	return r.app.workspace(r.app.rootConversationID(r.conversationID))
+}
+
+func (r *AppRuntime) ConversationID() int {
+	return r.conversationID
+}
+
+func (r *AppRuntime) OutputWriter(ctx context.Context) io.Writer {
+	if output, ok := ctx.Value(toolOutputKey{}).(*toolOutput); ok {
+		return output
+	}
+	return io.Discard
+}
+
+func (r *AppRuntime) Sandbox() (database.SandboxSettings, error) {
+	conversation, err := r.app.queries.GetConversation(r.app.ctx, r.conversationID)
+	if err != nil {
+		return database.SandboxSettings{}, fmt.Errorf("couldn't get conversation: %w", err)
+	}
+	conversationSettings, err := r.app.queries.GetConversationSettings(r.app.ctx, conversation.ConversationSettingsID)
+	if err != nil {
+		return database.SandboxSettings{}, fmt.Errorf("couldn't get conversation settings: %w", err)
 	}
+	return conversationSettings.Sandbox, nil
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"cuttlefish/database"
+	"cuttlefish/database/migrate"
+	"cuttlefish/llm"
+)
+
+type nopEmitter struct{}
+
+func (nopEmitter) Emit(eventName string, data ...interface{}) {}
+
+// newTestApp returns an app with a fresh database, whose openai provider is replaced by provider.
+func newTestApp(t *testing.T, provider llm.Provider) *App {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(dir, "data.db")+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	if err := migrate.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	a := NewApp(context.Background(), database.New(db), nopEmitter{})
+	a.providers["openai"] = provider
+	return a
+}
+
+// newTestConversation creates a conversation with the default settings, which starts with the user's message.
+func newTestConversation(t *testing.T, a *App, content string) int {
+	conversationID, err := a.createConversationWithDefaultSettings(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.addMessage(a.ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		Content:        content,
+		Author:         "user",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return conversationID
+}
+
+// fakeProvider responds with its responses in order, and records the requests it gets.
+type fakeProvider struct {
+	functionCalling bool
+	responses       []string
+
+	m        sync.Mutex
+	requests []llm.ChatCompletionRequest
+}
+
+func (p *fakeProvider) Name() string {
+	return "Fake"
+}
+
+func (p *fakeProvider) Instantiate(settings database.Settings) (llm.Client, error) {
+	return p, nil
+}
+
+func (p *fakeProvider) SupportsFunctionCalling(model string) bool {
+	return p.functionCalling
+}
+
+func (p *fakeProvider) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	response := ""
+	if len(p.requests) < len(p.responses) {
+		response = p.responses[len(p.requests)]
+	}
+	p.requests = append(p.requests, req)
+	return &fakeStream{content: response}, nil
+}
+
+type fakeStream struct {
+	content string
+	done    bool
+}
+
+func (s *fakeStream) Recv() (llm.ChatCompletionChunk, error) {
+	if s.done {
+		return llm.ChatCompletionChunk{}, io.EOF
+	}
+	s.done = true
+	return llm.ChatCompletionChunk{Content: s.content}, nil
+}
+
+func (s *fakeStream) Close() error {
+	return nil
+}
+
+func TestRetryWithoutStop(t *testing.T) {
+	provider := &fakeProvider{responses: []string{"", "the answer"}}
+	a := newTestApp(t, provider)
+	conversationID := newTestConversation(t, a, "hello")
+
+	if err := a.runChainOfMessages(conversationID); err != nil {
+		t.Fatal(err)
+	}
+	if len(provider.requests) != 2 {
+		t.Fatalf("got %d requests, want the first one and a retry", len(provider.requests))
+	}
+	if len(provider.requests[0].Stop) == 0 {
+		t.Errorf("the first request has no stop sequences, want the text-based protocol's")
+	}
+	if len(provider.requests[1].Stop) != 0 {
+		t.Errorf("the retry has the stop sequences %q, want none", provider.requests[1].Stop)
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	provider := &fakeProvider{}
+	a := newTestApp(t, provider)
+	conversationID := newTestConversation(t, a, "hello")
+
+	if err := a.runChainOfMessages(conversationID); err == nil {
+		t.Fatal("expected an error after only getting empty responses")
+	}
+	for i, req := range provider.requests[1:] {
+		if len(req.Stop) != 0 {
+			t.Errorf("retry %d has the stop sequences %q, want none", i+1, req.Stop)
+		}
+	}
+}
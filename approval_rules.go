@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cuttlefish/database"
+)
+
+// Approval rules let the user decide in advance about tool calls, i.e. to allow `git status`, but deny `rm -rf`.
+// They're checked before a tool runs. If none of them match, the tool asks for approval as it sees fit,
+// i.e. the terminal tool if it's configured to.
+
+const (
+	approvalAllow  = "allow"
+	approvalDeny   = "deny"
+	approvalPrompt = "prompt"
+)
+
+// Of all the matching rules, the one with the highest precedence decides, so a rule allowing specific calls
+// can make an exception to one that prompts for all of them, but never to one that denies them.
+var approvalPrecedence = map[string]int{
+	approvalPrompt: 1,
+	approvalAllow:  2,
+	approvalDeny:   3,
+}
+
+type approvalDecision struct {
+	decision string
+	rule     database.ApprovalRule
+}
+
+// decideApproval returns the decision of the rules about the tool call, which is empty if none of them match.
+func decideApproval(rules []database.ApprovalRule, toolID string, args map[string]interface{}) (approvalDecision, error) {
+	var out approvalDecision
+	for _, rule := range rules {
+		if approvalPrecedence[rule.Decision] == 0 {
+			return approvalDecision{}, fmt.Errorf("approval rule %s has an invalid decision `%s`, it has to be one of allow, deny or prompt", formatApprovalRule(rule), rule.Decision)
+		}
+		if !matchesApprovalRule(rule, toolID, args) {
+			continue
+		}
+		if approvalPrecedence[rule.Decision] > approvalPrecedence[out.decision] {
+			out = approvalDecision{
+				decision: rule.Decision,
+				rule:     rule,
+			}
+		}
+	}
+	return out, nil
+}
+
+func matchesApprovalRule(rule database.ApprovalRule, toolID string, args map[string]interface{}) bool {
+	if !matchPattern(rule.Tool, toolID) {
+		return false
+	}
+	for name, pattern := range rule.Arguments {
+		if !matchArgument(rule.Decision, pattern, approvalArgumentValue(args[name])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Shell control operators, which chain or nest commands.
+var controlOperators = []string{";", "&", "|", "$(", "`", "\n"}
+
+// matchArgument matches the argument's value against the rule's pattern.
+// Allow rules don't match values with control operators their pattern doesn't have,
+// so that i.e. `git *` doesn't allow `git status; rm -rf ~` too, unless the pattern is just *, which allows anything.
+// Deny rules match greedily, as matching too much only makes them stricter.
+func matchArgument(decision string, pattern string, value string) bool {
+	if !matchPattern(pattern, value) {
+		return false
+	}
+	if decision != approvalAllow || pattern == "*" {
+		return true
+	}
+	for _, operator := range controlOperators {
+		if strings.Contains(value, operator) && !strings.Contains(pattern, operator) {
+			return false
+		}
+	}
+	return true
+}
+
+func approvalArgumentValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// matchPattern matches the whole value, with * matching anything, including newlines, as commands may span multiple lines.
+func matchPattern(pattern string, value string) bool {
+	var expr strings.Builder
+	expr.WriteString(`(?s)^`)
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case c == '*':
+			expr.WriteString(`.*`)
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString(`$`)
+	return regexp.MustCompile(expr.String()).MatchString(value)
+}
+
+func escapePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`).Replace(value)
+}
+
+// exactApprovalRule allows exactly this tool call again, which is what the user asks for by always allowing it.
+func exactApprovalRule(toolID string, args map[string]interface{}) database.ApprovalRule {
+	rule := database.ApprovalRule{
+		Tool:      escapePattern(toolID),
+		Arguments: map[string]string{},
+		Decision:  approvalAllow,
+	}
+	for name, value := range args {
+		rule.Arguments[name] = escapePattern(approvalArgumentValue(value))
+	}
+	return rule
+}
+
+// formatApprovalRule describes the rule for error messages, i.e. `terminal` with command `rm -rf *`.
+func formatApprovalRule(rule database.ApprovalRule) string {
+	names := make([]string, 0, len(rule.Arguments))
+	for name := range rule.Arguments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := []string{fmt.Sprintf("`%s`", rule.Tool)}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s `%s`", name, rule.Arguments[name]))
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return parts[0] + " with " + strings.Join(parts[1:], " and ")
+}
+
+// addApprovalRule saves the rule along with the other settings.
+// Unlike saving the settings, it keeps the tool instances running, as rules don't affect them.
+func (a *App) addApprovalRule(rule database.ApprovalRule) error {
+	a.saveSettingsM.Lock()
+	defer a.saveSettingsM.Unlock()
+	settings, err := a.getSettingsRaw()
+	if err != nil {
+		return err
+	}
+	settings.Approvals.Rules = append(settings.Approvals.Rules, rule)
+	if err := a.storeSettings(settings); err != nil {
+		return err
+	}
+	a.m.Lock()
+	a.settings.Approvals = settings.Approvals
+	a.m.Unlock()
+	return nil
+}
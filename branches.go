@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"cuttlefish/database"
+)
+
+// Messages form a tree, with each message pointing to its parent.
+// Rerunning or editing a message creates a new sibling, so that earlier branches stay available.
+// The conversation points at the leaf of its active branch, which is what gets shown and continued.
+
+type Branch struct {
+	// The last message of the branch, which identifies it.
+	LeafMessageID int `json:"leafMessageID"`
+	// The first message of the branch which isn't shared with the previous branch, or the first message of the conversation.
+	ForkMessageID int              `json:"forkMessageID"`
+	Length        int              `json:"length"`
+	LastMessage   database.Message `json:"lastMessage"`
+	Active        bool             `json:"active"`
+}
+
+type BranchComparison struct {
+	// Messages shared by both branches.
+	Common []database.Message `json:"common"`
+	// Messages after the fork point, for each of the branches.
+	A []database.Message `json:"a"`
+	B []database.Message `json:"b"`
+}
+
+// addMessage creates a message as a child of the conversation's active message, and makes it the active one.
+func (a *App) addMessage(ctx context.Context, params database.CreateMessageParams) (database.Message, error) {
+	conversation, err := a.queries.GetConversation(ctx, params.ConversationID)
+	if err != nil {
+		return database.Message{}, fmt.Errorf("couldn't get conversation: %w", err)
+	}
+	params.ParentMessageID = conversation.ActiveMessageID
+	msg, err := a.queries.CreateMessage(ctx, params)
+	if err != nil {
+		return database.Message{}, err
+	}
+	if err := a.queries.SetActiveMessage(ctx, database.SetActiveMessageParams{
+		ActiveMessageID: sql.NullInt64{Int64: int64(msg.ID), Valid: true},
+		ID:              params.ConversationID,
+	}); err != nil {
+		return database.Message{}, fmt.Errorf("couldn't set active message: %w", err)
+	}
+	return msg, nil
+}
+
+// activeBranchMessages lists the messages of the active branch, with the ones replaced by its latest summary marked as summarized.
+// That's worked out for each branch, as sibling branches share the summarized messages, but not necessarily the summary.
+func (a *App) activeBranchMessages(ctx context.Context, conversationID int) ([]database.Message, error) {
+	messages, err := a.queries.ListMessages(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := a.queries.ListMessageSummaries(ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list summaries: %w", err)
+	}
+	summarizedUntil := make(map[int]int, len(summaries))
+	for _, summary := range summaries {
+		summarizedUntil[summary.MessageID] = summary.SummarizedUntilMessageID
+	}
+	markSummarized(messages, summarizedUntil)
+	return messages, nil
+}
+
+// markSummarized marks the messages the branch's latest summary replaces as summarized, including earlier summaries,
+// which the latest one covers. Summaries without a record of what they summarize are left out too.
+func markSummarized(messages []database.Message, summarizedUntil map[int]int) {
+	latest, until := -1, -1
+	for i, message := range messages {
+		if message.Author != summaryAuthor {
+			continue
+		}
+		messages[i].ContextStatus = contextStatusSummarized
+		untilID, ok := summarizedUntil[message.ID]
+		if !ok {
+			continue
+		}
+		for j := range messages[:i] {
+			if messages[j].ID == untilID {
+				latest, until = i, j
+			}
+		}
+	}
+	if latest == -1 {
+		return
+	}
+	messages[latest].ContextStatus = contextStatusFull
+	for i := 0; i <= until; i++ {
+		messages[i].ContextStatus = contextStatusSummarized
+	}
+}
+
+func (a *App) ListBranches(conversationID int) ([]Branch, error) {
+	conversation, err := a.queries.GetConversation(a.ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get conversation: %w", err)
+	}
+	leaves, err := a.queries.ListConversationLeaves(a.ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list conversation leaves: %w", err)
+	}
+
+	out := make([]Branch, 0, len(leaves))
+	var previous []database.Message
+	for _, leaf := range leaves {
+		messages, err := a.queries.ListBranchMessages(a.ctx, leaf.ID)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't list branch messages: %w", err)
+		}
+		fork := messages[commonPrefixLength(previous, messages)]
+		out = append(out, Branch{
+			LeafMessageID: leaf.ID,
+			ForkMessageID: fork.ID,
+			Length:        len(messages),
+			LastMessage:   leaf,
+			Active:        conversation.ActiveMessageID.Valid && int(conversation.ActiveMessageID.Int64) == leaf.ID,
+		})
+		previous = messages
+	}
+	return out, nil
+}
+
+// SwitchBranch makes the branch containing the given message active.
+// If the message is shared by multiple branches, the most recent of them is picked.
+func (a *App) SwitchBranch(conversationID int, messageID int) error {
+	// Switching while generating would interleave the new messages with the other branch.
+	a.CancelGeneration(conversationID)
+
+	msg, err := a.queries.GetMessage(a.ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("couldn't get message: %w", err)
+	}
+	if msg.ConversationID != conversationID {
+		return fmt.Errorf("message %d doesn't belong to conversation %d", messageID, conversationID)
+	}
+	leafID, err := a.queries.GetLatestDescendant(a.ctx, messageID)
+	if err != nil {
+		return fmt.Errorf("couldn't get latest descendant: %w", err)
+	}
+	if err := a.queries.SetActiveMessage(a.ctx, database.SetActiveMessageParams{
+		ActiveMessageID: sql.NullInt64{Int64: int64(leafID), Valid: true},
+		ID:              conversationID,
+	}); err != nil {
+		return fmt.Errorf("couldn't set active message: %w", err)
+	}
+	a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
+	return nil
+}
+
+func (a *App) CompareBranches(conversationID int, leafMessageIDA int, leafMessageIDB int) (BranchComparison, error) {
+	var branches [2][]database.Message
+	for i, leafID := range []int{leafMessageIDA, leafMessageIDB} {
+		messages, err := a.queries.ListBranchMessages(a.ctx, leafID)
+		if err != nil {
+			return BranchComparison{}, fmt.Errorf("couldn't list branch messages: %w", err)
+		}
+		if len(messages) == 0 || messages[0].ConversationID != conversationID {
+			return BranchComparison{}, fmt.Errorf("message %d doesn't belong to conversation %d", leafID, conversationID)
+		}
+		branches[i] = messages
+	}
+
+	common := commonPrefixLength(branches[0], branches[1])
+	return BranchComparison{
+		Common: branches[0][:common],
+		A:      branches[0][common:],
+		B:      branches[1][common:],
+	}, nil
+}
+
+func commonPrefixLength(a, b []database.Message) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i].ID == b[i].ID {
+		i++
+	}
+	return i
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"cuttlefish/database"
+)
+
+func addTestMessage(t *testing.T, a *App, conversationID int, author string, content string) database.Message {
+	msg, err := a.addMessage(a.ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		Content:        content,
+		Author:         author,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+// contextStatuses returns the content of each message of the active branch, along with its status.
+func contextStatuses(t *testing.T, a *App, conversationID int) []string {
+	messages, err := a.activeBranchMessages(a.ctx, conversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make([]string, len(messages))
+	for i, message := range messages {
+		out[i] = message.Content + ":" + message.ContextStatus
+	}
+	return out
+}
+
+func TestSummariesArePerBranch(t *testing.T) {
+	provider := &fakeProvider{responses: []string{"first summary", "second summary"}}
+	a := newTestApp(t, provider)
+	conversationID := newTestConversation(t, a, "u1")
+	addTestMessage(t, a, conversationID, "assistant", "a1")
+	addTestMessage(t, a, conversationID, "user", "u2")
+	addTestMessage(t, a, conversationID, "assistant", "a2")
+
+	summarize := func(count int) {
+		messages, err := a.activeBranchMessages(a.ctx, conversationID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.summarizeMessages(a.ctx, conversationID, provider, "model", messages[:count]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	summarize(2)
+	fork := addTestMessage(t, a, conversationID, "assistant", "a2b")
+	addTestMessage(t, a, conversationID, "user", "u3")
+	addTestMessage(t, a, conversationID, "assistant", "a3")
+	summarize(6)
+
+	got := strings.Join(contextStatuses(t, a, conversationID), " ")
+	want := "u1:summarized a1:summarized u2:summarized a2:summarized first summary:summarized a2b:summarized u3:full a3:full second summary:full"
+	if got != want {
+		t.Errorf("branch with both summaries:\n got %s\nwant %s", got, want)
+	}
+
+	// A sibling branch forking after the first summary, but before the second.
+	if err := a.queries.SetActiveMessage(a.ctx, database.SetActiveMessageParams{
+		ActiveMessageID: sql.NullInt64{Int64: int64(fork.ID), Valid: true},
+		ID:              conversationID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	addTestMessage(t, a, conversationID, "user", "u3b")
+
+	got = strings.Join(contextStatuses(t, a, conversationID), " ")
+	want = "u1:summarized a1:summarized u2:full a2:full first summary:full a2b:full u3b:full"
+	if got != want {
+		t.Errorf("sibling branch with the first summary:\n got %s\nwant %s", got, want)
+	}
+
+	settings, err := a.GetDefaultConversationSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := a.activeBranchMessages(a.ctx, conversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llmMessages, err := a.messagesToLLMMessages(settings, messages, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, message := range llmMessages[1:] {
+		contents = append(contents, message.Content)
+	}
+	got = strings.Join(contents, " | ")
+	want = "Summary of the earlier conversation:\nfirst summary | u2 | a2 | a2b | u3b"
+	if got != want {
+		t.Errorf("sibling branch sends:\n got %q\nwant %q", got, want)
+	}
+}
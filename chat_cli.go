@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"cuttlefish/database"
+)
+
+// The chat subcommand is a terminal frontend, for machines where the desktop app can't run, i.e. over SSH.
+// It uses the same database, tools and approval flow as the desktop app.
+
+// chatEventEmitter only passes event names on, as the chat reloads the conversation state on each of them anyway.
+type chatEventEmitter struct {
+	events chan string
+}
+
+func (e *chatEventEmitter) Emit(eventName string, data ...interface{}) {
+	select {
+	case e.events <- eventName:
+	default:
+		// There's already a pending event, which will show this change as well.
+	}
+}
+
+type chat struct {
+	app            *App
+	conversationID int
+	out            io.Writer
+
+	// The part of each message that has already been printed.
+	printedContent   map[int]string
+	printedToolCalls map[int]bool
+	// The approval request the user is currently being asked about.
+	pendingApprovalID string
+	// Whether the pending request can be approved always or with edits.
+	pendingCanApproveAlways bool
+}
+
+func runChat(ctx context.Context, queries *database.Queries, args []string) error {
+	flags := flag.NewFlagSet("chat", flag.ExitOnError)
+	conversationID := flags.Int("conversation", -1, "ID of the conversation to resume. A new one is started if not set.")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	events := &chatEventEmitter{events: make(chan string, 1)}
+	c := &chat{
+		app:              NewApp(ctx, queries, events),
+		conversationID:   *conversationID,
+		out:              os.Stdout,
+		printedContent:   map[int]string{},
+		printedToolCalls: map[int]bool{},
+	}
+	defer c.app.shutdown(ctx)
+
+	if c.conversationID != -1 {
+		conversation, err := c.app.GetConversation(c.conversationID)
+		if err != nil {
+			return fmt.Errorf("couldn't get conversation %d: %w", c.conversationID, err)
+		}
+		fmt.Fprintf(c.out, "Resuming conversation %d: %s\n", conversation.ID, conversation.Title)
+		if err := c.printNewContent(); err != nil {
+			return err
+		}
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	for {
+		fmt.Fprint(c.out, "\nyou> ")
+		var content string
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				fmt.Fprintln(c.out)
+				return nil
+			}
+			content = line
+		case <-interrupts:
+			fmt.Fprintln(c.out)
+			return nil
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		done, err := c.send(ctx, content)
+		if err != nil {
+			return err
+		}
+		if err := c.waitForGeneration(done, events.events, lines, interrupts); err != nil {
+			fmt.Fprintf(c.out, "\nerror: %s\n", err)
+		}
+	}
+}
+
+// send adds the user message and starts generating the response, returning a channel with the generation's result.
+func (c *chat) send(ctx context.Context, content string) (<-chan error, error) {
+	if c.conversationID == -1 {
+		conversationID, err := c.app.createConversationWithDefaultSettings(content)
+		if err != nil {
+			return nil, err
+		}
+		c.conversationID = conversationID
+		fmt.Fprintf(c.out, "(started conversation %d)\n", conversationID)
+	}
+	msg, err := c.app.addMessage(ctx, database.CreateMessageParams{
+		ConversationID: c.conversationID,
+		Content:        content,
+		Author:         "user",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create message: %w", err)
+	}
+	// The user has already seen it while typing.
+	c.printedContent[msg.ID] = msg.Content
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.app.runChainOfMessages(c.conversationID)
+	}()
+	return done, nil
+}
+
+func (c *chat) waitForGeneration(done <-chan error, events <-chan string, lines <-chan string, interrupts <-chan os.Signal) error {
+	for {
+		select {
+		case err := <-done:
+			// Events emitted right before finishing might not have been handled yet.
+			if printErr := c.printNewContent(); printErr != nil {
+				return printErr
+			}
+			fmt.Fprintln(c.out)
+			if errors.Is(err, context.Canceled) {
+				fmt.Fprintln(c.out, "(generation cancelled)")
+				return nil
+			}
+			return err
+		case <-events:
+			if err := c.printNewContent(); err != nil {
+				return err
+			}
+			if err := c.promptForApproval(); err != nil {
+				return err
+			}
+		case line, ok := <-lines:
+			if !ok {
+				c.app.CancelGeneration(c.conversationID)
+				lines = nil
+				continue
+			}
+			if err := c.answerApproval(line); err != nil {
+				// I.e. the request got decided or cancelled in the meantime. The generation goes on either way,
+				// so instead of giving up on it, whatever request is pending now gets prompted for.
+				fmt.Fprintf(c.out, "\n%s\n", err)
+				c.pendingApprovalID = ""
+				if err := c.promptForApproval(); err != nil {
+					return err
+				}
+			}
+		case <-interrupts:
+			// Ctrl+C stops the generation, and only exits when there's nothing to stop.
+			c.app.CancelGeneration(c.conversationID)
+		}
+	}
+}
+
+// printNewContent prints whatever has been added to the conversation since the last call, i.e. streamed tokens and tool outputs.
+func (c *chat) printNewContent() error {
+	messages, err := c.app.Messages(c.conversationID)
+	if err != nil {
+		return fmt.Errorf("couldn't list messages: %w", err)
+	}
+	for _, message := range messages {
+		printed, started := c.printedContent[message.ID]
+		if started && !strings.HasPrefix(message.Content, printed) {
+			// Streamed tool output gets replaced by the tool's observation once it finishes, of which only the result is new.
+			result, _, _ := strings.Cut(message.Content, "\n")
+			fmt.Fprintf(c.out, "\n%s", result)
+			c.printedContent[message.ID] = message.Content
+		} else if len(printed) < len(message.Content) {
+			if !started {
+				fmt.Fprintf(c.out, "\n%s> ", chatAuthorLabel(message.Author))
+			}
+			fmt.Fprint(c.out, message.Content[len(printed):])
+			c.printedContent[message.ID] = message.Content
+		}
+		if len(message.ToolCalls) > 0 && !c.printedToolCalls[message.ID] {
+			for _, toolCall := range message.ToolCalls {
+				args, err := json.Marshal(toolCall.Args)
+				if err != nil {
+					return fmt.Errorf("couldn't encode tool call arguments: %w", err)
+				}
+				fmt.Fprintf(c.out, "\n(calling `%s` with %s)", toolCall.Tool, string(args))
+			}
+			c.printedToolCalls[message.ID] = true
+		}
+	}
+	return nil
+}
+
+func chatAuthorLabel(author string) string {
+	if author == "user" {
+		return "you"
+	}
+	return author
+}
+
+func (c *chat) promptForApproval() error {
+	requests, err := c.app.ListApprovalRequests(c.conversationID)
+	if err != nil {
+		return fmt.Errorf("couldn't list approval requests: %w", err)
+	}
+	if len(requests) == 0 {
+		c.pendingApprovalID = ""
+		return nil
+	}
+	if requests[0].ID == c.pendingApprovalID {
+		return nil
+	}
+	c.pendingApprovalID = requests[0].ID
+	c.pendingCanApproveAlways = requests[0].CanApproveAlways
+	req := requests[0]
+
+	fmt.Fprintf(c.out, "\nApproval requested: %s\n", req.Message)
+	var details []string
+	if req.ToolID != "" {
+		details = append(details, fmt.Sprintf("tool: %s (%s)", req.ToolName, req.ToolID))
+	}
+	if req.WorkingDirectory != "" {
+		details = append(details, "in: "+req.WorkingDirectory)
+	}
+	if req.Risk != "" {
+		risk := "risk: " + req.Risk
+		if req.RiskReason != "" {
+			risk += " (" + req.RiskReason + ")"
+		}
+		details = append(details, risk)
+	}
+	if len(details) > 0 {
+		fmt.Fprintf(c.out, "%s\n", strings.Join(details, ", "))
+	}
+	if req.Preview != "" {
+		fmt.Fprintf(c.out, "%s\n", strings.TrimRight(req.Preview, "\n"))
+	} else if req.Args != nil {
+		// The arguments are what editing starts from.
+		if args, err := json.Marshal(req.Args); err == nil {
+			fmt.Fprintf(c.out, "arguments: %s\n", args)
+		}
+	}
+	fmt.Fprintf(c.out, "Approve? [%s] ", approvalOptions(req.CanApproveAlways))
+	return nil
+}
+
+func approvalOptions(canApproveAlways bool) string {
+	if canApproveAlways {
+		return "y/n [reason]/a(lways)/e(dit) <args json>"
+	}
+	return "y/n [reason]"
+}
+
+func (c *chat) answerApproval(line string) error {
+	if c.pendingApprovalID == "" {
+		// Input typed during generation isn't a message, as that would interleave it with the response.
+		fmt.Fprint(c.out, "\n(still generating, press Ctrl+C to stop)\n")
+		return nil
+	}
+	// Rejections and edits are followed by the reason or the edited arguments, i.e. `n use git instead`.
+	answer, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	answer = strings.ToLower(answer)
+	if !c.pendingCanApproveAlways && (answer == "a" || answer == "always" || answer == "e" || answer == "edit") {
+		// The app would refuse it too, but then the request would be prompted for all over again.
+		fmt.Fprintf(c.out, "This request can't be approved always or with edits, please answer [%s]: ", approvalOptions(false))
+		return nil
+	}
+	switch answer {
+	case "y", "yes":
+		if err := c.app.Approve(c.conversationID, c.pendingApprovalID); err != nil {
+			return fmt.Errorf("couldn't approve: %w", err)
+		}
+	case "a", "always":
+		if err := c.app.ApproveAlways(c.conversationID, c.pendingApprovalID); err != nil {
+			return fmt.Errorf("couldn't approve: %w", err)
+		}
+	case "e", "edit":
+		var args map[string]interface{}
+		if err := json.Unmarshal([]byte(rest), &args); err != nil {
+			fmt.Fprintf(c.out, "The arguments have to be a JSON object (%s), please answer again: ", err)
+			return nil
+		}
+		if err := c.app.ApproveWithEdits(c.conversationID, c.pendingApprovalID, args); err != nil {
+			// I.e. the arguments don't match the tool's schema, in which case the request is still pending.
+			fmt.Fprintf(c.out, "%s, please answer again: ", err)
+			return nil
+		}
+	case "n", "no":
+		if err := c.app.Reject(c.conversationID, c.pendingApprovalID, rest); err != nil {
+			return fmt.Errorf("couldn't reject: %w", err)
+		}
+	default:
+		fmt.Fprintf(c.out, "Please answer [%s]: ", approvalOptions(c.pendingCanApproveAlways))
+		return nil
+	}
+	c.pendingApprovalID = ""
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestAnswerApprovalOnlyOffersWhatTheRequestAllows(t *testing.T) {
+	for _, answer := range []string{"a", "Always", "e {}", "edit {}"} {
+		var out bytes.Buffer
+		// The app isn't needed, as answers that aren't offered are refused before reaching it.
+		c := &chat{out: &out, pendingApprovalID: "1"}
+		if err := c.answerApproval(answer); err != nil {
+			t.Fatalf("%q: %s", answer, err)
+		}
+		if c.pendingApprovalID != "1" {
+			t.Errorf("%q: the request is no longer pending", answer)
+		}
+		if !strings.Contains(out.String(), "[y/n [reason]]") || strings.Contains(out.String(), "a(lways)") {
+			t.Errorf("%q: got %q, want only y and n offered", answer, out.String())
+		}
+	}
+
+	var out bytes.Buffer
+	c := &chat{out: &out, pendingApprovalID: "1"}
+	if err := c.answerApproval("maybe"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "Please answer [y/n [reason]]: "; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	out.Reset()
+	c.pendingCanApproveAlways = true
+	if err := c.answerApproval("maybe"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "Please answer [y/n [reason]/a(lways)/e(dit) <args json>]: "; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
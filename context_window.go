@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"cuttlefish/database"
+	"cuttlefish/llm"
+	"cuttlefish/tools"
+)
+
+const (
+	contextStatusFull       = "full"
+	contextStatusTrimmed    = "trimmed"
+	contextStatusSummarized = "summarized"
+)
+
+const summaryAuthor = "summary"
+
+// How much of a trimmed tool output is still sent to the model.
+const trimmedOutputLength = 200
+
+// The token counts are estimates, which can be too low for i.e. code and JSON,
+// so this share of the context window, in percent, is kept free to not go over it anyway.
+const contextSafetyMargin = 15
+
+const summarizationPrompt = `You are summarizing the beginning of a conversation between a user and an assistant that uses tools, so that the conversation can be continued with the summary in place of the original messages.
+Keep the user's goals and requests, the important facts and results from tool outputs, decisions that were made, and anything that's still unresolved.
+Write the summary in a concise, factual way, as a list of bullet points. Don't add anything that isn't in the conversation.`
+
+// fitMessagesIntoContext converts the messages to LLM messages which fit into the context window of the model.
+// When they don't, old tool outputs get trimmed first, then older turns get summarized,
+// and then the tool outputs of the current turn get trimmed too, except for the latest one, which the model is about to act on.
+// All of that is persisted on the messages, so that users can see what has been elided.
+func (a *App) fitMessagesIntoContext(ctx context.Context, conversationID int, llmClient llm.Client, model string, conversationSettings database.ConversationSetting, functionCalling bool, toolDefinitions []llm.ToolDefinition, maxTokens int) ([]llm.Message, error) {
+	contextWindow := conversationSettings.ContextWindow
+	if contextWindow <= 0 {
+		contextWindow = llm.ContextWindow(model)
+	}
+	budget := contextWindow*(100-contextSafetyMargin)/100 - maxTokens
+
+	for {
+		messages, err := a.activeBranchMessages(ctx, conversationID)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't list conversation messages: %w", err)
+		}
+		llmMessages, err := a.messagesToLLMMessages(conversationSettings, messages, functionCalling)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't convert messages to LLM messages: %w", err)
+		}
+		if llm.EstimateRequestTokens(llmMessages, toolDefinitions) <= budget {
+			return llmMessages, nil
+		}
+
+		// Messages of the current turn are only reduced once there's nothing left to reduce before it, as the model is still working with them.
+		currentTurnStart := lastUserMessageIndex(messages)
+
+		if toTrim := oldestUntrimmedToolOutput(messages[:currentTurnStart]); toTrim != nil {
+			if err := a.trimToolOutput(ctx, conversationID, toTrim); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if summarizeUntil := summarizationCutoff(messages[:currentTurnStart]); summarizeUntil != -1 {
+			if err := a.summarizeMessages(ctx, conversationID, llmClient, model, messages[:summarizeUntil+1]); err != nil {
+				return nil, fmt.Errorf("couldn't summarize older messages: %w", err)
+			}
+			a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
+			continue
+		}
+
+		// A long turn with many tool calls, i.e. an agent working through a task, can fill the context window on its own.
+		currentTurn := messages[currentTurnStart:]
+		if toTrim := oldestUntrimmedToolOutput(currentTurn[:latestToolOutputIndex(currentTurn)]); toTrim != nil {
+			if err := a.trimToolOutput(ctx, conversationID, toTrim); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// There's nothing more we can do, so we let the provider decide whether it fits.
+		return llmMessages, nil
+	}
+}
+
+func (a *App) trimToolOutput(ctx context.Context, conversationID int, message *database.Message) error {
+	if err := a.queries.SetMessageContextStatus(ctx, database.SetMessageContextStatusParams{
+		ContextStatus: contextStatusTrimmed,
+		ID:            message.ID,
+	}); err != nil {
+		return fmt.Errorf("couldn't mark tool output as trimmed: %w", err)
+	}
+	a.events.Emit(fmt.Sprintf("conversation-%d-updated", conversationID))
+	return nil
+}
+
+// latestToolOutputIndex returns the index of the latest tool output, or len(messages) if there's none.
+func latestToolOutputIndex(messages []database.Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if isToolOutput(messages[i]) {
+			return i
+		}
+	}
+	return len(messages)
+}
+
+func lastUserMessageIndex(messages []database.Message) int {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Author == "user" {
+			return i
+		}
+	}
+	return 0
+}
+
+func isToolOutput(message database.Message) bool {
+	return message.Author != "user" && message.Author != "assistant" && message.Author != summaryAuthor
+}
+
+func oldestUntrimmedToolOutput(messages []database.Message) *database.Message {
+	for i := range messages {
+		if isToolOutput(messages[i]) && messages[i].ContextStatus == contextStatusFull && len(messages[i].Content) > trimmedOutputLength {
+			return &messages[i]
+		}
+	}
+	return nil
+}
+
+// summarizationCutoff returns the index of the last message to summarize, or -1 if there's nothing to summarize.
+// We summarize roughly the older half of the not yet summarized turns, always ending right before a user message,
+// so that tool calls aren't separated from their outputs.
+func summarizationCutoff(messages []database.Message) int {
+	var turnStarts []int
+	for i, message := range messages {
+		if message.ContextStatus != contextStatusSummarized && message.Author == "user" {
+			turnStarts = append(turnStarts, i)
+		}
+	}
+	if len(turnStarts) == 0 {
+		return -1
+	}
+	if len(turnStarts) == 1 {
+		// A single old turn, which we can summarize entirely.
+		return len(messages) - 1
+	}
+	return turnStarts[(len(turnStarts)+1)/2] - 1
+}
+
+func (a *App) summarizeMessages(ctx context.Context, conversationID int, llmClient llm.Client, model string, messages []database.Message) error {
+	var transcript strings.Builder
+	for _, message := range messages {
+		if message.ContextStatus == contextStatusSummarized {
+			// Already covered by a previous summary.
+			continue
+		}
+		author := message.Author
+		if author == summaryAuthor {
+			author = "summary of the earlier conversation"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n", author, message.Content)
+		for _, toolCall := range message.ToolCalls {
+			args, err := json.Marshal(toolCall.Args)
+			if err != nil {
+				return fmt.Errorf("couldn't encode tool call arguments: %w", err)
+			}
+			fmt.Fprintf(&transcript, "(called tool `%s` with %s)\n", toolCall.Tool, string(args))
+		}
+		transcript.WriteString("\n")
+	}
+
+	stream, err := llmClient.CreateChatCompletionStream(ctx, llm.ChatCompletionRequest{
+		Model:       model,
+		MaxTokens:   500,
+		Temperature: 0,
+		Messages: []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: summarizationPrompt,
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: transcript.String(),
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create chat completion stream: %w", err)
+	}
+	defer stream.Close()
+	var summary strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("couldn't receive from chat completion stream: %w", err)
+		}
+		summary.WriteString(chunk.Content)
+	}
+	if strings.TrimSpace(summary.String()) == "" {
+		return fmt.Errorf("got an empty summary")
+	}
+
+	// The summary is only used once it's recorded what it summarizes, so that a failure in between can't lose any information.
+	summaryMessage, err := a.addMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversationID,
+		Content:        strings.TrimSpace(summary.String()),
+		Author:         summaryAuthor,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create summary message: %w", err)
+	}
+	if err := a.queries.CreateMessageSummary(ctx, database.CreateMessageSummaryParams{
+		MessageID:                summaryMessage.ID,
+		SummarizedUntilMessageID: messages[len(messages)-1].ID,
+	}); err != nil {
+		return fmt.Errorf("couldn't record what the summary summarizes: %w", err)
+	}
+	return nil
+}
+
+func trimToolOutput(content string) string {
+	if len(content) <= trimmedOutputLength {
+		return content
+	}
+	return tools.Truncate(content, trimmedOutputLength) + "\n[...the rest of this tool output has been elided to save context space]"
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTrimToolOutputKeepsRunes(t *testing.T) {
+	// Each of these takes 3 bytes, so the limit falls in the middle of one.
+	content := strings.Repeat("日", trimmedOutputLength)
+	trimmed := trimToolOutput(content)
+	if !utf8.ValidString(trimmed) {
+		t.Errorf("trimmed output isn't valid UTF-8: %q", trimmed)
+	}
+	if !strings.HasPrefix(trimmed, strings.Repeat("日", trimmedOutputLength/3)) {
+		t.Errorf("trimmed output %q doesn't keep as much as fits", trimmed)
+	}
+}
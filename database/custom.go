@@ -1,11 +1,55 @@
 package database
 
 type Settings struct {
-	OpenAIAPIKey string           `json:"openAiApiKey"`
-	Model        string           `json:"model"`
-	Terminal     TerminalSettings `json:"terminal"`
-	Search       SearchSettings   `json:"search"`
-	Python       PythonSettings   `json:"python"`
+	OpenAIAPIKey string            `json:"openAiApiKey"`
+	Provider     string            `json:"provider"`
+	Model        string            `json:"model"`
+	OpenAI       OpenAISettings    `json:"openAi"`
+	Anthropic    AnthropicSettings `json:"anthropic"`
+	Ollama       OllamaSettings    `json:"ollama"`
+	Terminal     TerminalSettings  `json:"terminal"`
+	Search       SearchSettings    `json:"search"`
+	Python       PythonSettings    `json:"python"`
+	SQL          SQLSettings       `json:"sql"`
+	DuckDB       DuckDBSettings    `json:"duckDb"`
+	Assistant    AssistantSettings `json:"assistant"`
+	Files        FilesSettings     `json:"files"`
+	MCP          MCPSettings       `json:"mcp"`
+	Approvals    ApprovalSettings  `json:"approvals"`
+}
+
+type OpenAISettings struct {
+	// BaseURL can point to any OpenAI-compatible server, i.e. llama.cpp.
+	BaseURL string `json:"baseUrl"`
+	// FunctionCalling enables native function calling for custom servers.
+	FunctionCalling bool `json:"functionCalling"`
+}
+
+type AnthropicSettings struct {
+	APIKey  string `json:"apiKey"`
+	BaseURL string `json:"baseUrl"`
+}
+
+type OllamaSettings struct {
+	BaseURL string `json:"baseUrl"`
+	// FunctionCalling enables native function calling, which only some local models support.
+	FunctionCalling bool `json:"functionCalling"`
+}
+
+type ApprovalSettings struct {
+	Rules []ApprovalRule `json:"rules"`
+}
+
+// ApprovalRule approves or denies matching tool calls without asking, or makes them need approval.
+// Patterns match whole values, with * matching anything, and \ escaping a * or \.
+type ApprovalRule struct {
+	// Tool is a pattern for the IDs of the tools the rule applies to.
+	Tool string `json:"tool"`
+	// Arguments maps argument names to patterns their values have to match.
+	// Values which aren't strings are matched as JSON.
+	Arguments map[string]string `json:"arguments"`
+	// Decision is one of allow, deny or prompt.
+	Decision string `json:"decision"`
 }
 
 type TerminalSettings struct {
@@ -24,3 +68,55 @@ type GoogleCustomSearchSettings struct {
 type PythonSettings struct {
 	InterpreterPath string `json:"interpreterPath"`
 }
+
+type SQLSettings struct {
+	Databases []SQLDatabase `json:"databases"`
+	// MaxRows limits how many rows of a query result are shown to the Assistant.
+	MaxRows int `json:"maxRows"`
+}
+
+type SQLDatabase struct {
+	Name string `json:"name"`
+	// Driver is one of sqlite, postgres or mysql.
+	Driver string `json:"driver"`
+	DSN    string `json:"dsn"`
+}
+
+type DuckDBSettings struct {
+	ExecutablePath string `json:"executablePath"`
+	// Directories limits which data files can be queried.
+	Directories []string `json:"directories"`
+	// MaxRows limits how many rows of a query result are shown to the Assistant.
+	MaxRows int `json:"maxRows"`
+}
+
+type AssistantSettings struct {
+	// MaxDepth limits how deeply sub-conversations can be nested.
+	MaxDepth int `json:"maxDepth"`
+	// MaxSteps limits how many responses a sub-conversation can take to come to a final answer.
+	MaxSteps int `json:"maxSteps"`
+}
+
+type FilesSettings struct {
+	// WorkspacesDirectory holds a workspace per conversation, it defaults to ~/.cuttlefish/workspaces.
+	WorkspacesDirectory string `json:"workspacesDirectory"`
+}
+
+type MCPSettings struct {
+	Servers []MCPServer `json:"servers"`
+}
+
+// MCPServer is a Model Context Protocol server, whose tools are made available to the Assistant.
+// It's either started with Command and talked to over stdio, or reached at URL using the streamable HTTP transport.
+type MCPServer struct {
+	Name string `json:"name"`
+	// Command is split like a shell would, i.e. `npx -y @modelcontextprotocol/server-memory`.
+	Command string `json:"command"`
+	// Env holds additional environment variables for the command, as KEY=value.
+	Env []string `json:"env"`
+	// URL is used instead of Command for servers using the streamable HTTP transport.
+	URL string `json:"url"`
+	// Headers are sent along with each request to the URL, as Name: value, i.e. for authorization.
+	Headers         []string `json:"headers"`
+	RequireApproval bool     `json:"requireApproval"`
+}
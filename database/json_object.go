@@ -0,0 +1,31 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type JSONObject map[string]interface{}
+
+func (o JSONObject) Value() (driver.Value, error) {
+	if o == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (o *JSONObject) Scan(src any) error {
+	if src == nil {
+		return nil
+	}
+	data, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("JSONObject not encoded as a String")
+	}
+	return json.Unmarshal([]byte(data), o)
+}
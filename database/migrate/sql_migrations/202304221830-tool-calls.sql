@@ -0,0 +1,2 @@
+ALTER TABLE messages ADD COLUMN tool_calls TOOL_CALL_ARRAY NOT NULL DEFAULT '[]'; -- Set on assistant messages that use native function calling.
+ALTER TABLE messages ADD COLUMN tool_call_id TEXT NOT NULL DEFAULT ''; -- Set on tool messages that respond to a native function call.
@@ -0,0 +1,2 @@
+ALTER TABLE conversation_settings ADD COLUMN provider TEXT NOT NULL DEFAULT ''; -- Empty means the provider from the app settings.
+ALTER TABLE conversation_settings ADD COLUMN model TEXT NOT NULL DEFAULT ''; -- Empty means the model from the app settings.
@@ -0,0 +1 @@
+ALTER TABLE conversation_settings ADD COLUMN max_consecutive_tool_failures INTEGER NOT NULL DEFAULT 3; -- 0 means no limit.
@@ -0,0 +1,2 @@
+ALTER TABLE messages ADD COLUMN context_status TEXT NOT NULL DEFAULT 'full'; -- 'full', 'trimmed' (tool output elided), or 'summarized' (replaced by a summary message)
+ALTER TABLE conversation_settings ADD COLUMN context_window INTEGER NOT NULL DEFAULT 0; -- 0 means the default for the model.
@@ -0,0 +1,6 @@
+ALTER TABLE messages ADD COLUMN parent_message_id INTEGER REFERENCES messages (id) ON DELETE CASCADE; -- NULL for the first message of a conversation.
+ALTER TABLE conversations ADD COLUMN active_message_id INTEGER REFERENCES messages (id) ON DELETE SET NULL; -- The leaf of the currently shown branch.
+
+-- Existing conversations are linear, so each message's parent is the one preceding it.
+UPDATE messages SET parent_message_id = (SELECT MAX(previous.id) FROM messages previous WHERE previous.conversation_id = messages.conversation_id AND previous.id < messages.id);
+UPDATE conversations SET active_message_id = (SELECT MAX(messages.id) FROM messages WHERE messages.conversation_id = conversations.id);
@@ -0,0 +1,4 @@
+ALTER TABLE conversation_settings ADD COLUMN temperature REAL NOT NULL DEFAULT 0.7;
+ALTER TABLE conversation_settings ADD COLUMN max_tokens INTEGER NOT NULL DEFAULT 1024;
+ALTER TABLE conversation_settings ADD COLUMN top_p REAL NOT NULL DEFAULT 1;
+ALTER TABLE conversation_settings ADD COLUMN stop TEXT_ARRAY NOT NULL DEFAULT '[]'; -- Added to the stop sequences of the text-based tool protocol.
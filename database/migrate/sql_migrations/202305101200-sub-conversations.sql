@@ -0,0 +1 @@
+ALTER TABLE conversations ADD COLUMN parent_message_id INTEGER REFERENCES messages (id) ON DELETE CASCADE; -- Set for sub-conversations, pointing to the message whose tool call started them.
@@ -0,0 +1,20 @@
+-- An audit log of tool calls. The ids aren't foreign keys, so the log is kept when conversations or messages get deleted.
+CREATE TABLE IF NOT EXISTS tool_executions
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id INTEGER     NOT NULL,
+    message_id      INTEGER     NOT NULL, -- The message with the tool call.
+    tool_call_id    TEXT        NOT NULL,
+    tool            TEXT        NOT NULL,
+    args            JSON_OBJECT NOT NULL, -- As the tool ran with them, so edited if the user edited them.
+    approval        TEXT        NOT NULL, -- 'not_required', 'pending', 'allowed_by_rule', 'denied_by_rule', 'approved', 'approved_with_edits' or 'rejected'
+    approved_by     TEXT        NOT NULL, -- The user or the approval rule that decided, if any.
+    status          TEXT        NOT NULL, -- 'running', 'succeeded', 'failed', 'denied', 'rejected' or 'cancelled'
+    started_at      DATETIME    NOT NULL,
+    finished_at     DATETIME,
+    exit_code       INTEGER, -- Set by tools running processes, i.e. the terminal.
+    result          TEXT        NOT NULL,
+    output          TEXT        NOT NULL, -- Truncated.
+    error           TEXT        NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tool_executions_conversation_id ON tool_executions (conversation_id);
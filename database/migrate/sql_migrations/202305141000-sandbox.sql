@@ -0,0 +1 @@
+ALTER TABLE conversation_settings ADD COLUMN sandbox SANDBOX_SETTINGS NOT NULL DEFAULT '{}';
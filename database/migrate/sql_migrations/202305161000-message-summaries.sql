@@ -0,0 +1,18 @@
+-- Which message of its branch each summary message summarizes the conversation until.
+-- It's kept per summary, rather than as a status of the summarized messages, as those are shared with sibling branches, which may not have the summary.
+CREATE TABLE IF NOT EXISTS message_summaries
+(
+    message_id                  INTEGER PRIMARY KEY REFERENCES messages (id) ON DELETE CASCADE, -- The summary message.
+    summarized_until_message_id INTEGER NOT NULL REFERENCES messages (id) ON DELETE CASCADE
+);
+
+-- Existing summaries summarize until the latest message marked as summarized before them, which is as close as it gets without the branches.
+INSERT INTO message_summaries (message_id, summarized_until_message_id)
+SELECT summaries.id, MAX(summarized.id)
+FROM messages summaries
+         JOIN messages summarized ON summarized.conversation_id = summaries.conversation_id AND summarized.id < summaries.id
+WHERE summaries.author = 'summary'
+  AND summarized.author != 'summary'
+  AND summarized.context_status = 'summarized'
+GROUP BY summaries.id;
+UPDATE messages SET context_status = 'full' WHERE context_status = 'summarized';
@@ -10,18 +10,29 @@ import (
 )
 
 type Conversation struct {
-	ID                     int       `json:"id"`
-	ConversationSettingsID int       `json:"conversationSettingsID"`
-	Title                  string    `json:"title"`
-	LastMessageTime        time.Time `json:"lastMessageTime"`
-	Generating             bool      `json:"generating"`
+	ID                     int           `json:"id"`
+	ConversationSettingsID int           `json:"conversationSettingsID"`
+	Title                  string        `json:"title"`
+	LastMessageTime        time.Time     `json:"lastMessageTime"`
+	Generating             bool          `json:"generating"`
+	ActiveMessageID        sql.NullInt64 `json:"activeMessageID"`
+	ParentMessageID        sql.NullInt64 `json:"parentMessageID"`
 }
 
 type ConversationSetting struct {
-	ID                   int          `json:"id"`
-	IsDefault            sql.NullBool `json:"isDefault"`
-	SystemPromptTemplate string       `json:"systemPromptTemplate"`
-	ToolsEnabled         StringArray  `json:"toolsEnabled"`
+	ID                         int             `json:"id"`
+	IsDefault                  sql.NullBool    `json:"isDefault"`
+	SystemPromptTemplate       string          `json:"systemPromptTemplate"`
+	ToolsEnabled               StringArray     `json:"toolsEnabled"`
+	Provider                   string          `json:"provider"`
+	Model                      string          `json:"model"`
+	MaxConsecutiveToolFailures int             `json:"maxConsecutiveToolFailures"`
+	ContextWindow              int             `json:"contextWindow"`
+	Temperature                float64         `json:"temperature"`
+	MaxTokens                  int             `json:"maxTokens"`
+	TopP                       float64         `json:"topP"`
+	Stop                       StringArray     `json:"stop"`
+	Sandbox                    SandboxSettings `json:"sandbox"`
 }
 
 type ConversationTemplate struct {
@@ -36,8 +47,35 @@ type KeyValue struct {
 }
 
 type Message struct {
-	ID             int    `json:"id"`
-	ConversationID int    `json:"conversationID"`
-	Content        string `json:"content"`
-	Author         string `json:"author"`
+	ID              int           `json:"id"`
+	ConversationID  int           `json:"conversationID"`
+	Content         string        `json:"content"`
+	Author          string        `json:"author"`
+	ToolCalls       ToolCallArray `json:"toolCalls"`
+	ToolCallID      string        `json:"toolCallID"`
+	ContextStatus   string        `json:"contextStatus"`
+	ParentMessageID sql.NullInt64 `json:"parentMessageID"`
+}
+
+type MessageSummary struct {
+	MessageID                int `json:"messageID"`
+	SummarizedUntilMessageID int `json:"summarizedUntilMessageID"`
+}
+
+type ToolExecution struct {
+	ID             int           `json:"id"`
+	ConversationID int           `json:"conversationID"`
+	MessageID      int           `json:"messageID"`
+	ToolCallID     string        `json:"toolCallID"`
+	Tool           string        `json:"tool"`
+	Args           JSONObject    `json:"args"`
+	Approval       string        `json:"approval"`
+	ApprovedBy     string        `json:"approvedBy"`
+	Status         string        `json:"status"`
+	StartedAt      time.Time     `json:"startedAt"`
+	FinishedAt     sql.NullTime  `json:"finishedAt"`
+	ExitCode       sql.NullInt64 `json:"exitCode"`
+	Result         string        `json:"result"`
+	Output         string        `json:"output"`
+	Error          string        `json:"error"`
 }
@@ -4,22 +4,68 @@
 SELECT * FROM messages WHERE id = ?;
 
 -- name: ListMessages :many
-SELECT * FROM messages WHERE conversation_id = ? ORDER BY id;
+-- Lists the messages of the active branch. Parents always have lower ids than their children, so ordering by id orders the branch.
+WITH RECURSIVE branch(id) AS (
+    SELECT active_message_id FROM conversations WHERE conversations.id = ?
+    UNION ALL
+    SELECT messages.parent_message_id FROM messages JOIN branch ON messages.id = branch.id
+)
+SELECT messages.* FROM messages JOIN branch ON messages.id = branch.id ORDER BY messages.id;
+
+-- name: ListBranchMessages :many
+-- Lists the messages of the branch ending with the given message.
+WITH RECURSIVE branch(id) AS (
+    SELECT ?
+    UNION ALL
+    SELECT messages.parent_message_id FROM messages JOIN branch ON messages.id = branch.id
+)
+SELECT messages.* FROM messages JOIN branch ON messages.id = branch.id ORDER BY messages.id;
+
+-- name: ListConversationLeaves :many
+SELECT * FROM messages WHERE conversation_id = ? AND NOT EXISTS (SELECT 1 FROM messages children WHERE children.parent_message_id = messages.id) ORDER BY id;
+
+-- name: GetLatestDescendant :one
+-- Returns the most recent leaf below the given message, which is the descendant with the highest id.
+WITH RECURSIVE descendants(id) AS (
+    SELECT ?
+    UNION ALL
+    SELECT messages.id FROM messages JOIN descendants ON messages.parent_message_id = descendants.id
+)
+SELECT id FROM descendants ORDER BY id DESC LIMIT 1;
 
 -- name: CreateMessage :one
-INSERT INTO messages (conversation_id, content, author) VALUES (?, ?, ?) RETURNING *;
+INSERT INTO messages (conversation_id, content, author, tool_calls, tool_call_id, parent_message_id) VALUES (?, ?, ?, ?, ?, ?) RETURNING *;
 
 -- name: AppendMessage :one
 UPDATE messages SET content = content || ? WHERE id = ? RETURNING *;
 
+-- name: SetMessageContent :exec
+UPDATE messages SET content = ? WHERE id = ?;
+
+-- name: SetMessageContextStatus :exec
+UPDATE messages SET context_status = ? WHERE id = ?;
+
+-- name: CreateMessageSummary :exec
+INSERT INTO message_summaries (message_id, summarized_until_message_id) VALUES (?, ?);
+
+-- name: ListMessageSummaries :many
+SELECT message_summaries.* FROM message_summaries JOIN messages ON messages.id = message_summaries.message_id WHERE messages.conversation_id = ?;
+
+-- name: SetMessageToolCalls :one
+UPDATE messages SET tool_calls = ? WHERE id = ? RETURNING *;
+
 -- name: GetConversation :one
 SELECT * FROM conversations WHERE id = ?;
 
 -- name: ListConversations :many
-SELECT * FROM conversations ORDER BY last_message_time DESC;
+-- Sub-conversations are listed along with their parent conversation instead.
+SELECT * FROM conversations WHERE parent_message_id IS NULL ORDER BY last_message_time DESC;
+
+-- name: ListSubConversations :many
+SELECT conversations.* FROM conversations JOIN messages ON conversations.parent_message_id = messages.id WHERE messages.conversation_id = ? ORDER BY conversations.id;
 
 -- name: CreateConversation :one
-INSERT INTO conversations (conversation_settings_id, title, last_message_time) VALUES (?, ?, ?) RETURNING *;
+INSERT INTO conversations (conversation_settings_id, title, last_message_time, parent_message_id) VALUES (?, ?, ?, ?) RETURNING *;
 
 -- name: DeleteConversation :exec
 DELETE FROM conversations WHERE id = ?;
@@ -30,6 +76,9 @@ UPDATE conversations SET generating = true WHERE id = ?;
 -- name: MarkGenerationDone :exec
 UPDATE conversations SET generating = false WHERE id = ?;
 
+-- name: SetActiveMessage :exec
+UPDATE conversations SET active_message_id = ? WHERE id = ?;
+
 -- name: GetConversationSettings :one
 SELECT * FROM conversation_settings WHERE id = ?;
 
@@ -37,13 +86,13 @@ SELECT * FROM conversation_settings WHERE id = ?;
 SELECT * FROM conversation_settings WHERE is_default = true;
 
 -- name: CreateConversationSettings :one
-INSERT INTO conversation_settings (system_prompt_template, tools_enabled) VALUES (?, ?) RETURNING *;
+INSERT INTO conversation_settings (system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING *;
 
 -- name: UpdateConversationSettings :one
-UPDATE conversation_settings SET system_prompt_template = ?, tools_enabled = ? WHERE id = ? RETURNING *;
+UPDATE conversation_settings SET system_prompt_template = ?, tools_enabled = ?, provider = ?, model = ?, max_consecutive_tool_failures = ?, context_window = ?, temperature = ?, max_tokens = ?, top_p = ?, stop = ?, sandbox = ? WHERE id = ? RETURNING *;
 
 -- name: CreateDefaultConversationSettings :one
-INSERT INTO conversation_settings (system_prompt_template, tools_enabled, is_default) VALUES (?, ?, true) RETURNING *;
+INSERT INTO conversation_settings (system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox, is_default) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, true) RETURNING *;
 
 -- name: DeleteDefaultConversationSettings :exec
 DELETE FROM conversation_settings WHERE is_default = true;
@@ -62,10 +111,33 @@ INSERT INTO key_values (key, value) VALUES (?, ?);
 UPDATE key_values SET value = ? WHERE key = ?;
 
 -- name: CloneConversationSettings :one
-INSERT INTO conversation_settings(system_prompt_template, tools_enabled) SELECT system_prompt_template, tools_enabled FROM conversation_settings WHERE conversation_settings.id = ? RETURNING *;
+INSERT INTO conversation_settings(system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox) SELECT system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox FROM conversation_settings WHERE conversation_settings.id = ? RETURNING *;
 
 -- name: CreateConversationTemplate :one
 INSERT INTO conversation_templates(name, conversation_settings_id) VALUES (?, ?) RETURNING *;
 
--- name: ResetConversationFrom :exec
-DELETE FROM messages WHERE conversation_id = ? AND id > ?;
+-- name: GetConversationTemplate :one
+SELECT * FROM conversation_templates WHERE id = ?;
+
+-- name: ListConversationTemplates :many
+SELECT * FROM conversation_templates ORDER BY name;
+
+-- name: UpdateConversationTemplate :one
+UPDATE conversation_templates SET name = ? WHERE id = ? RETURNING *;
+
+-- name: DeleteConversationSettings :exec
+DELETE FROM conversation_settings WHERE id = ?;
+
+
+-- name: CreateToolExecution :one
+INSERT INTO tool_executions (conversation_id, message_id, tool_call_id, tool, args, approval, approved_by, status, started_at, result, output, error) VALUES (?, ?, ?, ?, ?, 'not_required', '', 'running', ?, '', '', '') RETURNING *;
+
+-- name: FinishToolExecution :exec
+UPDATE tool_executions SET args = ?, approval = ?, approved_by = ?, status = ?, finished_at = ?, exit_code = ?, result = ?, output = ?, error = ? WHERE id = ?;
+
+-- name: ListToolExecutions :many
+-- A negative limit lists all of them.
+SELECT * FROM tool_executions ORDER BY id DESC LIMIT ?;
+
+-- name: ListConversationToolExecutions :many
+SELECT * FROM tool_executions WHERE conversation_id = ? ORDER BY id;
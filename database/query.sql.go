@@ -7,11 +7,12 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 )
 
 const appendMessage = `-- name: AppendMessage :one
-UPDATE messages SET content = content || ? WHERE id = ? RETURNING id, conversation_id, content, author
+UPDATE messages SET content = content || ? WHERE id = ? RETURNING id, conversation_id, content, author, tool_calls, tool_call_id, context_status, parent_message_id
 `
 
 type AppendMessageParams struct {
@@ -27,12 +28,16 @@ func (q *Queries) AppendMessage(ctx context.Context, arg AppendMessageParams) (M
 		&i.ConversationID,
 		&i.Content,
 		&i.Author,
+		&i.ToolCalls,
+		&i.ToolCallID,
+		&i.ContextStatus,
+		&i.ParentMessageID,
 	)
 	return i, err
 }
 
 const cloneConversationSettings = `-- name: CloneConversationSettings :one
-INSERT INTO conversation_settings(system_prompt_template, tools_enabled) SELECT system_prompt_template, tools_enabled FROM conversation_settings WHERE conversation_settings.id = ? RETURNING id, is_default, system_prompt_template, tools_enabled
+INSERT INTO conversation_settings(system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox) SELECT system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox FROM conversation_settings WHERE conversation_settings.id = ? RETURNING id, is_default, system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox
 `
 
 func (q *Queries) CloneConversationSettings(ctx context.Context, id int) (ConversationSetting, error) {
@@ -43,22 +48,37 @@ func (q *Queries) CloneConversationSettings(ctx context.Context, id int) (Conver
 		&i.IsDefault,
 		&i.SystemPromptTemplate,
 		&i.ToolsEnabled,
+		&i.Provider,
+		&i.Model,
+		&i.MaxConsecutiveToolFailures,
+		&i.ContextWindow,
+		&i.Temperature,
+		&i.MaxTokens,
+		&i.TopP,
+		&i.Stop,
+		&i.Sandbox,
 	)
 	return i, err
 }
 
 const createConversation = `-- name: CreateConversation :one
-INSERT INTO conversations (conversation_settings_id, title, last_message_time) VALUES (?, ?, ?) RETURNING id, conversation_settings_id, title, last_message_time, generating
+INSERT INTO conversations (conversation_settings_id, title, last_message_time, parent_message_id) VALUES (?, ?, ?, ?) RETURNING id, conversation_settings_id, title, last_message_time, generating, active_message_id, parent_message_id
 `
 
 type CreateConversationParams struct {
-	ConversationSettingsID int       `json:"conversationSettingsID"`
-	Title                  string    `json:"title"`
-	LastMessageTime        time.Time `json:"lastMessageTime"`
+	ConversationSettingsID int           `json:"conversationSettingsID"`
+	Title                  string        `json:"title"`
+	LastMessageTime        time.Time     `json:"lastMessageTime"`
+	ParentMessageID        sql.NullInt64 `json:"parentMessageID"`
 }
 
 func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversationParams) (Conversation, error) {
-	row := q.db.QueryRowContext(ctx, createConversation, arg.ConversationSettingsID, arg.Title, arg.LastMessageTime)
+	row := q.db.QueryRowContext(ctx, createConversation,
+		arg.ConversationSettingsID,
+		arg.Title,
+		arg.LastMessageTime,
+		arg.ParentMessageID,
+	)
 	var i Conversation
 	err := row.Scan(
 		&i.ID,
@@ -66,27 +86,59 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 		&i.Title,
 		&i.LastMessageTime,
 		&i.Generating,
+		&i.ActiveMessageID,
+		&i.ParentMessageID,
 	)
 	return i, err
 }
 
 const createConversationSettings = `-- name: CreateConversationSettings :one
-INSERT INTO conversation_settings (system_prompt_template, tools_enabled) VALUES (?, ?) RETURNING id, is_default, system_prompt_template, tools_enabled
+INSERT INTO conversation_settings (system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id, is_default, system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox
 `
 
 type CreateConversationSettingsParams struct {
-	SystemPromptTemplate string      `json:"systemPromptTemplate"`
-	ToolsEnabled         StringArray `json:"toolsEnabled"`
+	SystemPromptTemplate       string          `json:"systemPromptTemplate"`
+	ToolsEnabled               StringArray     `json:"toolsEnabled"`
+	Provider                   string          `json:"provider"`
+	Model                      string          `json:"model"`
+	MaxConsecutiveToolFailures int             `json:"maxConsecutiveToolFailures"`
+	ContextWindow              int             `json:"contextWindow"`
+	Temperature                float64         `json:"temperature"`
+	MaxTokens                  int             `json:"maxTokens"`
+	TopP                       float64         `json:"topP"`
+	Stop                       StringArray     `json:"stop"`
+	Sandbox                    SandboxSettings `json:"sandbox"`
 }
 
 func (q *Queries) CreateConversationSettings(ctx context.Context, arg CreateConversationSettingsParams) (ConversationSetting, error) {
-	row := q.db.QueryRowContext(ctx, createConversationSettings, arg.SystemPromptTemplate, arg.ToolsEnabled)
+	row := q.db.QueryRowContext(ctx, createConversationSettings,
+		arg.SystemPromptTemplate,
+		arg.ToolsEnabled,
+		arg.Provider,
+		arg.Model,
+		arg.MaxConsecutiveToolFailures,
+		arg.ContextWindow,
+		arg.Temperature,
+		arg.MaxTokens,
+		arg.TopP,
+		arg.Stop,
+		arg.Sandbox,
+	)
 	var i ConversationSetting
 	err := row.Scan(
 		&i.ID,
 		&i.IsDefault,
 		&i.SystemPromptTemplate,
 		&i.ToolsEnabled,
+		&i.Provider,
+		&i.Model,
+		&i.MaxConsecutiveToolFailures,
+		&i.ContextWindow,
+		&i.Temperature,
+		&i.MaxTokens,
+		&i.TopP,
+		&i.Stop,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -108,22 +160,52 @@ func (q *Queries) CreateConversationTemplate(ctx context.Context, arg CreateConv
 }
 
 const createDefaultConversationSettings = `-- name: CreateDefaultConversationSettings :one
-INSERT INTO conversation_settings (system_prompt_template, tools_enabled, is_default) VALUES (?, ?, true) RETURNING id, is_default, system_prompt_template, tools_enabled
+INSERT INTO conversation_settings (system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox, is_default) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, true) RETURNING id, is_default, system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox
 `
 
 type CreateDefaultConversationSettingsParams struct {
-	SystemPromptTemplate string      `json:"systemPromptTemplate"`
-	ToolsEnabled         StringArray `json:"toolsEnabled"`
+	SystemPromptTemplate       string          `json:"systemPromptTemplate"`
+	ToolsEnabled               StringArray     `json:"toolsEnabled"`
+	Provider                   string          `json:"provider"`
+	Model                      string          `json:"model"`
+	MaxConsecutiveToolFailures int             `json:"maxConsecutiveToolFailures"`
+	ContextWindow              int             `json:"contextWindow"`
+	Temperature                float64         `json:"temperature"`
+	MaxTokens                  int             `json:"maxTokens"`
+	TopP                       float64         `json:"topP"`
+	Stop                       StringArray     `json:"stop"`
+	Sandbox                    SandboxSettings `json:"sandbox"`
 }
 
 func (q *Queries) CreateDefaultConversationSettings(ctx context.Context, arg CreateDefaultConversationSettingsParams) (ConversationSetting, error) {
-	row := q.db.QueryRowContext(ctx, createDefaultConversationSettings, arg.SystemPromptTemplate, arg.ToolsEnabled)
+	row := q.db.QueryRowContext(ctx, createDefaultConversationSettings,
+		arg.SystemPromptTemplate,
+		arg.ToolsEnabled,
+		arg.Provider,
+		arg.Model,
+		arg.MaxConsecutiveToolFailures,
+		arg.ContextWindow,
+		arg.Temperature,
+		arg.MaxTokens,
+		arg.TopP,
+		arg.Stop,
+		arg.Sandbox,
+	)
 	var i ConversationSetting
 	err := row.Scan(
 		&i.ID,
 		&i.IsDefault,
 		&i.SystemPromptTemplate,
 		&i.ToolsEnabled,
+		&i.Provider,
+		&i.Model,
+		&i.MaxConsecutiveToolFailures,
+		&i.ContextWindow,
+		&i.Temperature,
+		&i.MaxTokens,
+		&i.TopP,
+		&i.Stop,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -143,23 +225,94 @@ func (q *Queries) CreateKeyValue(ctx context.Context, arg CreateKeyValueParams)
 }
 
 const createMessage = `-- name: CreateMessage :one
-INSERT INTO messages (conversation_id, content, author) VALUES (?, ?, ?) RETURNING id, conversation_id, content, author
+INSERT INTO messages (conversation_id, content, author, tool_calls, tool_call_id, parent_message_id) VALUES (?, ?, ?, ?, ?, ?) RETURNING id, conversation_id, content, author, tool_calls, tool_call_id, context_status, parent_message_id
 `
 
 type CreateMessageParams struct {
-	ConversationID int    `json:"conversationID"`
-	Content        string `json:"content"`
-	Author         string `json:"author"`
+	ConversationID  int           `json:"conversationID"`
+	Content         string        `json:"content"`
+	Author          string        `json:"author"`
+	ToolCalls       ToolCallArray `json:"toolCalls"`
+	ToolCallID      string        `json:"toolCallID"`
+	ParentMessageID sql.NullInt64 `json:"parentMessageID"`
 }
 
 func (q *Queries) CreateMessage(ctx context.Context, arg CreateMessageParams) (Message, error) {
-	row := q.db.QueryRowContext(ctx, createMessage, arg.ConversationID, arg.Content, arg.Author)
+	row := q.db.QueryRowContext(ctx, createMessage,
+		arg.ConversationID,
+		arg.Content,
+		arg.Author,
+		arg.ToolCalls,
+		arg.ToolCallID,
+		arg.ParentMessageID,
+	)
 	var i Message
 	err := row.Scan(
 		&i.ID,
 		&i.ConversationID,
 		&i.Content,
 		&i.Author,
+		&i.ToolCalls,
+		&i.ToolCallID,
+		&i.ContextStatus,
+		&i.ParentMessageID,
+	)
+	return i, err
+}
+
+const createMessageSummary = `-- name: CreateMessageSummary :exec
+INSERT INTO message_summaries (message_id, summarized_until_message_id) VALUES (?, ?)
+`
+
+type CreateMessageSummaryParams struct {
+	MessageID                int `json:"messageID"`
+	SummarizedUntilMessageID int `json:"summarizedUntilMessageID"`
+}
+
+func (q *Queries) CreateMessageSummary(ctx context.Context, arg CreateMessageSummaryParams) error {
+	_, err := q.db.ExecContext(ctx, createMessageSummary, arg.MessageID, arg.SummarizedUntilMessageID)
+	return err
+}
+
+const createToolExecution = `-- name: CreateToolExecution :one
+INSERT INTO tool_executions (conversation_id, message_id, tool_call_id, tool, args, approval, approved_by, status, started_at, result, output, error) VALUES (?, ?, ?, ?, ?, 'not_required', '', 'running', ?, '', '', '') RETURNING id, conversation_id, message_id, tool_call_id, tool, args, approval, approved_by, status, started_at, finished_at, exit_code, result, output, error
+`
+
+type CreateToolExecutionParams struct {
+	ConversationID int        `json:"conversationID"`
+	MessageID      int        `json:"messageID"`
+	ToolCallID     string     `json:"toolCallID"`
+	Tool           string     `json:"tool"`
+	Args           JSONObject `json:"args"`
+	StartedAt      time.Time  `json:"startedAt"`
+}
+
+func (q *Queries) CreateToolExecution(ctx context.Context, arg CreateToolExecutionParams) (ToolExecution, error) {
+	row := q.db.QueryRowContext(ctx, createToolExecution,
+		arg.ConversationID,
+		arg.MessageID,
+		arg.ToolCallID,
+		arg.Tool,
+		arg.Args,
+		arg.StartedAt,
+	)
+	var i ToolExecution
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.MessageID,
+		&i.ToolCallID,
+		&i.Tool,
+		&i.Args,
+		&i.Approval,
+		&i.ApprovedBy,
+		&i.Status,
+		&i.StartedAt,
+		&i.FinishedAt,
+		&i.ExitCode,
+		&i.Result,
+		&i.Output,
+		&i.Error,
 	)
 	return i, err
 }
@@ -173,6 +326,15 @@ func (q *Queries) DeleteConversation(ctx context.Context, id int) error {
 	return err
 }
 
+const deleteConversationSettings = `-- name: DeleteConversationSettings :exec
+DELETE FROM conversation_settings WHERE id = ?
+`
+
+func (q *Queries) DeleteConversationSettings(ctx context.Context, id int) error {
+	_, err := q.db.ExecContext(ctx, deleteConversationSettings, id)
+	return err
+}
+
 const deleteDefaultConversationSettings = `-- name: DeleteDefaultConversationSettings :exec
 DELETE FROM conversation_settings WHERE is_default = true
 `
@@ -182,8 +344,41 @@ func (q *Queries) DeleteDefaultConversationSettings(ctx context.Context) error {
 	return err
 }
 
+const finishToolExecution = `-- name: FinishToolExecution :exec
+UPDATE tool_executions SET args = ?, approval = ?, approved_by = ?, status = ?, finished_at = ?, exit_code = ?, result = ?, output = ?, error = ? WHERE id = ?
+`
+
+type FinishToolExecutionParams struct {
+	Args       JSONObject    `json:"args"`
+	Approval   string        `json:"approval"`
+	ApprovedBy string        `json:"approvedBy"`
+	Status     string        `json:"status"`
+	FinishedAt sql.NullTime  `json:"finishedAt"`
+	ExitCode   sql.NullInt64 `json:"exitCode"`
+	Result     string        `json:"result"`
+	Output     string        `json:"output"`
+	Error      string        `json:"error"`
+	ID         int           `json:"id"`
+}
+
+func (q *Queries) FinishToolExecution(ctx context.Context, arg FinishToolExecutionParams) error {
+	_, err := q.db.ExecContext(ctx, finishToolExecution,
+		arg.Args,
+		arg.Approval,
+		arg.ApprovedBy,
+		arg.Status,
+		arg.FinishedAt,
+		arg.ExitCode,
+		arg.Result,
+		arg.Output,
+		arg.Error,
+		arg.ID,
+	)
+	return err
+}
+
 const getConversation = `-- name: GetConversation :one
-SELECT id, conversation_settings_id, title, last_message_time, generating FROM conversations WHERE id = ?
+SELECT id, conversation_settings_id, title, last_message_time, generating, active_message_id, parent_message_id FROM conversations WHERE id = ?
 `
 
 func (q *Queries) GetConversation(ctx context.Context, id int) (Conversation, error) {
@@ -195,12 +390,14 @@ func (q *Queries) GetConversation(ctx context.Context, id int) (Conversation, er
 		&i.Title,
 		&i.LastMessageTime,
 		&i.Generating,
+		&i.ActiveMessageID,
+		&i.ParentMessageID,
 	)
 	return i, err
 }
 
 const getConversationSettings = `-- name: GetConversationSettings :one
-SELECT id, is_default, system_prompt_template, tools_enabled FROM conversation_settings WHERE id = ?
+SELECT id, is_default, system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox FROM conversation_settings WHERE id = ?
 `
 
 func (q *Queries) GetConversationSettings(ctx context.Context, id int) (ConversationSetting, error) {
@@ -211,12 +408,32 @@ func (q *Queries) GetConversationSettings(ctx context.Context, id int) (Conversa
 		&i.IsDefault,
 		&i.SystemPromptTemplate,
 		&i.ToolsEnabled,
+		&i.Provider,
+		&i.Model,
+		&i.MaxConsecutiveToolFailures,
+		&i.ContextWindow,
+		&i.Temperature,
+		&i.MaxTokens,
+		&i.TopP,
+		&i.Stop,
+		&i.Sandbox,
 	)
 	return i, err
 }
 
+const getConversationTemplate = `-- name: GetConversationTemplate :one
+SELECT id, name, conversation_settings_id FROM conversation_templates WHERE id = ?
+`
+
+func (q *Queries) GetConversationTemplate(ctx context.Context, id int) (ConversationTemplate, error) {
+	row := q.db.QueryRowContext(ctx, getConversationTemplate, id)
+	var i ConversationTemplate
+	err := row.Scan(&i.ID, &i.Name, &i.ConversationSettingsID)
+	return i, err
+}
+
 const getDefaultConversationSettings = `-- name: GetDefaultConversationSettings :one
-SELECT id, is_default, system_prompt_template, tools_enabled FROM conversation_settings WHERE is_default = true
+SELECT id, is_default, system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox FROM conversation_settings WHERE is_default = true
 `
 
 func (q *Queries) GetDefaultConversationSettings(ctx context.Context) (ConversationSetting, error) {
@@ -227,6 +444,15 @@ func (q *Queries) GetDefaultConversationSettings(ctx context.Context) (Conversat
 		&i.IsDefault,
 		&i.SystemPromptTemplate,
 		&i.ToolsEnabled,
+		&i.Provider,
+		&i.Model,
+		&i.MaxConsecutiveToolFailures,
+		&i.ContextWindow,
+		&i.Temperature,
+		&i.MaxTokens,
+		&i.TopP,
+		&i.Stop,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -246,9 +472,25 @@ func (q *Queries) GetKeyValue(ctx context.Context, key string) (KeyValue, error)
 	return i, err
 }
 
+const getLatestDescendant = `-- name: GetLatestDescendant :one
+WITH RECURSIVE descendants(id) AS (
+    SELECT ?
+    UNION ALL
+    SELECT messages.id FROM messages JOIN descendants ON messages.parent_message_id = descendants.id
+)
+SELECT id FROM descendants ORDER BY id DESC LIMIT 1
+`
+
+// Returns the most recent leaf below the given message, which is the descendant with the highest id.
+func (q *Queries) GetLatestDescendant(ctx context.Context, id int) (int, error) {
+	row := q.db.QueryRowContext(ctx, getLatestDescendant, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
 const getMessage = `-- name: GetMessage :one
 
-SELECT id, conversation_id, content, author FROM messages WHERE id = ?
+SELECT id, conversation_id, content, author, tool_calls, tool_call_id, context_status, parent_message_id FROM messages WHERE id = ?
 `
 
 // TODO: Change all wildcards to explicit column lists.
@@ -260,12 +502,165 @@ func (q *Queries) GetMessage(ctx context.Context, id int) (Message, error) {
 		&i.ConversationID,
 		&i.Content,
 		&i.Author,
+		&i.ToolCalls,
+		&i.ToolCallID,
+		&i.ContextStatus,
+		&i.ParentMessageID,
 	)
 	return i, err
 }
 
+const listBranchMessages = `-- name: ListBranchMessages :many
+WITH RECURSIVE branch(id) AS (
+    SELECT ?
+    UNION ALL
+    SELECT messages.parent_message_id FROM messages JOIN branch ON messages.id = branch.id
+)
+SELECT messages.id, messages.conversation_id, messages.content, messages.author, messages.tool_calls, messages.tool_call_id, messages.context_status, messages.parent_message_id FROM messages JOIN branch ON messages.id = branch.id ORDER BY messages.id
+`
+
+// Lists the messages of the branch ending with the given message.
+func (q *Queries) ListBranchMessages(ctx context.Context, id int) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listBranchMessages, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.Content,
+			&i.Author,
+			&i.ToolCalls,
+			&i.ToolCallID,
+			&i.ContextStatus,
+			&i.ParentMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConversationLeaves = `-- name: ListConversationLeaves :many
+SELECT id, conversation_id, content, author, tool_calls, tool_call_id, context_status, parent_message_id FROM messages WHERE conversation_id = ? AND NOT EXISTS (SELECT 1 FROM messages children WHERE children.parent_message_id = messages.id) ORDER BY id
+`
+
+func (q *Queries) ListConversationLeaves(ctx context.Context, conversationID int) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listConversationLeaves, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Message{}
+	for rows.Next() {
+		var i Message
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.Content,
+			&i.Author,
+			&i.ToolCalls,
+			&i.ToolCallID,
+			&i.ContextStatus,
+			&i.ParentMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConversationToolExecutions = `-- name: ListConversationToolExecutions :many
+SELECT id, conversation_id, message_id, tool_call_id, tool, args, approval, approved_by, status, started_at, finished_at, exit_code, result, output, error FROM tool_executions WHERE conversation_id = ? ORDER BY id
+`
+
+func (q *Queries) ListConversationToolExecutions(ctx context.Context, conversationID int) ([]ToolExecution, error) {
+	rows, err := q.db.QueryContext(ctx, listConversationToolExecutions, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ToolExecution{}
+	for rows.Next() {
+		var i ToolExecution
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.MessageID,
+			&i.ToolCallID,
+			&i.Tool,
+			&i.Args,
+			&i.Approval,
+			&i.ApprovedBy,
+			&i.Status,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.ExitCode,
+			&i.Result,
+			&i.Output,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listConversationTemplates = `-- name: ListConversationTemplates :many
+SELECT id, name, conversation_settings_id FROM conversation_templates ORDER BY name
+`
+
+func (q *Queries) ListConversationTemplates(ctx context.Context) ([]ConversationTemplate, error) {
+	rows, err := q.db.QueryContext(ctx, listConversationTemplates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ConversationTemplate{}
+	for rows.Next() {
+		var i ConversationTemplate
+		if err := rows.Scan(&i.ID, &i.Name, &i.ConversationSettingsID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listConversations = `-- name: ListConversations :many
-SELECT id, conversation_settings_id, title, last_message_time, generating FROM conversations ORDER BY last_message_time DESC
+-- Sub-conversations are listed along with their parent conversation instead.
+SELECT id, conversation_settings_id, title, last_message_time, generating, active_message_id, parent_message_id FROM conversations WHERE parent_message_id IS NULL ORDER BY last_message_time DESC
 `
 
 func (q *Queries) ListConversations(ctx context.Context) ([]Conversation, error) {
@@ -283,6 +678,38 @@ func (q *Queries) ListConversations(ctx context.Context) ([]Conversation, error)
 			&i.Title,
 			&i.LastMessageTime,
 			&i.Generating,
+			&i.ActiveMessageID,
+			&i.ParentMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMessageSummaries = `-- name: ListMessageSummaries :many
+SELECT message_summaries.message_id, message_summaries.summarized_until_message_id FROM message_summaries JOIN messages ON messages.id = message_summaries.message_id WHERE messages.conversation_id = ?
+`
+
+func (q *Queries) ListMessageSummaries(ctx context.Context, conversationID int) ([]MessageSummary, error) {
+	rows, err := q.db.QueryContext(ctx, listMessageSummaries, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []MessageSummary{}
+	for rows.Next() {
+		var i MessageSummary
+		if err := rows.Scan(
+			&i.MessageID,
+			&i.SummarizedUntilMessageID,
 		); err != nil {
 			return nil, err
 		}
@@ -298,11 +725,17 @@ func (q *Queries) ListConversations(ctx context.Context) ([]Conversation, error)
 }
 
 const listMessages = `-- name: ListMessages :many
-SELECT id, conversation_id, content, author FROM messages WHERE conversation_id = ? ORDER BY id
+WITH RECURSIVE branch(id) AS (
+    SELECT active_message_id FROM conversations WHERE conversations.id = ?
+    UNION ALL
+    SELECT messages.parent_message_id FROM messages JOIN branch ON messages.id = branch.id
+)
+SELECT messages.id, messages.conversation_id, messages.content, messages.author, messages.tool_calls, messages.tool_call_id, messages.context_status, messages.parent_message_id FROM messages JOIN branch ON messages.id = branch.id ORDER BY messages.id
 `
 
-func (q *Queries) ListMessages(ctx context.Context, conversationID int) ([]Message, error) {
-	rows, err := q.db.QueryContext(ctx, listMessages, conversationID)
+// Lists the messages of the active branch. Parents always have lower ids than their children, so ordering by id orders the branch.
+func (q *Queries) ListMessages(ctx context.Context, id int) ([]Message, error) {
+	rows, err := q.db.QueryContext(ctx, listMessages, id)
 	if err != nil {
 		return nil, err
 	}
@@ -315,6 +748,89 @@ func (q *Queries) ListMessages(ctx context.Context, conversationID int) ([]Messa
 			&i.ConversationID,
 			&i.Content,
 			&i.Author,
+			&i.ToolCalls,
+			&i.ToolCallID,
+			&i.ContextStatus,
+			&i.ParentMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSubConversations = `-- name: ListSubConversations :many
+SELECT conversations.id, conversations.conversation_settings_id, conversations.title, conversations.last_message_time, conversations.generating, conversations.active_message_id, conversations.parent_message_id FROM conversations JOIN messages ON conversations.parent_message_id = messages.id WHERE messages.conversation_id = ? ORDER BY conversations.id
+`
+
+func (q *Queries) ListSubConversations(ctx context.Context, conversationID int) ([]Conversation, error) {
+	rows, err := q.db.QueryContext(ctx, listSubConversations, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Conversation{}
+	for rows.Next() {
+		var i Conversation
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationSettingsID,
+			&i.Title,
+			&i.LastMessageTime,
+			&i.Generating,
+			&i.ActiveMessageID,
+			&i.ParentMessageID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listToolExecutions = `-- name: ListToolExecutions :many
+-- A negative limit lists all of them.
+SELECT id, conversation_id, message_id, tool_call_id, tool, args, approval, approved_by, status, started_at, finished_at, exit_code, result, output, error FROM tool_executions ORDER BY id DESC LIMIT ?
+`
+
+func (q *Queries) ListToolExecutions(ctx context.Context, limit int) ([]ToolExecution, error) {
+	rows, err := q.db.QueryContext(ctx, listToolExecutions, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ToolExecution{}
+	for rows.Next() {
+		var i ToolExecution
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.MessageID,
+			&i.ToolCallID,
+			&i.Tool,
+			&i.Args,
+			&i.Approval,
+			&i.ApprovedBy,
+			&i.Status,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.ExitCode,
+			&i.Result,
+			&i.Output,
+			&i.Error,
 		); err != nil {
 			return nil, err
 		}
@@ -347,42 +863,142 @@ func (q *Queries) MarkGenerationStarted(ctx context.Context, id int) error {
 	return err
 }
 
-const resetConversationFrom = `-- name: ResetConversationFrom :exec
-DELETE FROM messages WHERE conversation_id = ? AND id > ?
+const setActiveMessage = `-- name: SetActiveMessage :exec
+UPDATE conversations SET active_message_id = ? WHERE id = ?
 `
 
-type ResetConversationFromParams struct {
-	ConversationID int `json:"conversationID"`
-	ID             int `json:"id"`
+type SetActiveMessageParams struct {
+	ActiveMessageID sql.NullInt64 `json:"activeMessageID"`
+	ID              int           `json:"id"`
 }
 
-func (q *Queries) ResetConversationFrom(ctx context.Context, arg ResetConversationFromParams) error {
-	_, err := q.db.ExecContext(ctx, resetConversationFrom, arg.ConversationID, arg.ID)
+func (q *Queries) SetActiveMessage(ctx context.Context, arg SetActiveMessageParams) error {
+	_, err := q.db.ExecContext(ctx, setActiveMessage, arg.ActiveMessageID, arg.ID)
 	return err
 }
 
+const setMessageContent = `-- name: SetMessageContent :exec
+UPDATE messages SET content = ? WHERE id = ?
+`
+
+type SetMessageContentParams struct {
+	Content string `json:"content"`
+	ID      int    `json:"id"`
+}
+
+func (q *Queries) SetMessageContent(ctx context.Context, arg SetMessageContentParams) error {
+	_, err := q.db.ExecContext(ctx, setMessageContent, arg.Content, arg.ID)
+	return err
+}
+
+const setMessageContextStatus = `-- name: SetMessageContextStatus :exec
+UPDATE messages SET context_status = ? WHERE id = ?
+`
+
+type SetMessageContextStatusParams struct {
+	ContextStatus string `json:"contextStatus"`
+	ID            int    `json:"id"`
+}
+
+func (q *Queries) SetMessageContextStatus(ctx context.Context, arg SetMessageContextStatusParams) error {
+	_, err := q.db.ExecContext(ctx, setMessageContextStatus, arg.ContextStatus, arg.ID)
+	return err
+}
+
+const setMessageToolCalls = `-- name: SetMessageToolCalls :one
+UPDATE messages SET tool_calls = ? WHERE id = ? RETURNING id, conversation_id, content, author, tool_calls, tool_call_id, context_status, parent_message_id
+`
+
+type SetMessageToolCallsParams struct {
+	ToolCalls ToolCallArray `json:"toolCalls"`
+	ID        int           `json:"id"`
+}
+
+func (q *Queries) SetMessageToolCalls(ctx context.Context, arg SetMessageToolCallsParams) (Message, error) {
+	row := q.db.QueryRowContext(ctx, setMessageToolCalls, arg.ToolCalls, arg.ID)
+	var i Message
+	err := row.Scan(
+		&i.ID,
+		&i.ConversationID,
+		&i.Content,
+		&i.Author,
+		&i.ToolCalls,
+		&i.ToolCallID,
+		&i.ContextStatus,
+		&i.ParentMessageID,
+	)
+	return i, err
+}
+
 const updateConversationSettings = `-- name: UpdateConversationSettings :one
-UPDATE conversation_settings SET system_prompt_template = ?, tools_enabled = ? WHERE id = ? RETURNING id, is_default, system_prompt_template, tools_enabled
+UPDATE conversation_settings SET system_prompt_template = ?, tools_enabled = ?, provider = ?, model = ?, max_consecutive_tool_failures = ?, context_window = ?, temperature = ?, max_tokens = ?, top_p = ?, stop = ?, sandbox = ? WHERE id = ? RETURNING id, is_default, system_prompt_template, tools_enabled, provider, model, max_consecutive_tool_failures, context_window, temperature, max_tokens, top_p, stop, sandbox
 `
 
 type UpdateConversationSettingsParams struct {
-	SystemPromptTemplate string      `json:"systemPromptTemplate"`
-	ToolsEnabled         StringArray `json:"toolsEnabled"`
-	ID                   int         `json:"id"`
+	SystemPromptTemplate       string          `json:"systemPromptTemplate"`
+	ToolsEnabled               StringArray     `json:"toolsEnabled"`
+	Provider                   string          `json:"provider"`
+	Model                      string          `json:"model"`
+	MaxConsecutiveToolFailures int             `json:"maxConsecutiveToolFailures"`
+	ContextWindow              int             `json:"contextWindow"`
+	Temperature                float64         `json:"temperature"`
+	MaxTokens                  int             `json:"maxTokens"`
+	TopP                       float64         `json:"topP"`
+	Stop                       StringArray     `json:"stop"`
+	Sandbox                    SandboxSettings `json:"sandbox"`
+	ID                         int             `json:"id"`
 }
 
 func (q *Queries) UpdateConversationSettings(ctx context.Context, arg UpdateConversationSettingsParams) (ConversationSetting, error) {
-	row := q.db.QueryRowContext(ctx, updateConversationSettings, arg.SystemPromptTemplate, arg.ToolsEnabled, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateConversationSettings,
+		arg.SystemPromptTemplate,
+		arg.ToolsEnabled,
+		arg.Provider,
+		arg.Model,
+		arg.MaxConsecutiveToolFailures,
+		arg.ContextWindow,
+		arg.Temperature,
+		arg.MaxTokens,
+		arg.TopP,
+		arg.Stop,
+		arg.Sandbox,
+		arg.ID,
+	)
 	var i ConversationSetting
 	err := row.Scan(
 		&i.ID,
 		&i.IsDefault,
 		&i.SystemPromptTemplate,
 		&i.ToolsEnabled,
+		&i.Provider,
+		&i.Model,
+		&i.MaxConsecutiveToolFailures,
+		&i.ContextWindow,
+		&i.Temperature,
+		&i.MaxTokens,
+		&i.TopP,
+		&i.Stop,
+		&i.Sandbox,
 	)
 	return i, err
 }
 
+const updateConversationTemplate = `-- name: UpdateConversationTemplate :one
+UPDATE conversation_templates SET name = ? WHERE id = ? RETURNING id, name, conversation_settings_id
+`
+
+type UpdateConversationTemplateParams struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+func (q *Queries) UpdateConversationTemplate(ctx context.Context, arg UpdateConversationTemplateParams) (ConversationTemplate, error) {
+	row := q.db.QueryRowContext(ctx, updateConversationTemplate, arg.Name, arg.ID)
+	var i ConversationTemplate
+	err := row.Scan(&i.ID, &i.Name, &i.ConversationSettingsID)
+	return i, err
+}
+
 const updateKeyValue = `-- name: UpdateKeyValue :exec
 UPDATE key_values SET value = ? WHERE key = ?
 `
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// SandboxSettings run the terminal and python tools of a conversation in a bubblewrap sandbox, which is Linux only.
+// The limits only apply within the sandbox, and 0 means no limit.
+type SandboxSettings struct {
+	Enabled bool `json:"enabled"`
+	// ReadOnlyMounts and ReadWriteMounts are host paths, mounted at the same path in the sandbox.
+	// The system directories are always mounted read-only, and the conversation's workspace read-write.
+	ReadOnlyMounts  []string `json:"readOnlyMounts"`
+	ReadWriteMounts []string `json:"readWriteMounts"`
+	Network         bool     `json:"network"`
+	// CPUSeconds limits the CPU time of each process, so for the python tool that's all cells run in the same interpreter.
+	CPUSeconds int `json:"cpuSeconds"`
+	// MemoryMB limits the memory of everything in the sandbox together, if systemd can put it in a scope of the user's session.
+	// Otherwise, it limits the address space of each process, which is larger than the memory the process uses.
+	MemoryMB int `json:"memoryMB"`
+	// TimeoutSeconds limits how long a single command or cell can run.
+	TimeoutSeconds int `json:"timeoutSeconds"`
+}
+
+func (s SandboxSettings) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (s *SandboxSettings) Scan(src any) error {
+	if src == nil {
+		return nil
+	}
+	data, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("SandboxSettings not encoded as a String")
+	}
+	return json.Unmarshal([]byte(data), s)
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+type ToolCall struct {
+	ID   string                 `json:"id"`
+	Tool string                 `json:"tool"`
+	Args map[string]interface{} `json:"args"`
+}
+
+type ToolCallArray []ToolCall
+
+func (s ToolCallArray) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (s *ToolCallArray) Scan(src any) error {
+	if src == nil {
+		return nil
+	}
+	data, ok := src.(string)
+	if !ok {
+		return fmt.Errorf("ToolCallArray not encoded as a String")
+	}
+	return json.Unmarshal([]byte(data), s)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// EventEmitter delivers events, like conversation-N-updated, to whatever frontend is driving the app.
+type EventEmitter interface {
+	Emit(eventName string, data ...interface{})
+}
+
+// WailsEventEmitter emits events to the desktop frontend.
+// Its context has to be set to the one Wails passes on startup.
+type WailsEventEmitter struct {
+	ctx context.Context
+}
+
+func (e *WailsEventEmitter) Emit(eventName string, data ...interface{}) {
+	if e.ctx == nil {
+		// The window isn't up yet, so there's nobody to notify.
+		return
+	}
+	runtime.EventsEmit(e.ctx, eventName, data...)
+}
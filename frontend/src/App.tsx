@@ -22,6 +22,8 @@ const App = () => {
     const [curConversationID, setCurConversationID] = useState<number | null>(
         null
     );
+    // The template new conversations get started from, if any.
+    const [templateID, setTemplateID] = useState<number | null>(null);
     const [errorMessage, setErrorMessage] = useState("");
 
     useEffect(() => {
@@ -42,8 +44,9 @@ const App = () => {
 
     return (
         <div className="flex h-screen overflow-hidden">
-            <Sidebar curConversationID={curConversationID} setCurConversationID={setCurConversationID}/>
-            <Chat conversationID={curConversationID} setConversationID={setCurConversationID}/>
+            <Sidebar curConversationID={curConversationID} setCurConversationID={setCurConversationID}
+                     templateID={templateID} setTemplateID={setTemplateID}/>
+            <Chat conversationID={curConversationID} setConversationID={setCurConversationID} templateID={templateID}/>
             <WelcomePopup/>
             <Transition
                 show={errorMessage != ""}
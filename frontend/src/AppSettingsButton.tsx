@@ -1,14 +1,62 @@
 import {Settings} from "iconoir-react";
 import React, {Fragment, useEffect, useState} from "react";
 import {Dialog, Listbox, Switch, Transition} from "@headlessui/react";
-import {GetSettings, SaveSettings} from "../wailsjs/go/main/App";
-import {database} from "../wailsjs/go/models";
+import {GetAvailableProviders, GetSettings, SaveSettings} from "../wailsjs/go/main/App";
+import {database, main} from "../wailsjs/go/models";
 import {BrowserOpenURL} from "../wailsjs/runtime";
 
 interface Props {
     className?: string;
 }
 
+// The form for an MCP server, which has either a command with environment variables, or a url with headers.
+interface MCPServerForm {
+    name: string;
+    transport: "stdio" | "http";
+    target: string;
+    secrets: string;
+    requireApproval: boolean;
+}
+
+const toMCPServerForm = (server: database.MCPServer): MCPServerForm => ({
+    name: server.name,
+    transport: server.url ? "http" : "stdio",
+    target: server.url || server.command || "",
+    secrets: ((server.url ? server.headers : server.env) || []).join("\n"),
+    requireApproval: server.requireApproval,
+});
+
+const fromMCPServerForm = (form: MCPServerForm): database.MCPServer => {
+    const secrets = form.secrets.split("\n").map((line) => line.trim()).filter((line) => line !== "");
+    return {
+        name: form.name,
+        command: form.transport === "stdio" ? form.target : "",
+        env: form.transport === "stdio" ? secrets : [],
+        url: form.transport === "http" ? form.target : "",
+        headers: form.transport === "http" ? secrets : [],
+        requireApproval: form.requireApproval,
+    };
+};
+
+// The form for an approval rule, with its arguments as a list, so they keep their order while being edited.
+interface ApprovalRuleForm {
+    tool: string;
+    decision: string;
+    arguments: {name: string, pattern: string}[];
+}
+
+const toApprovalRuleForm = (rule: database.ApprovalRule): ApprovalRuleForm => ({
+    tool: rule.tool,
+    decision: rule.decision,
+    arguments: Object.entries(rule.arguments || {}).map(([name, pattern]) => ({name, pattern})),
+});
+
+const fromApprovalRuleForm = (form: ApprovalRuleForm): database.ApprovalRule => ({
+    tool: form.tool,
+    decision: form.decision,
+    arguments: Object.fromEntries(form.arguments.filter((arg) => arg.name.trim() !== "").map((arg) => [arg.name.trim(), arg.pattern])),
+});
+
 const AppSettingsButton = ({className}: Props) => {
     const [isSettingsModalOpen, setIsSettingsModalOpen] = useState(false);
     const [settings, setSettings] = useState<database.Settings>();
@@ -16,19 +64,56 @@ const AppSettingsButton = ({className}: Props) => {
     const [terminalRequireApproval, setTerminalRequireApproval] = useState(false);
     const [googleCloudApiKey, setGoogleCloudApiKey] = useState("");
     const [customSearchEngineId, setCustomSearchEngineId] = useState("");
+    const [availableProviders, setAvailableProviders] = useState<main.AvailableProvider[]>([]);
+    const [provider, setProvider] = useState("openai");
     const [model, setModel] = useState("gpt-3.5-turbo");
+    const [openAiBaseUrl, setOpenAiBaseUrl] = useState("");
+    const [anthropicApiKey, setAnthropicApiKey] = useState("");
+    const [ollamaBaseUrl, setOllamaBaseUrl] = useState("");
+    const [ollamaFunctionCalling, setOllamaFunctionCalling] = useState(false);
     const [pythonInterpreterPath, setPythonInterpreterPath] = useState("");
+    const [sqlDatabases, setSqlDatabases] = useState<database.SQLDatabase[]>([]);
+    const [sqlMaxRows, setSqlMaxRows] = useState(100);
+    const [duckDbExecutablePath, setDuckDbExecutablePath] = useState("duckdb");
+    const [duckDbDirectories, setDuckDbDirectories] = useState("");
+    const [duckDbMaxRows, setDuckDbMaxRows] = useState(200);
+    const [assistantMaxDepth, setAssistantMaxDepth] = useState(2);
+    const [assistantMaxSteps, setAssistantMaxSteps] = useState(10);
+    const [filesWorkspacesDirectory, setFilesWorkspacesDirectory] = useState("");
+    const [mcpServers, setMcpServers] = useState<MCPServerForm[]>([]);
+    const [approvalRules, setApprovalRules] = useState<ApprovalRuleForm[]>([]);
     const [changed, setChanged] = useState(false);
 
+    useEffect(() => {
+        GetAvailableProviders().then((providers) => {
+            setAvailableProviders(providers);
+        });
+    }, []);
+
     useEffect(() => {
         GetSettings().then((curSettings) => {
             setSettings(curSettings);
             setOpenAiApiKey(curSettings.openAiApiKey);
             setTerminalRequireApproval(curSettings.terminal.requireApproval);
+            setProvider(curSettings.provider || "openai");
             setModel(curSettings.model);
+            setOpenAiBaseUrl(curSettings.openAi?.baseUrl || "");
+            setAnthropicApiKey(curSettings.anthropic?.apiKey || "");
+            setOllamaBaseUrl(curSettings.ollama?.baseUrl || "");
+            setOllamaFunctionCalling(curSettings.ollama?.functionCalling || false);
             setGoogleCloudApiKey(curSettings.search.googleCustomSearch.googleCloudApiKey);
             setCustomSearchEngineId(curSettings.search.googleCustomSearch.customSearchEngineId);
             setPythonInterpreterPath(curSettings.python.interpreterPath);
+            setSqlDatabases(curSettings.sql?.databases || []);
+            setSqlMaxRows(curSettings.sql?.maxRows || 100);
+            setDuckDbExecutablePath(curSettings.duckDb?.executablePath || "duckdb");
+            setDuckDbDirectories((curSettings.duckDb?.directories || []).join("\n"));
+            setDuckDbMaxRows(curSettings.duckDb?.maxRows || 200);
+            setAssistantMaxDepth(curSettings.assistant?.maxDepth || 2);
+            setAssistantMaxSteps(curSettings.assistant?.maxSteps || 10);
+            setFilesWorkspacesDirectory(curSettings.files?.workspacesDirectory || "");
+            setMcpServers((curSettings.mcp?.servers || []).map(toMCPServerForm));
+            setApprovalRules((curSettings.approvals?.rules || []).map(toApprovalRuleForm));
         });
     }, [isSettingsModalOpen]);
 
@@ -38,18 +123,61 @@ const AppSettingsButton = ({className}: Props) => {
         }
         setChanged(
             openAiApiKey !== settings.openAiApiKey
+            || provider !== (settings.provider || "openai")
             || model !== settings.model
+            || openAiBaseUrl !== (settings.openAi?.baseUrl || "")
+            || anthropicApiKey !== (settings.anthropic?.apiKey || "")
+            || ollamaBaseUrl !== (settings.ollama?.baseUrl || "")
+            || ollamaFunctionCalling !== (settings.ollama?.functionCalling || false)
             || terminalRequireApproval !== settings.terminal.requireApproval
             || googleCloudApiKey !== settings.search.googleCustomSearch.googleCloudApiKey
             || customSearchEngineId !== settings.search.googleCustomSearch.customSearchEngineId
             || pythonInterpreterPath !== settings.python.interpreterPath
+            || JSON.stringify(sqlDatabases) !== JSON.stringify(settings.sql?.databases || [])
+            || sqlMaxRows !== (settings.sql?.maxRows || 100)
+            || duckDbExecutablePath !== (settings.duckDb?.executablePath || "duckdb")
+            || duckDbDirectories !== (settings.duckDb?.directories || []).join("\n")
+            || duckDbMaxRows !== (settings.duckDb?.maxRows || 200)
+            || assistantMaxDepth !== (settings.assistant?.maxDepth || 2)
+            || assistantMaxSteps !== (settings.assistant?.maxSteps || 10)
+            || filesWorkspacesDirectory !== (settings.files?.workspacesDirectory || "")
+            || JSON.stringify(mcpServers.map(fromMCPServerForm)) !== JSON.stringify((settings.mcp?.servers || []).map(toMCPServerForm).map(fromMCPServerForm))
+            || JSON.stringify(approvalRules.map(fromApprovalRuleForm)) !== JSON.stringify((settings.approvals?.rules || []).map(toApprovalRuleForm).map(fromApprovalRuleForm))
         );
-    }, [settings, openAiApiKey, terminalRequireApproval, model, googleCloudApiKey, customSearchEngineId, pythonInterpreterPath])
+    }, [settings, openAiApiKey, terminalRequireApproval, provider, model, openAiBaseUrl, anthropicApiKey, ollamaBaseUrl, ollamaFunctionCalling, googleCloudApiKey, customSearchEngineId, pythonInterpreterPath, sqlDatabases, sqlMaxRows, duckDbExecutablePath, duckDbDirectories, duckDbMaxRows, assistantMaxDepth, assistantMaxSteps, filesWorkspacesDirectory, mcpServers, approvalRules])
+
+    const updateSqlDatabase = (index: number, update: Partial<database.SQLDatabase>) => {
+        setSqlDatabases(sqlDatabases.map((db, i) => i === index ? {...db, ...update} : db));
+    }
+
+    const updateMcpServer = (index: number, update: Partial<MCPServerForm>) => {
+        setMcpServers(mcpServers.map((server, i) => i === index ? {...server, ...update} : server));
+    }
+
+    const updateApprovalRule = (index: number, update: Partial<ApprovalRuleForm>) => {
+        setApprovalRules(approvalRules.map((rule, i) => i === index ? {...rule, ...update} : rule));
+    }
 
     const saveSettings = async () => {
         const newSettings = await SaveSettings({
+            // Keep settings which aren't editable here.
+            ...settings,
             openAiApiKey: openAiApiKey,
+            provider: provider,
             model: model,
+            openAi: {
+                ...settings?.openAi,
+                baseUrl: openAiBaseUrl,
+            },
+            anthropic: {
+                ...settings?.anthropic,
+                apiKey: anthropicApiKey,
+            },
+            ollama: {
+                ...settings?.ollama,
+                baseUrl: ollamaBaseUrl,
+                functionCalling: ollamaFunctionCalling,
+            },
             search: {
                 googleCustomSearch: {
                     googleCloudApiKey: googleCloudApiKey,
@@ -61,6 +189,28 @@ const AppSettingsButton = ({className}: Props) => {
             },
             python: {
                 interpreterPath: pythonInterpreterPath,
+            },
+            sql: {
+                databases: sqlDatabases,
+                maxRows: sqlMaxRows,
+            },
+            duckDb: {
+                executablePath: duckDbExecutablePath,
+                directories: duckDbDirectories.split("\n").map((dir) => dir.trim()).filter((dir) => dir !== ""),
+                maxRows: duckDbMaxRows,
+            },
+            assistant: {
+                maxDepth: assistantMaxDepth,
+                maxSteps: assistantMaxSteps,
+            },
+            files: {
+                workspacesDirectory: filesWorkspacesDirectory,
+            },
+            mcp: {
+                servers: mcpServers.map(fromMCPServerForm),
+            },
+            approvals: {
+                rules: approvalRules.map(fromApprovalRuleForm),
             }
         } as database.Settings);
         setSettings(newSettings);
@@ -109,22 +259,22 @@ const AppSettingsButton = ({className}: Props) => {
                                            className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
                                 </div>
                                 <div className="flex items-center justify-between p-2">
-                                    <p className="text-gray-400">Model</p>
+                                    <p className="text-gray-400">Provider</p>
                                     <div className="w-1/3 max-w-xs">
-                                        <Listbox value={model} onChange={setModel}>
+                                        <Listbox value={provider} onChange={setProvider}>
                                             <div className="relative">
                                                 <Listbox.Button
-                                                    className="duration-150 cursor-default relative w-full border border-gray-300 border-opacity-50 rounded-md bg-gray-700 text-gray-300 pl-3 py-1.5 text-left hover:bg-gray-600">{model}</Listbox.Button>
+                                                    className="duration-150 cursor-default relative w-full border border-gray-300 border-opacity-50 rounded-md bg-gray-700 text-gray-300 pl-3 py-1.5 text-left hover:bg-gray-600">{availableProviders.find((p) => p.ID === provider)?.name || provider}</Listbox.Button>
                                                 <Listbox.Options
                                                     className="bg-gray-700 absolute mt-1 w-full rounded-md bg-white shadow-lg max-h-60 rounded-md z-40 divide-y divide-gray-600">
-                                                    {["gpt-3.5-turbo", "gpt-4"].map((model) => (
+                                                    {availableProviders.map((provider) => (
                                                         <Listbox.Option
-                                                            key={model}
-                                                            value={model}
+                                                            key={provider.ID}
+                                                            value={provider.ID}
                                                             className="duration-150 text-gray-300 cursor-default pl-4 py-2 rounded-md hover:bg-gray-600"
                                                         >
                                                     <span className="block truncate">
-                                                        {model}
+                                                        {provider.name}
                                                     </span>
                                                         </Listbox.Option>
                                                     ))}
@@ -133,6 +283,62 @@ const AppSettingsButton = ({className}: Props) => {
                                         </Listbox>
                                     </div>
                                 </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Model</p>
+                                    <input type="text"
+                                           value={model}
+                                           list="known-models"
+                                           onChange={(event) => setModel(event.target.value)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                    <datalist id="known-models">
+                                        {["gpt-3.5-turbo", "gpt-4", "claude-3-5-sonnet-latest", "llama3.1"].map((model) => (
+                                            <option key={model} value={model}/>
+                                        ))}
+                                    </datalist>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">OpenAI-Compatible Server URL (optional, i.e. llama.cpp)</p>
+                                    <input type="text"
+                                           value={openAiBaseUrl}
+                                           onChange={(event) => setOpenAiBaseUrl(event.target.value)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Anthropic API Key</p>
+                                    <input type="password"
+                                           value={anthropicApiKey}
+                                           onChange={(event) => setAnthropicApiKey(event.target.value)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="p-2">
+                                    <h2 className="text-md font-bold text-gray-400 mb-2">Ollama</h2>
+                                    <div className="flex flex-col">
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <p className="text-gray-400">Server URL</p>
+                                            <input type="text"
+                                                   value={ollamaBaseUrl}
+                                                   placeholder="http://localhost:11434"
+                                                   onChange={(event) => setOllamaBaseUrl(event.target.value)}
+                                                   className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                        </div>
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <p className="text-gray-400">Native Function Calling</p>
+                                            <Switch
+                                                checked={ollamaFunctionCalling}
+                                                onChange={(newValue) => setOllamaFunctionCalling(newValue)}
+                                                className={`${
+                                                    ollamaFunctionCalling ? 'bg-gray-400' : 'bg-gray-700'
+                                                } relative inline-flex h-6 w-11 items-center rounded-full border border-gray-300 border-opacity-50`}
+                                            >
+                                                <span
+                                                    className={`${
+                                                        ollamaFunctionCalling ? 'translate-x-6' : 'translate-x-1'
+                                                    } inline-block h-4 w-4 transform rounded-full bg-gray-200 transition`}
+                                                />
+                                            </Switch>
+                                        </div>
+                                    </div>
+                                </div>
                                 <div className="p-2">
                                     <h2 className="text-md font-bold text-gray-400 mb-2">Terminal</h2>
                                     <div className="flex flex-col">
@@ -188,6 +394,243 @@ const AppSettingsButton = ({className}: Props) => {
                                         </div>
                                     </div>
                                 </div>
+                                <div className="p-2">
+                                    <h2 className="text-md font-bold text-gray-400 mb-2">SQL</h2>
+                                    <div className="flex flex-col">
+                                        {sqlDatabases.map((db, index) => (
+                                            <div key={index} className="flex items-center gap-2 px-2 py-1">
+                                                <input type="text"
+                                                       placeholder="Name"
+                                                       value={db.name}
+                                                       onChange={(event) => updateSqlDatabase(index, {name: event.target.value})}
+                                                       className="w-24 border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                                <select value={db.driver}
+                                                        onChange={(event) => updateSqlDatabase(index, {driver: event.target.value})}
+                                                        className="h-8 bg-gray-700 text-gray-300 rounded-md px-2">
+                                                    <option value="sqlite">SQLite</option>
+                                                    <option value="postgres">PostgreSQL</option>
+                                                    <option value="mysql">MySQL</option>
+                                                </select>
+                                                <input type="password"
+                                                       placeholder="DSN"
+                                                       value={db.dsn}
+                                                       onChange={(event) => updateSqlDatabase(index, {dsn: event.target.value})}
+                                                       className="flex-1 border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                                <button type="button"
+                                                        onClick={() => setSqlDatabases(sqlDatabases.filter((_, i) => i !== index))}
+                                                        className="text-gray-500 hover:text-gray-400">
+                                                    Remove
+                                                </button>
+                                            </div>
+                                        ))}
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <button type="button"
+                                                    onClick={() => setSqlDatabases([...sqlDatabases, {name: "", driver: "sqlite", dsn: ""}])}
+                                                    className="text-gray-400 hover:text-gray-300">
+                                                + Add database
+                                            </button>
+                                        </div>
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <p className="text-gray-400">Max Rows per Query Result</p>
+                                            <input type="number"
+                                                   min={1}
+                                                   value={sqlMaxRows}
+                                                   onChange={(event) => setSqlMaxRows(Number(event.target.value))}
+                                                   className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                        </div>
+                                    </div>
+                                </div>
+                                <div className="p-2">
+                                    <h2 className="text-md font-bold text-gray-400 mb-2">DuckDB</h2>
+                                    <div className="flex flex-col">
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <p className="text-gray-400">Path to DuckDB CLI</p>
+                                            <input type="text"
+                                                   value={duckDbExecutablePath}
+                                                   onChange={(event) => setDuckDbExecutablePath(event.target.value)}
+                                                   className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                        </div>
+                                        <div className="flex items-start justify-between px-2 py-1">
+                                            <p className="text-gray-400">Data Directories (one per line)</p>
+                                            <textarea
+                                                value={duckDbDirectories}
+                                                onChange={(event) => setDuckDbDirectories(event.target.value)}
+                                                rows={3}
+                                                className="border border-gray-300 border-opacity-50 p-2 bg-gray-700 text-gray-300 rounded-md"/>
+                                        </div>
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <p className="text-gray-400">Max Rows per Query Result</p>
+                                            <input type="number"
+                                                   min={1}
+                                                   value={duckDbMaxRows}
+                                                   onChange={(event) => setDuckDbMaxRows(Number(event.target.value))}
+                                                   className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                        </div>
+                                    </div>
+                                </div>
+                                <div className="p-2">
+                                    <h2 className="text-md font-bold text-gray-400 mb-2">Assistant</h2>
+                                    <div className="flex flex-col">
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <p className="text-gray-400">Max Sub-Conversation Depth</p>
+                                            <input type="number"
+                                                   min={1}
+                                                   value={assistantMaxDepth}
+                                                   onChange={(event) => setAssistantMaxDepth(Number(event.target.value))}
+                                                   className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                        </div>
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <p className="text-gray-400">Max Steps per Sub-Conversation</p>
+                                            <input type="number"
+                                                   min={1}
+                                                   value={assistantMaxSteps}
+                                                   onChange={(event) => setAssistantMaxSteps(Number(event.target.value))}
+                                                   className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                        </div>
+                                    </div>
+                                </div>
+                                <div className="p-2">
+                                    <h2 className="text-md font-bold text-gray-400 mb-2">Files</h2>
+                                    <div className="flex flex-col">
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <p className="text-gray-400">Workspaces Directory</p>
+                                            <input type="text"
+                                                   value={filesWorkspacesDirectory}
+                                                   placeholder="~/.cuttlefish/workspaces"
+                                                   onChange={(event) => setFilesWorkspacesDirectory(event.target.value)}
+                                                   className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                        </div>
+                                    </div>
+                                </div>
+                                <div className="p-2">
+                                    <h2 className="text-md font-bold text-gray-400 mb-2">MCP Servers</h2>
+                                    <div className="flex flex-col">
+                                        {mcpServers.map((server, index) => (
+                                            <div key={index} className="flex flex-col gap-1 px-2 py-1">
+                                                <div className="flex items-center gap-2">
+                                                    <input type="text"
+                                                           placeholder="Name"
+                                                           value={server.name}
+                                                           onChange={(event) => updateMcpServer(index, {name: event.target.value})}
+                                                           className="w-24 border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                                    <select value={server.transport}
+                                                            onChange={(event) => updateMcpServer(index, {transport: event.target.value as MCPServerForm["transport"]})}
+                                                            className="h-8 bg-gray-700 text-gray-300 rounded-md px-2">
+                                                        <option value="stdio">Command</option>
+                                                        <option value="http">URL</option>
+                                                    </select>
+                                                    <input type="text"
+                                                           placeholder={server.transport === "stdio" ? "npx -y @modelcontextprotocol/server-memory" : "https://example.com/mcp"}
+                                                           value={server.target}
+                                                           onChange={(event) => updateMcpServer(index, {target: event.target.value})}
+                                                           className="flex-1 border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                                    <button type="button"
+                                                            onClick={() => setMcpServers(mcpServers.filter((_, i) => i !== index))}
+                                                            className="text-gray-500 hover:text-gray-400">
+                                                        Remove
+                                                    </button>
+                                                </div>
+                                                <div className="flex items-start gap-2">
+                                                    <textarea
+                                                        placeholder={server.transport === "stdio" ? "Environment variables, one KEY=value per line" : "Headers, one Name: value per line"}
+                                                        value={server.secrets}
+                                                        onChange={(event) => updateMcpServer(index, {secrets: event.target.value})}
+                                                        rows={2}
+                                                        className="flex-1 border border-gray-300 border-opacity-50 p-2 bg-gray-700 text-gray-300 rounded-md"/>
+                                                    <p className="text-gray-400">Require Approval</p>
+                                                    <Switch
+                                                        checked={server.requireApproval}
+                                                        onChange={(newValue) => updateMcpServer(index, {requireApproval: newValue})}
+                                                        className={`${
+                                                            server.requireApproval ? 'bg-gray-400' : 'bg-gray-700'
+                                                        } relative inline-flex h-6 w-11 items-center rounded-full border border-gray-300 border-opacity-50`}
+                                                    >
+                                                        <span
+                                                            className={`${
+                                                                server.requireApproval ? 'translate-x-6' : 'translate-x-1'
+                                                            } inline-block h-4 w-4 transform rounded-full bg-gray-200 transition`}
+                                                        />
+                                                    </Switch>
+                                                </div>
+                                            </div>
+                                        ))}
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <button type="button"
+                                                    onClick={() => setMcpServers([...mcpServers, {name: "", transport: "stdio", target: "", secrets: "", requireApproval: true}])}
+                                                    className="text-gray-400 hover:text-gray-300">
+                                                + Add server
+                                            </button>
+                                        </div>
+                                    </div>
+                                </div>
+                                <div className="p-2">
+                                    <h2 className="text-md font-bold text-gray-400 mb-2">Approval Rules</h2>
+                                    <p className="text-gray-400 px-2 mb-1">
+                                        Allow, deny or prompt for tool calls matching a rule, where * matches anything.
+                                        Deny rules win over allow rules, which win over prompt rules.
+                                        Allow rules don't match chained commands, i.e. with ; or &&, unless their pattern has them too.
+                                        Calls without a matching rule ask for approval as their tool sees fit.
+                                    </p>
+                                    <div className="flex flex-col">
+                                        {approvalRules.map((rule, index) => (
+                                            <div key={index} className="flex flex-col gap-1 px-2 py-1">
+                                                <div className="flex items-center gap-2">
+                                                    <input type="text"
+                                                           placeholder="Tool, i.e. terminal or *"
+                                                           value={rule.tool}
+                                                           onChange={(event) => updateApprovalRule(index, {tool: event.target.value})}
+                                                           className="flex-1 border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                                    <select value={rule.decision}
+                                                            onChange={(event) => updateApprovalRule(index, {decision: event.target.value})}
+                                                            className="h-8 bg-gray-700 text-gray-300 rounded-md px-2">
+                                                        <option value="allow">Allow</option>
+                                                        <option value="deny">Deny</option>
+                                                        <option value="prompt">Prompt</option>
+                                                    </select>
+                                                    <button type="button"
+                                                            onClick={() => setApprovalRules(approvalRules.filter((_, i) => i !== index))}
+                                                            className="text-gray-500 hover:text-gray-400">
+                                                        Remove
+                                                    </button>
+                                                </div>
+                                                {rule.arguments.map((arg, argIndex) => (
+                                                    <div key={argIndex} className="flex items-start gap-2 pl-4">
+                                                        <input type="text"
+                                                               placeholder="Argument, i.e. command"
+                                                               value={arg.name}
+                                                               onChange={(event) => updateApprovalRule(index, {arguments: rule.arguments.map((a, i) => i === argIndex ? {...a, name: event.target.value} : a)})}
+                                                               className="w-32 border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                                        <textarea
+                                                            placeholder="Pattern, i.e. git status*"
+                                                            value={arg.pattern}
+                                                            onChange={(event) => updateApprovalRule(index, {arguments: rule.arguments.map((a, i) => i === argIndex ? {...a, pattern: event.target.value} : a)})}
+                                                            rows={1}
+                                                            className="flex-1 border border-gray-300 border-opacity-50 p-1 bg-gray-700 text-gray-300 rounded-md"/>
+                                                        <button type="button"
+                                                                onClick={() => updateApprovalRule(index, {arguments: rule.arguments.filter((_, i) => i !== argIndex)})}
+                                                                className="text-gray-500 hover:text-gray-400">
+                                                            Remove
+                                                        </button>
+                                                    </div>
+                                                ))}
+                                                <div className="pl-4">
+                                                    <button type="button"
+                                                            onClick={() => updateApprovalRule(index, {arguments: [...rule.arguments, {name: "", pattern: ""}]})}
+                                                            className="text-gray-400 hover:text-gray-300">
+                                                        + Add argument
+                                                    </button>
+                                                </div>
+                                            </div>
+                                        ))}
+                                        <div className="flex items-center justify-between px-2 py-1">
+                                            <button type="button"
+                                                    onClick={() => setApprovalRules([...approvalRules, {tool: "", decision: "allow", arguments: []}])}
+                                                    className="text-gray-400 hover:text-gray-300">
+                                                + Add rule
+                                            </button>
+                                        </div>
+                                    </div>
+                                </div>
                             </div>
                             <div className="flex justify-end">
                                 <button
@@ -0,0 +1,109 @@
+import React, {useState} from "react";
+import {Approve, ApproveAlways, ApproveWithEdits, Reject} from "../wailsjs/go/main/App";
+import {main} from "../wailsjs/go/models";
+import ApprovalRequest = main.ApprovalRequest;
+
+const riskColors: { [risk: string]: string } = {
+    low: "bg-green-200",
+    medium: "bg-yellow-200",
+    high: "bg-red-300",
+};
+
+interface Props {
+    conversationID: number;
+    request: ApprovalRequest;
+}
+
+// ApprovalRequestPill approves the request when clicked, or lets the user reject it with a reason, or edit the tool call's arguments first.
+const ApprovalRequestPill = ({conversationID, request}: Props) => {
+    const [mode, setMode] = useState<"approve" | "reject" | "edit">("approve");
+    const [reason, setReason] = useState("");
+    const [argsText, setArgsText] = useState("");
+    const [error, setError] = useState("");
+
+    const startEditing = () => {
+        setArgsText(JSON.stringify(request.args || {}, null, 2));
+        setError("");
+        setMode("edit");
+    };
+
+    const approveWithEdits = async () => {
+        let args: { [key: string]: any };
+        try {
+            args = JSON.parse(argsText);
+        } catch (e) {
+            setError(`The arguments aren't valid JSON: ${e}`);
+            return;
+        }
+        try {
+            await ApproveWithEdits(conversationID, request.id, args);
+        } catch (e) {
+            // I.e. the arguments don't match the tool's schema, in which case the request stays pending.
+            setError(`${e}`);
+        }
+    };
+
+    if (mode === "reject") {
+        return <form className="absolute left-12 bottom-1 flex bg-red-400 opacity-90 text-gray-800 rounded-xl px-2 py-1"
+                     onSubmit={async (e) => {
+                         e.preventDefault();
+                         await Reject(conversationID, request.id, reason);
+                     }}>
+            <input className="bg-red-200 rounded-md px-1 w-96" autoFocus placeholder="Why? The Assistant will see this (optional)"
+                   value={reason} onChange={(e) => setReason(e.target.value)}/>
+            <button type="submit" className="ml-2 underline hover:text-gray-600">Reject</button>
+            <button type="button" className="ml-2 underline hover:text-gray-600" onClick={() => setMode("approve")}>Back</button>
+        </form>
+    }
+
+    if (mode === "edit") {
+        return <div className="absolute left-12 bottom-1 flex flex-col w-1/2 bg-yellow-300 opacity-90 text-gray-800 rounded-xl px-2 py-1">
+            <textarea className="bg-yellow-100 rounded-md p-1 h-48 font-mono text-sm" autoFocus
+                      value={argsText} onChange={(e) => setArgsText(e.target.value)}/>
+            {error && <p className="text-red-700 text-sm whitespace-pre-wrap">{error}</p>}
+            <div className="flex justify-end">
+                <button className="ml-2 underline hover:text-gray-600" onClick={approveWithEdits}>Approve with edits</button>
+                <button className="ml-2 underline hover:text-gray-600" onClick={() => setMode("approve")}>Back</button>
+            </div>
+        </div>
+    }
+
+    // Long previews, i.e. diffs of files about to be written, grow upwards and scroll.
+    return <div className="absolute left-12 bottom-1 max-h-96 overflow-y-auto whitespace-pre-wrap bg-green-400 hover:bg-green-300 opacity-75 text-gray-800 rounded-xl px-2 cursor-pointer" onClick={async () => await Approve(conversationID, request.id)}>
+        Click to approve: "{request.message}"
+        {(request.toolName || request.workingDirectory || request.risk) &&
+          <div className="text-sm">
+              {request.toolName && <span className="mr-2">{request.toolName} <span className="text-gray-600">({request.toolID})</span></span>}
+              {request.workingDirectory && <span className="mr-2 font-mono">in {request.workingDirectory}</span>}
+              {request.risk &&
+                <span className={`rounded-md px-1 ${riskColors[request.risk] || ""}`}>
+                    {request.risk} risk{request.riskReason && `: ${request.riskReason}`}
+                </span>}
+          </div>}
+        {request.preview &&
+          <pre className="bg-gray-800 text-gray-200 rounded-md p-2 my-1 text-sm overflow-x-auto">
+              {request.previewType === "diff"
+                  ? request.preview.split("\n").map((line, index) => (
+                      <div key={index} className={line.startsWith("+") ? "text-green-400" : line.startsWith("-") ? "text-red-400" : ""}>{line}</div>
+                  ))
+                  : request.preview}
+          </pre>}
+        {/* The buttons stop the click from reaching the pill, which would approve the request. */}
+        {request.canApproveAlways &&
+          <button className="ml-2 underline hover:text-gray-600" onClick={async (event) => {
+              event.stopPropagation();
+              await ApproveAlways(conversationID, request.id);
+          }}>Always allow</button>}
+        {request.args &&
+          <button className="ml-2 underline hover:text-gray-600" onClick={(event) => {
+              event.stopPropagation();
+              startEditing();
+          }}>Edit</button>}
+        <button className="ml-2 underline hover:text-gray-600" onClick={(event) => {
+            event.stopPropagation();
+            setMode("reject");
+        }}>Reject</button>
+    </div>
+}
+
+export default ApprovalRequestPill;
@@ -1,34 +1,55 @@
-import {Approve, CancelGeneration, GetConversation, ListApprovalRequests, Messages} from "../wailsjs/go/main/App";
+import {
+    CancelGeneration,
+    GetConversation,
+    ListApprovalRequests,
+    ListBranches,
+    ListSubConversations,
+    Messages,
+    SwitchBranch
+} from "../wailsjs/go/main/App";
 import React, {useEffect, useRef, useState} from "react";
 import {database, main} from "../wailsjs/go/models";
 import {EventsOn} from "../wailsjs/runtime";
 import {MinusCircle} from "iconoir-react";
 import ChatInputForm from "./ChatInputForm";
 import MessageBubble from "./Message";
+import ApprovalRequestPill from "./ApprovalRequestPill";
 import Message = database.Message;
 import Conversation = database.Conversation;
 import ApprovalRequest = main.ApprovalRequest;
+import Branch = main.Branch;
 
 interface Props {
     conversationID: number | null;
     setConversationID: (conversationID: number) => void;
+    templateID: number | null;
 }
 
-const Chat = ({conversationID, setConversationID}: Props) => {
+const Chat = ({conversationID, setConversationID, templateID}: Props) => {
     const [messages, setMessages] = useState<Array<Message>>([]);
     const [approvalRequests, setApprovalRequests] = useState<Array<ApprovalRequest>>([]);
+    const [branches, setBranches] = useState<Array<Branch>>([]);
+    const [subConversations, setSubConversations] = useState<Array<Conversation>>([]);
     const [curConversation, setCurConversation] = useState<database.Conversation | null>(null);
     const messagesContainerRef = useRef<HTMLDivElement>(null);
 
     useEffect(() => {
         if (conversationID === null) {
             setMessages([]);
+            setBranches([]);
+            setSubConversations([]);
             setCurConversation(null);
             return;
         }
         Messages(conversationID).then((messages) => {
             setMessages(messages);
         });
+        ListBranches(conversationID).then((branches) => {
+            setBranches(branches);
+        });
+        ListSubConversations(conversationID).then((conversations) => {
+            setSubConversations(conversations);
+        });
         GetConversation(conversationID).then((conversation: Conversation) => {
             setCurConversation(conversation);
         })
@@ -45,6 +66,12 @@ const Chat = ({conversationID, setConversationID}: Props) => {
             Messages(conversationID).then((messages) => {
                 setMessages(messages);
             });
+            ListBranches(conversationID).then((branches) => {
+                setBranches(branches);
+            });
+            ListSubConversations(conversationID).then((conversations) => {
+                setSubConversations(conversations);
+            });
             GetConversation(conversationID).then((conversation: Conversation) => {
                 setCurConversation(conversation);
             })
@@ -78,25 +105,37 @@ const Chat = ({conversationID, setConversationID}: Props) => {
                     {messages.map((message, index) => (
                         <div key={index}
                              className={`flex flex-col ${message.author == 'user' ? "items-end" : "items-start"}`}>
-                            <MessageBubble message={message}/>
+                            <MessageBubble message={message}
+                                           subConversations={subConversations.filter((conversation) => conversation.parentMessageID?.Int64 === message.id)}
+                                           openConversation={setConversationID}/>
                         </div>
                     ))}
                 </div>
+                {conversationID && branches.length > 1 &&
+                  <select
+                    className="absolute right-4 top-2 max-w-xs bg-gray-700 text-gray-300 rounded-md px-2 py-1 text-sm"
+                    value={branches.find((branch) => branch.active)?.leafMessageID}
+                    onChange={async (e) => await SwitchBranch(conversationID, Number(e.target.value))}
+                  >
+                      {branches.map((branch, index) => (
+                          <option key={branch.leafMessageID} value={branch.leafMessageID}>
+                              Branch {index + 1}/{branches.length}: {branch.lastMessage.content.slice(0, 30) || branch.lastMessage.author}
+                          </option>
+                      ))}
+                  </select>}
                 {curConversation?.generating &&
                   <MinusCircle className="absolute left-4 bottom-1 hover:text-gray-400 cursor-pointer" onClick={async () => {
                       if (curConversation !== null) {
                           await CancelGeneration(curConversation.id)
                       }
                   }}/>}
-                {conversationID && approvalRequests.map((request, index) => {
+                {conversationID && approvalRequests.map((request) => (
                     // There's at most one.
-                    return <div className="absolute left-12 bottom-1 bg-green-400 hover:bg-green-300 opacity-75 text-gray-800 rounded-full px-2 cursor-pointer" onClick={async () => await Approve(conversationID, request.id)}>
-                        Click to approve: "{request.message}"
-                    </div>
-                })}
+                    <ApprovalRequestPill key={request.id} conversationID={conversationID} request={request}/>
+                ))}
             </div>
             <ChatInputForm disabled={curConversation?.generating || false} conversationID={conversationID}
-                           setConversationID={setConversationID}/>
+                           setConversationID={setConversationID} templateID={templateID}/>
         </div>
     </div>
 }
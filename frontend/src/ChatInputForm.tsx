@@ -1,16 +1,17 @@
 import {Settings} from "iconoir-react";
 import React, {Fragment, useEffect, useState} from "react";
 import {Dialog, Listbox, Transition} from "@headlessui/react";
-import {GetSettings, SaveSettings, SendMessage} from "../wailsjs/go/main/App";
+import {GetSettings, SaveSettings, SendMessage, SendMessageFromTemplate} from "../wailsjs/go/main/App";
 import {database} from "../wailsjs/go/models";
 
 interface Props {
     disabled: boolean;
     conversationID: number | null;
     setConversationID: (conversationID: number) => void;
+    templateID: number | null;
 }
 
-const ChatInputForm = ({disabled, conversationID, setConversationID}: Props) => {
+const ChatInputForm = ({disabled, conversationID, setConversationID, templateID}: Props) => {
     const [inputText, setInputText] = useState("");
 
     const handleKeyDown = async (event: React.KeyboardEvent<HTMLTextAreaElement>) => {
@@ -25,7 +26,9 @@ const ChatInputForm = ({disabled, conversationID, setConversationID}: Props) =>
             return;
         }
         if (inputText.trim() !== "") {
-            let message = await SendMessage(conversationID !== null ? conversationID : -1, inputText);
+            let message = conversationID === null && templateID !== null
+                ? await SendMessageFromTemplate(templateID, inputText)
+                : await SendMessage(conversationID !== null ? conversationID : -1, inputText);
             setInputText("");
             setConversationID(message.conversationID);
         }
@@ -25,6 +25,17 @@ const ConversationSettingsButton = ({className, conversationSettingsID}: Props)
     const [settings, setSettings] = useState<database.ConversationSetting>();
     const [systemPromptTemplate, setSystemPromptTemplate] = useState("");
     const [toolsEnabled, setToolsEnabled] = useState<Set<string>>(new Set());
+    const [provider, setProvider] = useState("");
+    const [model, setModel] = useState("");
+    const [maxConsecutiveToolFailures, setMaxConsecutiveToolFailures] = useState(3);
+    const [contextWindow, setContextWindow] = useState(0);
+    const [temperature, setTemperature] = useState(0.7);
+    const [maxTokens, setMaxTokens] = useState(1024);
+    const [topP, setTopP] = useState(1);
+    const [stop, setStop] = useState("");
+    const [sandbox, setSandbox] = useState(new database.SandboxSettings({enabled: false}));
+    const [readOnlyMounts, setReadOnlyMounts] = useState("");
+    const [readWriteMounts, setReadWriteMounts] = useState("");
     const [changed, setChanged] = useState(false);
 
     useEffect(() => {
@@ -39,12 +50,34 @@ const ConversationSettingsButton = ({className, conversationSettingsID}: Props)
                 setSettings(curSettings);
                 setSystemPromptTemplate(curSettings.systemPromptTemplate);
                 setToolsEnabled(new Set(curSettings.toolsEnabled));
+                setProvider(curSettings.provider);
+                setModel(curSettings.model);
+                setMaxConsecutiveToolFailures(curSettings.maxConsecutiveToolFailures);
+                setContextWindow(curSettings.contextWindow);
+                setTemperature(curSettings.temperature);
+                setMaxTokens(curSettings.maxTokens);
+                setTopP(curSettings.topP);
+                setStop(curSettings.stop.join(", "));
+                setSandbox(curSettings.sandbox);
+                setReadOnlyMounts((curSettings.sandbox.readOnlyMounts || []).join(", "));
+                setReadWriteMounts((curSettings.sandbox.readWriteMounts || []).join(", "));
             });
         } else {
             GetDefaultConversationSettings().then((curSettings) => {
                 setSettings(curSettings);
                 setSystemPromptTemplate(curSettings.systemPromptTemplate);
                 setToolsEnabled(new Set(curSettings.toolsEnabled));
+                setProvider(curSettings.provider);
+                setModel(curSettings.model);
+                setMaxConsecutiveToolFailures(curSettings.maxConsecutiveToolFailures);
+                setContextWindow(curSettings.contextWindow);
+                setTemperature(curSettings.temperature);
+                setMaxTokens(curSettings.maxTokens);
+                setTopP(curSettings.topP);
+                setStop(curSettings.stop.join(", "));
+                setSandbox(curSettings.sandbox);
+                setReadOnlyMounts((curSettings.sandbox.readOnlyMounts || []).join(", "));
+                setReadWriteMounts((curSettings.sandbox.readWriteMounts || []).join(", "));
             });
         }
     }
@@ -58,8 +91,27 @@ const ConversationSettingsButton = ({className, conversationSettingsID}: Props)
         setChanged(
             systemPromptTemplate !== settings.systemPromptTemplate
             || !arraySetsEqual(Array.from(toolsEnabled), settings.toolsEnabled)
+            || provider !== settings.provider
+            || model !== settings.model
+            || maxConsecutiveToolFailures !== settings.maxConsecutiveToolFailures
+            || contextWindow !== settings.contextWindow
+            || temperature !== settings.temperature
+            || maxTokens !== settings.maxTokens
+            || topP !== settings.topP
+            || stop !== settings.stop.join(", ")
+            || !sandboxesEqual(sandboxSettings(), settings.sandbox)
         );
-    }, [settings, systemPromptTemplate, toolsEnabled])
+    }, [settings, systemPromptTemplate, toolsEnabled, provider, model, maxConsecutiveToolFailures, contextWindow, temperature, maxTokens, topP, stop, sandbox, readOnlyMounts, readWriteMounts])
+
+    const sandboxSettings = () => new database.SandboxSettings({
+        ...sandbox,
+        readOnlyMounts: parseCommaSeparated(readOnlyMounts),
+        readWriteMounts: parseCommaSeparated(readWriteMounts),
+    });
+
+    const updateSandbox = (update: Partial<database.SandboxSettings>) => {
+        setSandbox(new database.SandboxSettings({...sandbox, ...update}));
+    }
 
     const setToolEnabled = (tool: string, enabled: boolean) => {
         let toolsEnabledUpdated = new Set(toolsEnabled);
@@ -77,12 +129,30 @@ const ConversationSettingsButton = ({className, conversationSettingsID}: Props)
                 id: conversationSettingsID,
                 systemPromptTemplate: systemPromptTemplate,
                 toolsEnabled: Array.from(toolsEnabled),
+                provider: provider,
+                model: model,
+                maxConsecutiveToolFailures: maxConsecutiveToolFailures,
+                contextWindow: contextWindow,
+                temperature: temperature,
+                maxTokens: maxTokens,
+                topP: topP,
+                stop: parseCommaSeparated(stop),
+                sandbox: sandboxSettings(),
             });
             setSettings(curSettings);
         } else {
             const curSettings = await SetDefaultConversationSettings({
                 systemPromptTemplate: systemPromptTemplate,
                 toolsEnabled: Array.from(toolsEnabled),
+                provider: provider,
+                model: model,
+                maxConsecutiveToolFailures: maxConsecutiveToolFailures,
+                contextWindow: contextWindow,
+                temperature: temperature,
+                maxTokens: maxTokens,
+                topP: topP,
+                stop: parseCommaSeparated(stop),
+                sandbox: sandboxSettings(),
             });
             setSettings(curSettings);
         }
@@ -139,6 +209,149 @@ const ConversationSettingsButton = ({className, conversationSettingsID}: Props)
                                         className="border border-gray-300 border-opacity-50 p-2 w-full h-32 bg-gray-700 text-gray-300 resize-none rounded-md"
                                     />
                                 </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Provider (empty to use the app setting)</p>
+                                    <input type="text"
+                                           value={provider}
+                                           placeholder="openai, anthropic, ollama"
+                                           onChange={(event) => setProvider(event.target.value)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Model (empty to use the app setting)</p>
+                                    <input type="text"
+                                           value={model}
+                                           onChange={(event) => setModel(event.target.value)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Stop after consecutive tool failures (0 for no limit)</p>
+                                    <input type="number"
+                                           min={0}
+                                           value={maxConsecutiveToolFailures}
+                                           onChange={(event) => setMaxConsecutiveToolFailures(parseInt(event.target.value) || 0)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Context window in tokens (0 for the model's default)</p>
+                                    <input type="number"
+                                           min={0}
+                                           value={contextWindow}
+                                           onChange={(event) => setContextWindow(parseInt(event.target.value) || 0)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Temperature</p>
+                                    <input type="number"
+                                           min={0}
+                                           max={2}
+                                           step={0.1}
+                                           value={temperature}
+                                           onChange={(event) => setTemperature(parseFloat(event.target.value) || 0)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Max tokens per response</p>
+                                    <input type="number"
+                                           min={1}
+                                           value={maxTokens}
+                                           onChange={(event) => setMaxTokens(parseInt(event.target.value) || 0)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Top P</p>
+                                    <input type="number"
+                                           min={0}
+                                           max={1}
+                                           step={0.05}
+                                           value={topP}
+                                           onChange={(event) => setTopP(parseFloat(event.target.value) || 0)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="flex items-center justify-between p-2">
+                                    <p className="text-gray-400">Additional stop sequences (comma-separated, at most 2 for OpenAI)</p>
+                                    <input type="text"
+                                           value={stop}
+                                           onChange={(event) => setStop(event.target.value)}
+                                           className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                </div>
+                                <div className="p-2">
+                                    <h2 className="text-md font-bold text-gray-400 mb-2">Sandbox</h2>
+                                    <p className="text-gray-500 text-sm px-2">Runs the terminal and python tools with bubblewrap, on Linux only.
+                                        They see the system directories read-only, the conversation's workspace read-write, and the mounts below.</p>
+                                    <div className="flex items-center justify-between p-2">
+                                        <p className="text-gray-400">Enabled</p>
+                                        <Switch
+                                            checked={sandbox.enabled}
+                                            onChange={(enabled) => updateSandbox({enabled: enabled})}
+                                            className={`${
+                                                sandbox.enabled ? 'bg-gray-400' : 'bg-gray-700'
+                                            } relative inline-flex h-6 w-11 items-center rounded-full border border-gray-300 border-opacity-50`}
+                                        >
+                                            <span
+                                                className={`${
+                                                    sandbox.enabled ? 'translate-x-6' : 'translate-x-1'
+                                                } inline-block h-4 w-4 transform rounded-full bg-gray-200 transition`}
+                                            />
+                                        </Switch>
+                                    </div>
+                                    <div className="flex items-center justify-between p-2">
+                                        <p className="text-gray-400">Read-only mounts (comma-separated)</p>
+                                        <input type="text"
+                                               value={readOnlyMounts}
+                                               placeholder="~/projects/data"
+                                               onChange={(event) => setReadOnlyMounts(event.target.value)}
+                                               className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                    </div>
+                                    <div className="flex items-center justify-between p-2">
+                                        <p className="text-gray-400">Read-write mounts (comma-separated)</p>
+                                        <input type="text"
+                                               value={readWriteMounts}
+                                               placeholder="~/projects/scratch"
+                                               onChange={(event) => setReadWriteMounts(event.target.value)}
+                                               className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                    </div>
+                                    <div className="flex items-center justify-between p-2">
+                                        <p className="text-gray-400">Network access</p>
+                                        <Switch
+                                            checked={sandbox.network}
+                                            onChange={(network) => updateSandbox({network: network})}
+                                            className={`${
+                                                sandbox.network ? 'bg-gray-400' : 'bg-gray-700'
+                                            } relative inline-flex h-6 w-11 items-center rounded-full border border-gray-300 border-opacity-50`}
+                                        >
+                                            <span
+                                                className={`${
+                                                    sandbox.network ? 'translate-x-6' : 'translate-x-1'
+                                                } inline-block h-4 w-4 transform rounded-full bg-gray-200 transition`}
+                                            />
+                                        </Switch>
+                                    </div>
+                                    <div className="flex items-center justify-between p-2">
+                                        <p className="text-gray-400">CPU time per process in seconds (0 for no limit)</p>
+                                        <input type="number"
+                                               min={0}
+                                               value={sandbox.cpuSeconds || 0}
+                                               onChange={(event) => updateSandbox({cpuSeconds: parseInt(event.target.value) || 0})}
+                                               className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                    </div>
+                                    <div className="flex items-center justify-between p-2">
+                                        <p className="text-gray-400">Memory in MB, per process as address space without systemd (0 for no limit)</p>
+                                        <input type="number"
+                                               min={0}
+                                               value={sandbox.memoryMB || 0}
+                                               onChange={(event) => updateSandbox({memoryMB: parseInt(event.target.value) || 0})}
+                                               className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                    </div>
+                                    <div className="flex items-center justify-between p-2">
+                                        <p className="text-gray-400">Time limit per command or cell in seconds (0 for no limit)</p>
+                                        <input type="number"
+                                               min={0}
+                                               value={sandbox.timeoutSeconds || 0}
+                                               onChange={(event) => updateSandbox({timeoutSeconds: parseInt(event.target.value) || 0})}
+                                               className="border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                                    </div>
+                                </div>
                                 <div className="p-2">
                                     <h2 className="text-md font-bold text-gray-400 mb-2">Enabled Tools</h2>
                                     <div className="flex flex-col">
@@ -187,6 +400,20 @@ const ConversationSettingsButton = ({className, conversationSettingsID}: Props)
     )
 }
 
+function parseCommaSeparated(list: string): string[] {
+    return list.split(",").map((item) => item.trim()).filter((item) => item !== "");
+}
+
+function sandboxesEqual(sandbox1: database.SandboxSettings, sandbox2: database.SandboxSettings): boolean {
+    return sandbox1.enabled === sandbox2.enabled
+        && sandbox1.network === sandbox2.network
+        && (sandbox1.cpuSeconds || 0) === (sandbox2.cpuSeconds || 0)
+        && (sandbox1.memoryMB || 0) === (sandbox2.memoryMB || 0)
+        && (sandbox1.timeoutSeconds || 0) === (sandbox2.timeoutSeconds || 0)
+        && (sandbox1.readOnlyMounts || []).join(", ") === (sandbox2.readOnlyMounts || []).join(", ")
+        && (sandbox1.readWriteMounts || []).join(", ") === (sandbox2.readWriteMounts || []).join(", ");
+}
+
 function arraySetsEqual(arr1: string[], arr2: string[]): boolean {
     const arr1Sorted = arr1.sort();
     const arr2Sorted = arr2.sort();
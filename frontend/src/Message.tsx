@@ -1,7 +1,7 @@
-import {RefreshDouble, Settings} from "iconoir-react";
+import {EditPencil, RefreshDouble, Settings} from "iconoir-react";
 import React, {Fragment, useEffect, useState} from "react";
 import {Dialog, Listbox, Transition} from "@headlessui/react";
-import {GetSettings, RerunFromMessage, SaveSettings, SendMessage} from "../wailsjs/go/main/App";
+import {EditMessage, GetSettings, RerunFromMessage, SaveSettings, SendMessage} from "../wailsjs/go/main/App";
 import {database} from "../wailsjs/go/models";
 import Message = database.Message;
 import {capitalizeFirstLetter, isJSONString} from "./helpers";
@@ -12,20 +12,38 @@ import {dracula} from "react-syntax-highlighter/dist/esm/styles/prism";
 
 interface Props {
     message: Message;
+    // The sub-conversations started by this message's tool calls.
+    subConversations?: database.Conversation[];
+    openConversation?: (conversationID: number) => void;
 }
 
-const MessageBubble = ({message}: Props) => {
+const MessageBubble = ({message, subConversations, openConversation}: Props) => {
     const [effect, setEffect] = useState(false);
+    const [editing, setEditing] = useState(false);
+    const [editedContent, setEditedContent] = useState("");
+
+    const submitEdit = async () => {
+        if (editedContent.trim() === "") {
+            return;
+        }
+        setEditing(false);
+        await EditMessage(message.conversationID, message.id, editedContent);
+    };
 
     const copyToClipboard = (text: string) => {
         navigator.clipboard.writeText(text);
     };
 
     const renderMarkdown = (message: Message) => {
+        let content = message.content;
+        for (const toolCall of message.toolCalls || []) {
+            // Native function calls aren't part of the content, so we render them like text-based actions.
+            content += "\n```action\n" + JSON.stringify({tool: toolCall.tool, args: toolCall.args}, null, 2) + "\n```";
+        }
         return (
             // TODO: Custom Thought and Action rendering.
             <ReactMarkdown
-                children={message.content}
+                children={content}
                 components={{
                     code({node, inline, className, children, ...props}) {
                         const match = /language-(\w+)/.exec(className || "");
@@ -101,14 +119,44 @@ const MessageBubble = ({message}: Props) => {
 
     return (
         <div
-            className={`flex flex-col max-w-5/6 w-5/6 ${message.author == 'user' ? "items-end" : "items-start"} py-1 px-4 rounded-md text-white inline-block relative`}
+            className={`flex flex-col max-w-5/6 w-5/6 ${message.author == 'user' ? "items-end" : "items-start"} ${message.contextStatus === 'summarized' && "opacity-50"} py-1 px-4 rounded-md text-white inline-block relative`}
             style={{wordWrap: "break-word"}}
         >
             <div className={`${message.author == 'user' ? "text-end" : "text-start"} text-gray-500 p-1 px-2`}>
                 {capitalizeFirstLetter(message.author == 'user' ? "you" : message.author)}
+                {message.contextStatus === 'trimmed' && <span className="italic"> (output trimmed from the model's context)</span>}
+                {message.contextStatus === 'summarized' && <span className="italic"> (summarized, no longer sent to the model)</span>}
             </div>
-            {message.author === 'user' ?
+            {message.author === 'user' && editing ?
+                (<div className="flex flex-col items-end w-full">
+                    <textarea
+                        value={editedContent}
+                        onChange={(event) => setEditedContent(event.target.value)}
+                        onKeyDown={async (event) => {
+                            if (event.key === "Enter" && !event.shiftKey) {
+                                event.preventDefault();
+                                await submitEdit();
+                            } else if (event.key === "Escape") {
+                                setEditing(false);
+                            }
+                        }}
+                        className="border border-gray-300 border-opacity-50 p-2 w-full h-32 bg-gray-900 text-white resize-none rounded-md"
+                    />
+                    <div className="flex flex-row">
+                        <button type="button" onClick={() => setEditing(false)} className="bg-gray-600 text-white p-2 rounded-md mt-2 mr-2">
+                            Cancel
+                        </button>
+                        <button type="button" onClick={submitEdit} className="bg-blue-500 text-white p-2 rounded-md mt-2">
+                            Save & Submit
+                        </button>
+                    </div>
+                </div>)
+                : message.author === 'user' ?
                 (<div className="flex flex-row">
+                    <EditPencil className="flex-none my-2 scale-75 text-gray-500 hover:text-gray-400 cursor-pointer" onClick={() => {
+                        setEditedContent(message.content);
+                        setEditing(true);
+                    }}/>
                     <RefreshDouble className={`${effect && "animate-refresh_rotate_scaled"} flex-none m-2 scale-75 text-gray-500 hover:text-gray-400 cursor-pointer`} onClick={async () => {
                         setEffect(true);
                         await RerunFromMessage(message.conversationID, message.id);
@@ -118,7 +166,13 @@ const MessageBubble = ({message}: Props) => {
                 :
                 renderMarkdown(message)
             }
-
+            {subConversations?.map((conversation) => (
+                <div key={conversation.id}
+                     className="text-gray-500 hover:text-gray-400 cursor-pointer text-sm p-1 px-2"
+                     onClick={() => openConversation?.(conversation.id)}>
+                    Open sub-conversation: {conversation.title}
+                </div>
+            ))}
         </div>
     )
 }
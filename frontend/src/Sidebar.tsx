@@ -1,19 +1,31 @@
 import React, {useEffect, useState} from "react";
 import {database} from "../wailsjs/go/models";
 import AppSettingsButton from "./AppSettingsButton";
-import {Conversations, DeleteConversation} from "../wailsjs/go/main/App";
+import ToolExecutionsButton from "./ToolExecutionsButton";
+import {
+    Conversations,
+    CreateConversationTemplate,
+    DeleteConversation,
+    DeleteConversationTemplate,
+    ListConversationTemplates
+} from "../wailsjs/go/main/App";
 import {EventsOn} from "../wailsjs/runtime";
 import {Bin, EditPencil} from "iconoir-react";
 import Conversation = database.Conversation;
+import ConversationTemplate = database.ConversationTemplate;
 import ConversationSettingsButton from "./ConversationSettingsButton";
 
 interface Props {
     curConversationID: number | null;
     setCurConversationID: (conversationID: number | null) => void;
+    templateID: number | null;
+    setTemplateID: (templateID: number | null) => void;
 }
 
-const Sidebar = ({curConversationID, setCurConversationID}: Props) => {
+const Sidebar = ({curConversationID, setCurConversationID, templateID, setTemplateID}: Props) => {
     const [conversations, setConversations] = useState<Array<Conversation>>([]);
+    const [templates, setTemplates] = useState<Array<ConversationTemplate>>([]);
+    const [newTemplateName, setNewTemplateName] = useState("");
 
     useEffect(() => {
         Conversations().then((conversations) => {
@@ -29,6 +41,32 @@ const Sidebar = ({curConversationID, setCurConversationID}: Props) => {
         })
     }, []);
 
+    useEffect(() => {
+        ListConversationTemplates().then((templates) => {
+            setTemplates(templates);
+        });
+        return EventsOn(`conversation-templates-updated`, (data: any) => {
+            ListConversationTemplates().then((templates) => {
+                setTemplates(templates);
+            });
+        })
+    }, []);
+
+    const onTemplateCreate = async () => {
+        if (newTemplateName.trim() === "") {
+            return;
+        }
+        await CreateConversationTemplate(newTemplateName.trim());
+        setNewTemplateName("");
+    }
+
+    const onTemplateDelete = async (id: number) => {
+        await DeleteConversationTemplate(id);
+        if (templateID === id) {
+            setTemplateID(null);
+        }
+    }
+
     const onConversationDelete = async (id: number) => {
         await DeleteConversation(id);
         setCurConversationID(null);
@@ -40,9 +78,10 @@ const Sidebar = ({curConversationID, setCurConversationID}: Props) => {
                 <h2 className="font-bold text-lg text-gray-300 p-3">Conversations</h2>
                 <div className="overflow-y-auto divide-y divide-gray-700 border-t border-gray-300 border-opacity-50">
                     <div
-                        className={`flex items-center cursor-pointer py-2 hover:bg-gray-700`}
+                        className={`flex items-center cursor-pointer py-2 ${curConversationID === null && templateID === null ? "bg-gray-800" : ""} hover:bg-gray-700`}
                         onClick={() => {
                             setCurConversationID(null)
+                            setTemplateID(null)
                         }}
                     >
                         {/*<div className="w-10 h-10 rounded-full bg-gray-300 mr-2"></div>*/}
@@ -51,6 +90,38 @@ const Sidebar = ({curConversationID, setCurConversationID}: Props) => {
                             <ConversationSettingsButton className="absolute scale-75 top-0 right-1 text-gray-500 hover:text-gray-400" conversationSettingsID={null}/>
                         </div>
                     </div>
+                    {templates.map((template) => (
+                        <div
+                            key={`template-${template.id}`}
+                            className={`relative flex items-center cursor-pointer py-2 ${curConversationID === null && templateID === template.id ? "bg-gray-800" : ""} hover:bg-gray-700`}
+                            onClick={() => {
+                                setCurConversationID(null)
+                                setTemplateID(template.id)
+                            }}
+                        >
+                            <div className="flex-1 text-gray-500 px-2">
+                                <p className="text-gray-500">New conversation with <span className="italic">{template.name}</span>...</p>
+                            </div>
+                            <ConversationSettingsButton className="absolute scale-75 top-1 right-7 text-gray-500 hover:text-gray-400" conversationSettingsID={template.conversationSettingsID}/>
+                            <Bin className="absolute scale-75 top-1 right-1 text-gray-500 hover:text-red-400"
+                                 onClick={async (e) => {
+                                     e.stopPropagation();
+                                     await onTemplateDelete(template.id);
+                                 }}/>
+                        </div>
+                    ))}
+                    <div className="flex items-center py-2 px-2">
+                        <input type="text"
+                               value={newTemplateName}
+                               placeholder="New template name..."
+                               onChange={(event) => setNewTemplateName(event.target.value)}
+                               onKeyDown={async (event) => {
+                                   if (event.key === "Enter") {
+                                       await onTemplateCreate();
+                                   }
+                               }}
+                               className="flex-1 border border-gray-300 border-opacity-50 p-2 h-8 bg-gray-700 text-gray-300 rounded-md"/>
+                    </div>
                     {conversations.map((conversation, index) => (
                         <div
                             key={conversation.id}
@@ -75,6 +146,7 @@ const Sidebar = ({curConversationID, setCurConversationID}: Props) => {
             </div>
             <div className="h-12"></div>
             <AppSettingsButton className="absolute bottom-4 left-4"/>
+            <ToolExecutionsButton className="absolute bottom-4 left-12" conversationID={curConversationID}/>
         </div>
     )
 }
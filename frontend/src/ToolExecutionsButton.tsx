@@ -0,0 +1,136 @@
+import {List} from "iconoir-react";
+import React, {Fragment, useEffect, useState} from "react";
+import {Dialog, Switch, Transition} from "@headlessui/react";
+import {ExportToolExecutions, ListConversationToolExecutions, ListToolExecutions} from "../wailsjs/go/main/App";
+import {database} from "../wailsjs/go/models";
+import ToolExecution = database.ToolExecution;
+
+interface Props {
+    className?: string;
+    conversationID: number | null;
+}
+
+const statusColors: { [status: string]: string } = {
+    succeeded: "text-green-400",
+    failed: "text-red-400",
+    denied: "text-red-400",
+    rejected: "text-yellow-400",
+    cancelled: "text-gray-500",
+    running: "text-blue-400",
+};
+
+// ToolExecutionsButton opens the log of everything the Assistant ran, with how each tool call got approved.
+const ToolExecutionsButton = ({className, conversationID}: Props) => {
+    const [isOpen, setIsOpen] = useState(false);
+    const [onlyConversation, setOnlyConversation] = useState(false);
+    const [executions, setExecutions] = useState<ToolExecution[]>([]);
+    const [expandedID, setExpandedID] = useState<number | null>(null);
+
+    const filterConversationID = onlyConversation ? conversationID : null;
+
+    useEffect(() => {
+        if (!isOpen) {
+            return;
+        }
+        const executions = filterConversationID !== null
+            ? ListConversationToolExecutions(filterConversationID).then((executions) => executions.reverse())
+            : ListToolExecutions(500);
+        executions.then(setExecutions);
+    }, [isOpen, filterConversationID]);
+
+    const exportExecutions = async (format: string) => {
+        const content = await ExportToolExecutions(filterConversationID !== null ? filterConversationID : -1, format);
+        const url = URL.createObjectURL(new Blob([content], {type: format === "csv" ? "text/csv" : "application/jsonl"}));
+        const link = document.createElement("a");
+        link.href = url;
+        link.download = `tool-executions.${format}`;
+        link.click();
+        URL.revokeObjectURL(url);
+    };
+
+    return (
+        <>
+            <div onClick={() => setIsOpen(true)} className={"cursor-pointer " + className}>
+                <List className="text-gray-500 hover:text-gray-400"/>
+            </div>
+            <Transition show={isOpen} as={Fragment}>
+                <Dialog open={isOpen} onClose={() => setIsOpen(false)}>
+                    <Transition.Child
+                        as={Fragment}
+                        enter="ease-out duration-300"
+                        enterFrom="opacity-0"
+                        enterTo="opacity-50"
+                        leave="ease-in duration-200"
+                        leaveFrom="opacity-50"
+                        leaveTo="opacity-0"
+                    >
+                        <div
+                            onClick={() => setIsOpen(false)}
+                            className="fixed inset-0 bg-gray-800 opacity-50 z-30"
+                        ></div>
+                    </Transition.Child>
+                    <Transition.Child
+                        as={Fragment}
+                        enter="ease-out duration-300"
+                        enterFrom="opacity-0 scale-95"
+                        enterTo="opacity-100 scale-100"
+                        leave="ease-in duration-200"
+                        leaveFrom="opacity-100 scale-100"
+                        leaveTo="opacity-0 scale-95"
+                    >
+                        <Dialog.Panel
+                            className="flex flex-col fixed inset-40 z-40 bg-gray-900 rounded-md p-4 overflow-hidden">
+                            <div className="flex items-center justify-between mb-4">
+                                <Dialog.Title className="text-lg font-bold text-gray-400">Tool Executions</Dialog.Title>
+                                <div className="flex items-center text-gray-400">
+                                    {conversationID !== null && <>
+                                      <p className="mr-2">This conversation only</p>
+                                      <Switch
+                                        checked={onlyConversation}
+                                        onChange={setOnlyConversation}
+                                        className={`${onlyConversation ? 'bg-gray-400' : 'bg-gray-700'} relative inline-flex h-6 w-11 items-center rounded-full mr-4`}
+                                      >
+                                          <span className={`${onlyConversation ? 'translate-x-6' : 'translate-x-1'} inline-block h-4 w-4 transform rounded-full bg-white transition`}/>
+                                      </Switch>
+                                    </>}
+                                    <button className="bg-gray-700 hover:bg-gray-600 rounded-md px-2 py-1 mr-2" onClick={() => exportExecutions("jsonl")}>Export JSONL</button>
+                                    <button className="bg-gray-700 hover:bg-gray-600 rounded-md px-2 py-1" onClick={() => exportExecutions("csv")}>Export CSV</button>
+                                </div>
+                            </div>
+                            <div className="divide-y divide-gray-700 h-full overflow-y-auto text-gray-300 text-sm">
+                                {executions.length === 0 && <p className="p-2 text-gray-500">No tools have been run yet.</p>}
+                                {executions.map((execution) => (
+                                    <div key={execution.id} className="p-2 cursor-pointer hover:bg-gray-800"
+                                         onClick={() => setExpandedID(expandedID === execution.id ? null : execution.id)}>
+                                        <div className="flex justify-between">
+                                            <span>
+                                                <span className="text-gray-500 mr-2">{new Date(execution.startedAt).toLocaleString()}</span>
+                                                <span className="font-bold mr-2">{execution.tool}</span>
+                                                <span className="font-mono">{JSON.stringify(execution.args).slice(0, 120)}</span>
+                                            </span>
+                                            <span className="whitespace-nowrap ml-2">
+                                                <span className="text-gray-500 mr-2">conversation {execution.conversationID}</span>
+                                                <span className="text-gray-500 mr-2">{execution.approval.replace(/_/g, " ")}{execution.approvedBy && ` by ${execution.approvedBy}`}</span>
+                                                <span className={statusColors[execution.status] || ""}>{execution.status}</span>
+                                                {execution.exitCode?.Valid && <span className="ml-2">exit code {execution.exitCode.Int64}</span>}
+                                            </span>
+                                        </div>
+                                        {expandedID === execution.id &&
+                                          <pre className="whitespace-pre-wrap bg-gray-800 rounded-md p-2 mt-2 font-mono">
+                                              {JSON.stringify(execution.args, null, 2)}
+                                              {execution.result && `\n\n${execution.result}`}
+                                              {execution.error && `\n\nerror: ${execution.error}`}
+                                              {execution.output && `\n\n${execution.output}`}
+                                          </pre>}
+                                    </div>
+                                ))}
+                            </div>
+                        </Dialog.Panel>
+                    </Transition.Child>
+                </Dialog>
+            </Transition>
+        </>
+    )
+}
+
+export default ToolExecutionsButton;
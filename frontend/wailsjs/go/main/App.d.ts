@@ -5,12 +5,28 @@ import {main} from '../models';
 
 export function Approve(arg1:number,arg2:string):Promise<void>;
 
+export function ApproveAlways(arg1:number,arg2:string):Promise<void>;
+
+export function ApproveWithEdits(arg1:number,arg2:string,arg3:{[key: string]: any}):Promise<void>;
+
 export function CancelGeneration(arg1:number):Promise<void>;
 
+export function CompareBranches(arg1:number,arg2:number,arg3:number):Promise<main.BranchComparison>;
+
 export function Conversations():Promise<Array<database.Conversation>>;
 
+export function CreateConversationTemplate(arg1:string):Promise<database.ConversationTemplate>;
+
 export function DeleteConversation(arg1:number):Promise<void>;
 
+export function DeleteConversationTemplate(arg1:number):Promise<void>;
+
+export function EditMessage(arg1:number,arg2:number,arg3:string):Promise<database.Message>;
+
+export function ExportToolExecutions(arg1:number,arg2:string):Promise<string>;
+
+export function GetAvailableProviders():Promise<Array<main.AvailableProvider>>;
+
 export function GetAvailableTools():Promise<Array<main.AvailableTool>>;
 
 export function GetConversation(arg1:number):Promise<database.Conversation>;
@@ -23,8 +39,20 @@ export function GetSettings():Promise<database.Settings>;
 
 export function ListApprovalRequests(arg1:number):Promise<Array<main.ApprovalRequest>>;
 
+export function ListBranches(arg1:number):Promise<Array<main.Branch>>;
+
+export function ListConversationTemplates():Promise<Array<database.ConversationTemplate>>;
+
+export function ListConversationToolExecutions(arg1:number):Promise<Array<database.ToolExecution>>;
+
+export function ListSubConversations(arg1:number):Promise<Array<database.Conversation>>;
+
+export function ListToolExecutions(arg1:number):Promise<Array<database.ToolExecution>>;
+
 export function Messages(arg1:number):Promise<Array<database.Message>>;
 
+export function Reject(arg1:number,arg2:string,arg3:string):Promise<void>;
+
 export function RerunFromMessage(arg1:number,arg2:number):Promise<void>;
 
 export function ResetDefaultConversationSettings():Promise<database.ConversationSetting>;
@@ -33,6 +61,12 @@ export function SaveSettings(arg1:database.Settings):Promise<database.Settings>;
 
 export function SendMessage(arg1:number,arg2:string):Promise<database.Message>;
 
+export function SendMessageFromTemplate(arg1:number,arg2:string):Promise<database.Message>;
+
 export function SetDefaultConversationSettings(arg1:database.CreateDefaultConversationSettingsParams):Promise<database.ConversationSetting>;
 
+export function SwitchBranch(arg1:number,arg2:number):Promise<void>;
+
 export function UpdateConversationSettings(arg1:database.UpdateConversationSettingsParams):Promise<database.ConversationSetting>;
+
+export function UpdateConversationTemplate(arg1:number,arg2:string):Promise<database.ConversationTemplate>;
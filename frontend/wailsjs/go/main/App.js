@@ -6,18 +6,50 @@ export function Approve(arg1, arg2) {
   return window['go']['main']['App']['Approve'](arg1, arg2);
 }
 
+export function ApproveAlways(arg1, arg2) {
+  return window['go']['main']['App']['ApproveAlways'](arg1, arg2);
+}
+
+export function ApproveWithEdits(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ApproveWithEdits'](arg1, arg2, arg3);
+}
+
 export function CancelGeneration(arg1) {
   return window['go']['main']['App']['CancelGeneration'](arg1);
 }
 
+export function CompareBranches(arg1, arg2, arg3) {
+  return window['go']['main']['App']['CompareBranches'](arg1, arg2, arg3);
+}
+
 export function Conversations() {
   return window['go']['main']['App']['Conversations']();
 }
 
+export function CreateConversationTemplate(arg1) {
+  return window['go']['main']['App']['CreateConversationTemplate'](arg1);
+}
+
 export function DeleteConversation(arg1) {
   return window['go']['main']['App']['DeleteConversation'](arg1);
 }
 
+export function DeleteConversationTemplate(arg1) {
+  return window['go']['main']['App']['DeleteConversationTemplate'](arg1);
+}
+
+export function EditMessage(arg1, arg2, arg3) {
+  return window['go']['main']['App']['EditMessage'](arg1, arg2, arg3);
+}
+
+export function ExportToolExecutions(arg1, arg2) {
+  return window['go']['main']['App']['ExportToolExecutions'](arg1, arg2);
+}
+
+export function GetAvailableProviders() {
+  return window['go']['main']['App']['GetAvailableProviders']();
+}
+
 export function GetAvailableTools() {
   return window['go']['main']['App']['GetAvailableTools']();
 }
@@ -42,10 +74,34 @@ export function ListApprovalRequests(arg1) {
   return window['go']['main']['App']['ListApprovalRequests'](arg1);
 }
 
+export function ListBranches(arg1) {
+  return window['go']['main']['App']['ListBranches'](arg1);
+}
+
+export function ListConversationTemplates() {
+  return window['go']['main']['App']['ListConversationTemplates']();
+}
+
+export function ListConversationToolExecutions(arg1) {
+  return window['go']['main']['App']['ListConversationToolExecutions'](arg1);
+}
+
+export function ListSubConversations(arg1) {
+  return window['go']['main']['App']['ListSubConversations'](arg1);
+}
+
+export function ListToolExecutions(arg1) {
+  return window['go']['main']['App']['ListToolExecutions'](arg1);
+}
+
 export function Messages(arg1) {
   return window['go']['main']['App']['Messages'](arg1);
 }
 
+export function Reject(arg1, arg2, arg3) {
+  return window['go']['main']['App']['Reject'](arg1, arg2, arg3);
+}
+
 export function RerunFromMessage(arg1, arg2) {
   return window['go']['main']['App']['RerunFromMessage'](arg1, arg2);
 }
@@ -62,10 +118,22 @@ export function SendMessage(arg1, arg2) {
   return window['go']['main']['App']['SendMessage'](arg1, arg2);
 }
 
+export function SendMessageFromTemplate(arg1, arg2) {
+  return window['go']['main']['App']['SendMessageFromTemplate'](arg1, arg2);
+}
+
 export function SetDefaultConversationSettings(arg1) {
   return window['go']['main']['App']['SetDefaultConversationSettings'](arg1);
 }
 
+export function SwitchBranch(arg1, arg2) {
+  return window['go']['main']['App']['SwitchBranch'](arg1, arg2);
+}
+
 export function UpdateConversationSettings(arg1) {
   return window['go']['main']['App']['UpdateConversationSettings'](arg1);
 }
+
+export function UpdateConversationTemplate(arg1, arg2) {
+  return window['go']['main']['App']['UpdateConversationTemplate'](arg1, arg2);
+}
@@ -7,6 +7,10 @@ export namespace database {
 	    // Go type: time
 	    lastMessageTime: any;
 	    generating: boolean;
+	    // Go type: sql
+	    activeMessageID: any;
+	    // Go type: sql
+	    parentMessageID: any;
 	
 	    static createFrom(source: any = {}) {
 	        return new Conversation(source);
@@ -19,6 +23,8 @@ export namespace database {
 	        this.title = source["title"];
 	        this.lastMessageTime = this.convertValues(source["lastMessageTime"], null);
 	        this.generating = source["generating"];
+	        this.activeMessageID = this.convertValues(source["activeMessageID"], null);
+	        this.parentMessageID = this.convertValues(source["parentMessageID"], null);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -39,12 +45,45 @@ export namespace database {
 		    return a;
 		}
 	}
+	export class SandboxSettings {
+	    enabled: boolean;
+	    readOnlyMounts: string[];
+	    readWriteMounts: string[];
+	    network: boolean;
+	    cpuSeconds: number;
+	    memoryMB: number;
+	    timeoutSeconds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SandboxSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.readOnlyMounts = source["readOnlyMounts"];
+	        this.readWriteMounts = source["readWriteMounts"];
+	        this.network = source["network"];
+	        this.cpuSeconds = source["cpuSeconds"];
+	        this.memoryMB = source["memoryMB"];
+	        this.timeoutSeconds = source["timeoutSeconds"];
+	    }
+	}
 	export class ConversationSetting {
 	    id: number;
 	    // Go type: sql
 	    isDefault: any;
 	    systemPromptTemplate: string;
 	    toolsEnabled: string[];
+	    provider: string;
+	    model: string;
+	    maxConsecutiveToolFailures: number;
+	    contextWindow: number;
+	    temperature: number;
+	    maxTokens: number;
+	    topP: number;
+	    stop: string[];
+	    sandbox: SandboxSettings;
 	
 	    static createFrom(source: any = {}) {
 	        return new ConversationSetting(source);
@@ -56,6 +95,83 @@ export namespace database {
 	        this.isDefault = this.convertValues(source["isDefault"], null);
 	        this.systemPromptTemplate = source["systemPromptTemplate"];
 	        this.toolsEnabled = source["toolsEnabled"];
+	        this.provider = source["provider"];
+	        this.model = source["model"];
+	        this.maxConsecutiveToolFailures = source["maxConsecutiveToolFailures"];
+	        this.contextWindow = source["contextWindow"];
+	        this.temperature = source["temperature"];
+	        this.maxTokens = source["maxTokens"];
+	        this.topP = source["topP"];
+	        this.stop = source["stop"];
+	        this.sandbox = this.convertValues(source["sandbox"], SandboxSettings);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ConversationTemplate {
+	    id: number;
+	    name: string;
+	    conversationSettingsID: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConversationTemplate(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.conversationSettingsID = source["conversationSettingsID"];
+	    }
+	}
+	export class MCPServer {
+	    name: string;
+	    command: string;
+	    env: string[];
+	    url: string;
+	    headers: string[];
+	    requireApproval: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new MCPServer(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.command = source["command"];
+	        this.env = source["env"];
+	        this.url = source["url"];
+	        this.headers = source["headers"];
+	        this.requireApproval = source["requireApproval"];
+	    }
+	}
+	export class MCPSettings {
+	    servers: MCPServer[];
+	
+	    static createFrom(source: any = {}) {
+	        return new MCPSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.servers = this.convertValues(source["servers"], MCPServer);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -76,9 +192,104 @@ export namespace database {
 		    return a;
 		}
 	}
+	export class ApprovalRule {
+	    tool: string;
+	    arguments: {[key: string]: string};
+	    decision: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ApprovalRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.tool = source["tool"];
+	        this.arguments = source["arguments"];
+	        this.decision = source["decision"];
+	    }
+	}
+	export class ApprovalSettings {
+	    rules: ApprovalRule[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ApprovalSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.rules = this.convertValues(source["rules"], ApprovalRule);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class FilesSettings {
+	    workspacesDirectory: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new FilesSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.workspacesDirectory = source["workspacesDirectory"];
+	    }
+	}
+	export class AssistantSettings {
+	    maxDepth: number;
+	    maxSteps: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AssistantSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.maxDepth = source["maxDepth"];
+	        this.maxSteps = source["maxSteps"];
+	    }
+	}
+	export class AnthropicSettings {
+	    apiKey: string;
+	    baseUrl: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AnthropicSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.apiKey = source["apiKey"];
+	        this.baseUrl = source["baseUrl"];
+	    }
+	}
 	export class CreateDefaultConversationSettingsParams {
 	    systemPromptTemplate: string;
 	    toolsEnabled: string[];
+	    provider: string;
+	    model: string;
+	    maxConsecutiveToolFailures: number;
+	    contextWindow: number;
+	    temperature: number;
+	    maxTokens: number;
+	    topP: number;
+	    stop: string[];
+	    sandbox: SandboxSettings;
 	
 	    static createFrom(source: any = {}) {
 	        return new CreateDefaultConversationSettingsParams(source);
@@ -88,6 +299,49 @@ export namespace database {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.systemPromptTemplate = source["systemPromptTemplate"];
 	        this.toolsEnabled = source["toolsEnabled"];
+	        this.provider = source["provider"];
+	        this.model = source["model"];
+	        this.maxConsecutiveToolFailures = source["maxConsecutiveToolFailures"];
+	        this.contextWindow = source["contextWindow"];
+	        this.temperature = source["temperature"];
+	        this.maxTokens = source["maxTokens"];
+	        this.topP = source["topP"];
+	        this.stop = source["stop"];
+	        this.sandbox = this.convertValues(source["sandbox"], SandboxSettings);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class DuckDBSettings {
+	    executablePath: string;
+	    directories: string[];
+	    maxRows: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DuckDBSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.executablePath = source["executablePath"];
+	        this.directories = source["directories"];
+	        this.maxRows = source["maxRows"];
 	    }
 	}
 	export class GoogleCustomSearchSettings {
@@ -104,11 +358,32 @@ export namespace database {
 	        this.googleCloudApiKey = source["googleCloudApiKey"];
 	    }
 	}
+	export class ToolCall {
+	    id: string;
+	    tool: string;
+	    args: {[key: string]: any};
+	
+	    static createFrom(source: any = {}) {
+	        return new ToolCall(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.tool = source["tool"];
+	        this.args = source["args"];
+	    }
+	}
 	export class Message {
 	    id: number;
 	    conversationID: number;
 	    content: string;
 	    author: string;
+	    toolCalls: ToolCall[];
+	    toolCallID: string;
+	    contextStatus: string;
+	    // Go type: sql
+	    parentMessageID: any;
 	
 	    static createFrom(source: any = {}) {
 	        return new Message(source);
@@ -120,6 +395,56 @@ export namespace database {
 	        this.conversationID = source["conversationID"];
 	        this.content = source["content"];
 	        this.author = source["author"];
+	        this.toolCalls = this.convertValues(source["toolCalls"], ToolCall);
+	        this.toolCallID = source["toolCallID"];
+	        this.contextStatus = source["contextStatus"];
+	        this.parentMessageID = this.convertValues(source["parentMessageID"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class OllamaSettings {
+	    baseUrl: string;
+	    functionCalling: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new OllamaSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.baseUrl = source["baseUrl"];
+	        this.functionCalling = source["functionCalling"];
+	    }
+	}
+	export class OpenAISettings {
+	    baseUrl: string;
+	    functionCalling: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new OpenAISettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.baseUrl = source["baseUrl"];
+	        this.functionCalling = source["functionCalling"];
 	    }
 	}
 	export class PythonSettings {
@@ -134,6 +459,54 @@ export namespace database {
 	        this.interpreterPath = source["interpreterPath"];
 	    }
 	}
+	export class SQLDatabase {
+	    name: string;
+	    driver: string;
+	    dsn: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SQLDatabase(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.driver = source["driver"];
+	        this.dsn = source["dsn"];
+	    }
+	}
+	export class SQLSettings {
+	    databases: SQLDatabase[];
+	    maxRows: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new SQLSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.databases = this.convertValues(source["databases"], SQLDatabase);
+	        this.maxRows = source["maxRows"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class SearchSettings {
 	    googleCustomSearch: GoogleCustomSearchSettings;
 	
@@ -178,10 +551,20 @@ export namespace database {
 	}
 	export class Settings {
 	    openAiApiKey: string;
+	    provider: string;
 	    model: string;
+	    openAi: OpenAISettings;
+	    anthropic: AnthropicSettings;
+	    ollama: OllamaSettings;
 	    terminal: TerminalSettings;
 	    search: SearchSettings;
 	    python: PythonSettings;
+	    sql: SQLSettings;
+	    duckDb: DuckDBSettings;
+	    assistant: AssistantSettings;
+	    files: FilesSettings;
+	    mcp: MCPSettings;
+	    approvals: ApprovalSettings;
 	
 	    static createFrom(source: any = {}) {
 	        return new Settings(source);
@@ -190,10 +573,20 @@ export namespace database {
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.openAiApiKey = source["openAiApiKey"];
+	        this.provider = source["provider"];
 	        this.model = source["model"];
+	        this.openAi = this.convertValues(source["openAi"], OpenAISettings);
+	        this.anthropic = this.convertValues(source["anthropic"], AnthropicSettings);
+	        this.ollama = this.convertValues(source["ollama"], OllamaSettings);
 	        this.terminal = this.convertValues(source["terminal"], TerminalSettings);
 	        this.search = this.convertValues(source["search"], SearchSettings);
 	        this.python = this.convertValues(source["python"], PythonSettings);
+	        this.sql = this.convertValues(source["sql"], SQLSettings);
+	        this.duckDb = this.convertValues(source["duckDb"], DuckDBSettings);
+	        this.assistant = this.convertValues(source["assistant"], AssistantSettings);
+	        this.files = this.convertValues(source["files"], FilesSettings);
+	        this.mcp = this.convertValues(source["mcp"], MCPSettings);
+	        this.approvals = this.convertValues(source["approvals"], ApprovalSettings);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -215,9 +608,79 @@ export namespace database {
 		}
 	}
 	
+	export class ToolExecution {
+	    id: number;
+	    conversationID: number;
+	    messageID: number;
+	    toolCallID: string;
+	    tool: string;
+	    args: {[key: string]: any};
+	    approval: string;
+	    approvedBy: string;
+	    status: string;
+	    // Go type: time
+	    startedAt: any;
+	    // Go type: sql
+	    finishedAt: any;
+	    // Go type: sql
+	    exitCode: any;
+	    result: string;
+	    output: string;
+	    error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ToolExecution(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.conversationID = source["conversationID"];
+	        this.messageID = source["messageID"];
+	        this.toolCallID = source["toolCallID"];
+	        this.tool = source["tool"];
+	        this.args = source["args"];
+	        this.approval = source["approval"];
+	        this.approvedBy = source["approvedBy"];
+	        this.status = source["status"];
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	        this.finishedAt = this.convertValues(source["finishedAt"], null);
+	        this.exitCode = this.convertValues(source["exitCode"], null);
+	        this.result = source["result"];
+	        this.output = source["output"];
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class UpdateConversationSettingsParams {
 	    systemPromptTemplate: string;
 	    toolsEnabled: string[];
+	    provider: string;
+	    model: string;
+	    maxConsecutiveToolFailures: number;
+	    contextWindow: number;
+	    temperature: number;
+	    maxTokens: number;
+	    topP: number;
+	    stop: string[];
+	    sandbox: SandboxSettings;
 	    id: number;
 	
 	    static createFrom(source: any = {}) {
@@ -228,8 +691,35 @@ export namespace database {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.systemPromptTemplate = source["systemPromptTemplate"];
 	        this.toolsEnabled = source["toolsEnabled"];
+	        this.provider = source["provider"];
+	        this.model = source["model"];
+	        this.maxConsecutiveToolFailures = source["maxConsecutiveToolFailures"];
+	        this.contextWindow = source["contextWindow"];
+	        this.temperature = source["temperature"];
+	        this.maxTokens = source["maxTokens"];
+	        this.topP = source["topP"];
+	        this.stop = source["stop"];
+	        this.sandbox = this.convertValues(source["sandbox"], SandboxSettings);
 	        this.id = source["id"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 
 }
@@ -239,6 +729,16 @@ export namespace main {
 	export class ApprovalRequest {
 	    id: string;
 	    message: string;
+	    conversationID: number;
+	    toolID?: string;
+	    toolName?: string;
+	    args?: {[key: string]: any};
+	    workingDirectory?: string;
+	    risk?: string;
+	    riskReason?: string;
+	    preview?: string;
+	    previewType?: string;
+	    canApproveAlways: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new ApprovalRequest(source);
@@ -248,6 +748,30 @@ export namespace main {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.id = source["id"];
 	        this.message = source["message"];
+	        this.conversationID = source["conversationID"];
+	        this.toolID = source["toolID"];
+	        this.toolName = source["toolName"];
+	        this.args = source["args"];
+	        this.workingDirectory = source["workingDirectory"];
+	        this.risk = source["risk"];
+	        this.riskReason = source["riskReason"];
+	        this.preview = source["preview"];
+	        this.previewType = source["previewType"];
+	        this.canApproveAlways = source["canApproveAlways"];
+	    }
+	}
+	export class AvailableProvider {
+	    name: string;
+	    ID: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new AvailableProvider(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.ID = source["ID"];
 	    }
 	}
 	export class AvailableTool {
@@ -264,6 +788,78 @@ export namespace main {
 	        this.ID = source["ID"];
 	    }
 	}
+	export class Branch {
+	    leafMessageID: number;
+	    forkMessageID: number;
+	    length: number;
+	    lastMessage: database.Message;
+	    active: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Branch(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.leafMessageID = source["leafMessageID"];
+	        this.forkMessageID = source["forkMessageID"];
+	        this.length = source["length"];
+	        this.lastMessage = this.convertValues(source["lastMessage"], database.Message);
+	        this.active = source["active"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class BranchComparison {
+	    common: database.Message[];
+	    a: database.Message[];
+	    b: database.Message[];
+	
+	    static createFrom(source: any = {}) {
+	        return new BranchComparison(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.common = this.convertValues(source["common"], database.Message);
+	        this.a = this.convertValues(source["a"], database.Message);
+	        this.b = this.convertValues(source["b"], database.Message);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 
 }
 
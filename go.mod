@@ -5,8 +5,12 @@ go 1.18
 require (
 	github.com/Andrew-peng/go-dalle2 v0.1.0
 	github.com/PuerkitoBio/goquery v1.8.1
+	github.com/go-sql-driver/mysql v1.7.1
+	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51
+	github.com/lib/pq v1.10.9
 	github.com/mitchellh/go-homedir v1.1.0
-	github.com/sashabaranov/go-openai v1.5.8
+	github.com/pmezard/go-difflib v1.0.0
+	github.com/sashabaranov/go-openai v1.20.4
 	github.com/trietmn/go-wiki v1.0.0
 	github.com/wailsapp/wails/v2 v2.4.1
 	golang.org/x/exp v0.0.0-20220303212507-bbda1eaf7a17
@@ -29,7 +33,6 @@ require (
 	github.com/googleapis/enterprise-certificate-proxy v0.2.3 // indirect
 	github.com/googleapis/gax-go/v2 v2.8.0 // indirect
 	github.com/jchv/go-winloader v0.0.0-20210711035445-715c2860da7e // indirect
-	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
 	github.com/labstack/echo/v4 v4.9.0 // indirect
 	github.com/labstack/gommon v0.3.1 // indirect
 	github.com/leaanthony/go-ansi-parser v1.0.1 // indirect
@@ -0,0 +1,253 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"cuttlefish/database"
+	"cuttlefish/llm"
+)
+
+const defaultBaseURL = "https://api.anthropic.com"
+
+// The Messages API requires max_tokens to be set.
+const defaultMaxTokens = 1024
+
+type Provider struct {
+}
+
+func (p *Provider) Name() string {
+	return "Anthropic"
+}
+
+func (p *Provider) Instantiate(settings database.Settings) (llm.Client, error) {
+	baseURL := settings.Anthropic.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &Client{
+		apiKey:  settings.Anthropic.APIKey,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+type Client struct {
+	apiKey  string
+	baseURL string
+}
+
+func (c *Client) SupportsFunctionCalling(model string) bool {
+	return !strings.HasPrefix(model, "claude-2") && !strings.HasPrefix(model, "claude-instant")
+}
+
+type messagesRequest struct {
+	Model         string    `json:"model"`
+	System        string    `json:"system,omitempty"`
+	Messages      []message `json:"messages"`
+	Tools         []tool    `json:"tools,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+	MaxTokens     int       `json:"max_tokens"`
+	Temperature   *float32  `json:"temperature,omitempty"`
+	TopP          *float32  `json:"top_p,omitempty"`
+	Stream        bool      `json:"stream"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type contentBlock struct {
+	Type string `json:"type"`
+	// Set for text blocks.
+	Text string `json:"text,omitempty"`
+	// Set for tool_use blocks.
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+	// Set for tool_result blocks.
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+type tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	body := messagesRequest{
+		Model:         req.Model,
+		StopSequences: req.Stop,
+		MaxTokens:     req.MaxTokens,
+		Stream:        true,
+	}
+	if body.MaxTokens == 0 {
+		body.MaxTokens = defaultMaxTokens
+	}
+	body.Temperature = &req.Temperature
+	if req.TopP != 0 && req.TopP != 1 {
+		// A top_p of 1 is a no-op, and some models reject requests setting both temperature and top_p.
+		body.TopP = &req.TopP
+	}
+	for _, msg := range req.Messages {
+		var role string
+		var blocks []contentBlock
+		switch msg.Role {
+		case llm.RoleSystem:
+			if body.System != "" {
+				body.System += "\n\n"
+			}
+			body.System += msg.Content
+			continue
+		case llm.RoleAssistant:
+			role = "assistant"
+			if strings.TrimSpace(msg.Content) != "" {
+				// Anthropic rejects assistant messages ending with whitespace.
+				blocks = append(blocks, contentBlock{Type: "text", Text: strings.TrimRight(msg.Content, " \t\n")})
+			}
+			for _, toolCall := range msg.ToolCalls {
+				input := json.RawMessage(toolCall.Arguments)
+				if strings.TrimSpace(toolCall.Arguments) == "" {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, contentBlock{Type: "tool_use", ID: toolCall.ID, Name: toolCall.Name, Input: input})
+			}
+		case llm.RoleTool:
+			role = "user"
+			blocks = append(blocks, contentBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: msg.Content})
+		default:
+			role = "user"
+			blocks = append(blocks, contentBlock{Type: "text", Text: msg.Content})
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		// Roles have to alternate, so consecutive messages by the same role get merged.
+		if len(body.Messages) > 0 && body.Messages[len(body.Messages)-1].Role == role {
+			body.Messages[len(body.Messages)-1].Content = append(body.Messages[len(body.Messages)-1].Content, blocks...)
+			continue
+		}
+		body.Messages = append(body.Messages, message{Role: role, Content: blocks})
+	}
+	for _, t := range req.Tools {
+		body.Tools = append(body.Tools, tool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: t.Parameters,
+		})
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create request: %w", err)
+	}
+	httpReq.Header.Set("content-type", "application/json")
+	httpReq.Header.Set("x-api-key", c.apiKey)
+	httpReq.Header.Set("anthropic-version", "2023-06-01")
+
+	res, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't send request: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		errBody, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status code %d: %s", res.StatusCode, string(errBody))
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &ChatCompletionStream{
+		body:    res.Body,
+		scanner: scanner,
+		blocks:  map[int]*llm.ToolCall{},
+	}, nil
+}
+
+type event struct {
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type ChatCompletionStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	// Tool use blocks in progress, keyed by their content block index.
+	blocks map[int]*llm.ToolCall
+}
+
+func (s *ChatCompletionStream) Recv() (llm.ChatCompletionChunk, error) {
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var e event
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &e); err != nil {
+			return llm.ChatCompletionChunk{}, fmt.Errorf("couldn't decode event: %w", err)
+		}
+		switch e.Type {
+		case "content_block_start":
+			if e.ContentBlock.Type == "tool_use" {
+				s.blocks[e.Index] = &llm.ToolCall{
+					ID:   e.ContentBlock.ID,
+					Name: e.ContentBlock.Name,
+				}
+			}
+		case "content_block_delta":
+			switch e.Delta.Type {
+			case "text_delta":
+				if e.Delta.Text != "" {
+					return llm.ChatCompletionChunk{Content: e.Delta.Text}, nil
+				}
+			case "input_json_delta":
+				if toolCall, ok := s.blocks[e.Index]; ok {
+					toolCall.Arguments += e.Delta.PartialJSON
+				}
+			}
+		case "content_block_stop":
+			if toolCall, ok := s.blocks[e.Index]; ok {
+				delete(s.blocks, e.Index)
+				return llm.ChatCompletionChunk{ToolCalls: []llm.ToolCall{*toolCall}}, nil
+			}
+		case "message_stop":
+			return llm.ChatCompletionChunk{}, io.EOF
+		case "error":
+			return llm.ChatCompletionChunk{}, fmt.Errorf("%s: %s", e.Error.Type, e.Error.Message)
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return llm.ChatCompletionChunk{}, err
+	}
+	return llm.ChatCompletionChunk{}, io.EOF
+}
+
+func (s *ChatCompletionStream) Close() error {
+	return s.body.Close()
+}
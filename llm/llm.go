@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+
+	"cuttlefish/database"
+)
+
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+type Provider interface {
+	Name() string
+	Instantiate(settings database.Settings) (Client, error)
+}
+
+type Client interface {
+	// SupportsFunctionCalling reports whether the model can receive tools as native function definitions.
+	// Other models fall back to the text-based protocol described in the system prompt.
+	SupportsFunctionCalling(model string) bool
+	CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error)
+}
+
+type ChatCompletionRequest struct {
+	Model     string
+	Messages  []Message
+	Tools     []ToolDefinition
+	Stop      []string
+	MaxTokens int
+	// Always sent, so 0 means deterministic sampling.
+	Temperature float32
+	// 0 means the provider's default.
+	TopP float32
+}
+
+type Message struct {
+	Role    string
+	Content string
+	// ToolCalls is set on assistant messages that call tools.
+	ToolCalls []ToolCall
+	// ToolCallID is set on tool messages, referencing the tool call they respond to.
+	ToolCallID string
+}
+
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string // JSON-encoded
+}
+
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{} // JSON Schema
+}
+
+type ChatCompletionStream interface {
+	// Recv returns io.EOF once the stream is finished.
+	Recv() (ChatCompletionChunk, error)
+	Close() error
+}
+
+type ChatCompletionChunk struct {
+	Content string
+	// ToolCalls are only returned once they're complete.
+	ToolCalls []ToolCall
+}
@@ -0,0 +1,199 @@
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"cuttlefish/database"
+	"cuttlefish/llm"
+)
+
+const defaultBaseURL = "http://localhost:11434"
+
+type Provider struct {
+}
+
+func (p *Provider) Name() string {
+	return "Ollama"
+}
+
+func (p *Provider) Instantiate(settings database.Settings) (llm.Client, error) {
+	baseURL := settings.Ollama.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+	return &Client{
+		baseURL:         strings.TrimSuffix(baseURL, "/"),
+		functionCalling: settings.Ollama.FunctionCalling,
+	}, nil
+}
+
+type Client struct {
+	baseURL         string
+	functionCalling bool
+}
+
+func (c *Client) SupportsFunctionCalling(model string) bool {
+	// Only some local models support tools, so this is left to the user.
+	return c.functionCalling
+}
+
+type chatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []message              `json:"messages"`
+	Tools    []tool                 `json:"tools,omitempty"`
+	Stream   bool                   `json:"stream"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+type message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+}
+
+type toolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type tool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description"`
+		Parameters  map[string]interface{} `json:"parameters"`
+	} `json:"function"`
+}
+
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	body := chatRequest{
+		Model:  req.Model,
+		Stream: true,
+		Options: map[string]interface{}{
+			"temperature": req.Temperature,
+		},
+	}
+	if len(req.Stop) > 0 {
+		body.Options["stop"] = req.Stop
+	}
+	if req.MaxTokens != 0 {
+		body.Options["num_predict"] = req.MaxTokens
+	}
+	if req.TopP != 0 {
+		body.Options["top_p"] = req.TopP
+	}
+	for _, msg := range req.Messages {
+		ollamaMessage := message{
+			Role:    msg.Role,
+			Content: msg.Content,
+		}
+		for _, call := range msg.ToolCalls {
+			var ollamaToolCall toolCall
+			ollamaToolCall.Function.Name = call.Name
+			ollamaToolCall.Function.Arguments = json.RawMessage(call.Arguments)
+			if strings.TrimSpace(call.Arguments) == "" {
+				ollamaToolCall.Function.Arguments = json.RawMessage("{}")
+			}
+			ollamaMessage.ToolCalls = append(ollamaMessage.ToolCalls, ollamaToolCall)
+		}
+		body.Messages = append(body.Messages, ollamaMessage)
+	}
+	for _, t := range req.Tools {
+		var ollamaTool tool
+		ollamaTool.Type = "function"
+		ollamaTool.Function.Name = t.Name
+		ollamaTool.Function.Description = t.Description
+		ollamaTool.Function.Parameters = t.Parameters
+		body.Tools = append(body.Tools, ollamaTool)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/chat", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	res, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't send request: %w", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		errBody, _ := io.ReadAll(res.Body)
+		return nil, fmt.Errorf("unexpected status code %d: %s", res.StatusCode, string(errBody))
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &ChatCompletionStream{
+		body:    res.Body,
+		scanner: scanner,
+	}, nil
+}
+
+type chatResponse struct {
+	Message message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error"`
+}
+
+type ChatCompletionStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	// Ollama doesn't assign IDs to tool calls, so we number them ourselves.
+	toolCallCount int
+}
+
+func (s *ChatCompletionStream) Recv() (llm.ChatCompletionChunk, error) {
+	for s.scanner.Scan() {
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" {
+			continue
+		}
+		var res chatResponse
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			return llm.ChatCompletionChunk{}, fmt.Errorf("couldn't decode response: %w", err)
+		}
+		if res.Error != "" {
+			return llm.ChatCompletionChunk{}, fmt.Errorf("ollama error: %s", res.Error)
+		}
+
+		var chunk llm.ChatCompletionChunk
+		chunk.Content = res.Message.Content
+		for _, call := range res.Message.ToolCalls {
+			s.toolCallCount++
+			chunk.ToolCalls = append(chunk.ToolCalls, llm.ToolCall{
+				ID:        fmt.Sprintf("call_%d", s.toolCallCount),
+				Name:      call.Function.Name,
+				Arguments: string(call.Function.Arguments),
+			})
+		}
+		if chunk.Content != "" || len(chunk.ToolCalls) > 0 {
+			return chunk, nil
+		}
+		if res.Done {
+			return llm.ChatCompletionChunk{}, io.EOF
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return llm.ChatCompletionChunk{}, err
+	}
+	return llm.ChatCompletionChunk{}, io.EOF
+}
+
+func (s *ChatCompletionStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,169 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	gogpt "github.com/sashabaranov/go-openai"
+
+	"cuttlefish/database"
+	"cuttlefish/llm"
+)
+
+type Provider struct {
+}
+
+func (p *Provider) Name() string {
+	return "OpenAI"
+}
+
+func (p *Provider) Instantiate(settings database.Settings) (llm.Client, error) {
+	config := gogpt.DefaultConfig(settings.OpenAIAPIKey)
+	if settings.OpenAI.BaseURL != "" {
+		// I.e. a llama.cpp server, or any other OpenAI-compatible one.
+		config.BaseURL = settings.OpenAI.BaseURL
+	}
+	return &Client{
+		cli:             gogpt.NewClientWithConfig(config),
+		customServer:    settings.OpenAI.BaseURL != "",
+		functionCalling: settings.OpenAI.FunctionCalling,
+	}, nil
+}
+
+type Client struct {
+	cli             *gogpt.Client
+	customServer    bool
+	functionCalling bool
+}
+
+func (c *Client) SupportsFunctionCalling(model string) bool {
+	if c.customServer {
+		return c.functionCalling
+	}
+	if strings.HasSuffix(model, "-0301") || strings.HasSuffix(model, "-0314") {
+		return false
+	}
+	return strings.HasPrefix(model, "gpt-3.5-turbo") || strings.HasPrefix(model, "gpt-4")
+}
+
+func (c *Client) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	gptReq := gogpt.ChatCompletionRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+	}
+	if gptReq.Temperature == 0 {
+		// A zero temperature gets omitted from the request, which means the default of 1.
+		gptReq.Temperature = math.SmallestNonzeroFloat32
+	}
+	for _, message := range req.Messages {
+		gptMessage := gogpt.ChatCompletionMessage{
+			Role:       message.Role,
+			Content:    message.Content,
+			ToolCallID: message.ToolCallID,
+		}
+		for _, toolCall := range message.ToolCalls {
+			gptMessage.ToolCalls = append(gptMessage.ToolCalls, gogpt.ToolCall{
+				ID:   toolCall.ID,
+				Type: gogpt.ToolTypeFunction,
+				Function: gogpt.FunctionCall{
+					Name:      toolCall.Name,
+					Arguments: toolCall.Arguments,
+				},
+			})
+		}
+		gptReq.Messages = append(gptReq.Messages, gptMessage)
+	}
+	for _, tool := range req.Tools {
+		gptReq.Tools = append(gptReq.Tools, gogpt.Tool{
+			Type: gogpt.ToolTypeFunction,
+			Function: &gogpt.FunctionDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+
+	stream, err := c.cli.CreateChatCompletionStream(ctx, gptReq)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create chat completion stream: %w", err)
+	}
+	return &ChatCompletionStream{
+		stream: stream,
+	}, nil
+}
+
+type ChatCompletionStream struct {
+	stream *gogpt.ChatCompletionStream
+	// Tool calls are streamed in fragments, keyed by their index.
+	toolCalls []llm.ToolCall
+	done      bool
+}
+
+func (s *ChatCompletionStream) Recv() (llm.ChatCompletionChunk, error) {
+	if s.done {
+		return llm.ChatCompletionChunk{}, io.EOF
+	}
+	for {
+		res, err := s.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			s.done = true
+			if len(s.toolCalls) > 0 {
+				return llm.ChatCompletionChunk{ToolCalls: s.toolCalls}, nil
+			}
+			return llm.ChatCompletionChunk{}, io.EOF
+		} else if err != nil {
+			return llm.ChatCompletionChunk{}, err
+		}
+		if len(res.Choices) == 0 {
+			continue
+		}
+
+		delta := res.Choices[0].Delta
+		for _, toolCall := range delta.ToolCalls {
+			index := s.toolCallIndex(toolCall)
+			for index >= len(s.toolCalls) {
+				s.toolCalls = append(s.toolCalls, llm.ToolCall{})
+			}
+			if toolCall.ID != "" {
+				s.toolCalls[index].ID = toolCall.ID
+			}
+			if toolCall.Function.Name != "" {
+				s.toolCalls[index].Name = toolCall.Function.Name
+			}
+			s.toolCalls[index].Arguments += toolCall.Function.Arguments
+		}
+		if delta.Content == "" {
+			continue
+		}
+		return llm.ChatCompletionChunk{Content: delta.Content}, nil
+	}
+}
+
+// toolCallIndex returns the index of the tool call the fragment belongs to. Some OpenAI-compatible servers don't send indices,
+// in which case a fragment continues the last tool call, unless it starts a new one with its own ID or function name.
+func (s *ChatCompletionStream) toolCallIndex(toolCall gogpt.ToolCall) int {
+	if toolCall.Index != nil {
+		return *toolCall.Index
+	}
+	if len(s.toolCalls) == 0 {
+		return 0
+	}
+	last := s.toolCalls[len(s.toolCalls)-1]
+	if (toolCall.ID != "" && last.ID != "" && toolCall.ID != last.ID) || (toolCall.Function.Name != "" && last.Name != "") {
+		return len(s.toolCalls)
+	}
+	return len(s.toolCalls) - 1
+}
+
+func (s *ChatCompletionStream) Close() error {
+	s.stream.Close()
+	return nil
+}
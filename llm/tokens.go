@@ -0,0 +1,109 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// Per-message overhead of the chat format, as documented by OpenAI.
+const tokensPerMessage = 4
+
+const defaultContextWindow = 4096
+
+// Ordered so that more specific prefixes come first.
+var contextWindows = []struct {
+	modelPrefix string
+	tokens      int
+}{
+	{"gpt-3.5-turbo-16k", 16385},
+	{"gpt-3.5-turbo-1106", 16385},
+	{"gpt-3.5-turbo-0125", 16385},
+	{"gpt-3.5-turbo", 4096},
+	{"gpt-4-32k", 32768},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-1106", 128000},
+	{"gpt-4-0125", 128000},
+	{"gpt-4o", 128000},
+	{"gpt-4.1", 1047576},
+	{"gpt-4", 8192},
+	{"claude-2", 100000},
+	{"claude-instant", 100000},
+	{"claude", 200000},
+	{"llama3", 8192},
+	{"mistral", 32768},
+}
+
+// ContextWindow returns the number of tokens the model can handle, prompt and completion combined.
+// Unknown models, i.e. most local ones, get a conservative default.
+func ContextWindow(model string) int {
+	for _, contextWindow := range contextWindows {
+		if strings.HasPrefix(model, contextWindow.modelPrefix) {
+			return contextWindow.tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+// EstimateTokens approximates the number of tokens in the text using the rules of thumb of BPE tokenizers:
+// words are split into ~4 character tokens, numbers into ~3 digit tokens, and each other symbol is a token.
+// It errs on the side of overestimating, as going over the context window fails the request.
+func EstimateTokens(text string) int {
+	tokens := 0
+	var runLength int
+	var runIsDigit bool
+	flush := func() {
+		if runLength == 0 {
+			return
+		}
+		perToken := 4
+		if runIsDigit {
+			perToken = 3
+		}
+		tokens += (runLength + perToken - 1) / perToken
+		runLength = 0
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsLetter(r) && r < unicode.MaxLatin1:
+			if runIsDigit {
+				flush()
+			}
+			runIsDigit = false
+			runLength++
+		case unicode.IsDigit(r):
+			if !runIsDigit {
+				flush()
+			}
+			runIsDigit = true
+			runLength++
+		default:
+			// Punctuation, symbols, and non-latin characters usually end up being separate tokens.
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// EstimateRequestTokens approximates the number of prompt tokens the request will use.
+func EstimateRequestTokens(messages []Message, tools []ToolDefinition) int {
+	tokens := 3 // Every reply is primed with the assistant role.
+	for _, message := range messages {
+		tokens += tokensPerMessage
+		tokens += EstimateTokens(message.Content)
+		for _, toolCall := range message.ToolCalls {
+			tokens += EstimateTokens(toolCall.Name) + EstimateTokens(toolCall.Arguments)
+		}
+	}
+	if len(tools) > 0 {
+		data, err := json.Marshal(tools)
+		if err == nil {
+			tokens += EstimateTokens(string(data))
+		}
+	}
+	return tokens
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"embed"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 
 	"github.com/mitchellh/go-homedir"
 	"github.com/wailsapp/wails/v2"
@@ -24,6 +29,11 @@ import (
 var assets embed.FS
 
 func main() {
+	serve := flag.Bool("serve", false, "Run a headless HTTP/JSON API server instead of the desktop app.")
+	addr := flag.String("addr", "127.0.0.1:8080", "Address for the API server to listen on.")
+	token := flag.String("token", os.Getenv("CUTTLEFISH_API_TOKEN"), "Bearer token required by the API server. Defaults to $CUTTLEFISH_API_TOKEN.")
+	flag.Parse()
+
 	ctx := context.Background()
 
 	userHomedir, err := homedir.Dir()
@@ -51,8 +61,39 @@ func main() {
 
 	queries := database.New(db)
 
+	if flag.Arg(0) == "chat" {
+		if err := runChat(ctx, queries, flag.Args()[1:]); err != nil {
+			log.Fatalln("chat failed:", err)
+		}
+		return
+	}
+
+	if *serve {
+		ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		if *token == "" {
+			// Without a token, any web page the user visits could use the API, and with it the terminal.
+			generated := make([]byte, 24)
+			if _, err := rand.Read(generated); err != nil {
+				log.Fatalln("could not generate api token:", err)
+			}
+			*token = hex.EncodeToString(generated)
+			log.Printf("No API token set, so this one was generated, use --token or $CUTTLEFISH_API_TOKEN to set your own: %s", *token)
+		}
+		events := NewEventBroker()
+		app := NewApp(ctx, queries, events)
+		err := NewServer(app, events, *token).Serve(ctx, *addr)
+		app.shutdown(ctx)
+		if err != nil {
+			log.Fatalln("could not run api server:", err)
+		}
+		return
+	}
+
 	// Create an instance of the app structure
-	app := NewApp(ctx, queries)
+	events := &WailsEventEmitter{}
+	app := NewApp(ctx, queries, events)
 
 	// Create application with options
 	err = wails.Run(&options.App{
@@ -63,7 +104,11 @@ func main() {
 			Assets: assets,
 		},
 		BackgroundColour: &options.RGBA{R: 27, G: 38, B: 54, A: 1},
-		OnStartup:        app.startup,
+		OnStartup: func(ctx context.Context) {
+			events.ctx = ctx
+			app.startup(ctx)
+		},
+		OnShutdown: app.shutdown,
 		Bind: []interface{}{
 			app,
 		},
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+	"cuttlefish/tools/mcp"
+)
+
+// The tools of the MCP servers configured in the settings are found by connecting to each server when the app starts,
+// and again whenever the settings are saved. They're then available next to the built-in tools.
+
+// Servers started with i.e. npx may have to be downloaded first.
+const mcpDiscoveryTimeout = time.Minute
+
+// refreshMCPTools looks up the tools of the MCP servers in the background, replacing the ones found before.
+func (a *App) refreshMCPTools(servers []database.MCPServer) {
+	loaded := make(chan struct{})
+	a.m.Lock()
+	a.mcpToolsLoaded = loaded
+	ctx := a.ctx
+	a.m.Unlock()
+
+	go func() {
+		defer close(loaded)
+		ctx, cancel := context.WithTimeout(ctx, mcpDiscoveryTimeout)
+		defer cancel()
+
+		var m sync.Mutex
+		found := map[string]tools.Tool{}
+		var wg sync.WaitGroup
+		for _, config := range servers {
+			config := config
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				serverTools, err := mcp.NewServer(config).Tools(ctx)
+				if err != nil {
+					err = fmt.Errorf("couldn't get tools of mcp server `%s`: %w", config.Name, err)
+					log.Println(err)
+					a.events.Emit("async-error", err.Error())
+					return
+				}
+				m.Lock()
+				defer m.Unlock()
+				for _, tool := range serverTools {
+					// Different names can end up as the same ID once they're sanitized, in which case the one found first is kept.
+					if other, ok := found[tool.ID()]; ok {
+						err := fmt.Errorf("mcp tool `%s` has the same id `%s` as `%s`, so it's skipped", tool.Name(), tool.ID(), other.Name())
+						log.Println(err)
+						a.events.Emit("async-error", err.Error())
+						continue
+					}
+					found[tool.ID()] = tool
+				}
+			}()
+		}
+		wg.Wait()
+
+		a.m.Lock()
+		defer a.m.Unlock()
+		// Unless the settings got saved again in the meantime, and these are already outdated.
+		if a.mcpToolsLoaded == loaded {
+			a.mcpTools = found
+		}
+	}()
+}
+
+// waitForMCPTools waits for the MCP servers' tools to be found, so a conversation using them doesn't start without them.
+func (a *App) waitForMCPTools(ctx context.Context) error {
+	a.m.Lock()
+	loaded := a.mcpToolsLoaded
+	a.m.Unlock()
+	select {
+	case <-loaded:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// availableTools returns the built-in tools along with the MCP servers' tools, by ID.
+func (a *App) availableTools() map[string]tools.Tool {
+	a.m.Lock()
+	defer a.m.Unlock()
+	out := make(map[string]tools.Tool, len(a.tools)+len(a.mcpTools))
+	for id, tool := range a.mcpTools {
+		out[id] = tool
+	}
+	// Built-in tools win, though MCP tool IDs have their own prefix anyway.
+	for id, tool := range a.tools {
+		out[id] = tool
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+
+	"cuttlefish/tools/plugin"
+)
+
+// loadPlugins adds the plugins in ~/.cuttlefish/plugins to the built-in tools.
+// They're only looked for at startup, so adding one requires restarting the app.
+func (a *App) loadPlugins() {
+	home, err := homedir.Dir()
+	if err != nil {
+		log.Printf("couldn't get user home directory to load plugins: %v", err)
+		return
+	}
+	plugins, errs := plugin.Load(a.ctx, filepath.Join(home, ".cuttlefish", "plugins"))
+	for _, err := range errs {
+		log.Println(err)
+	}
+	for id, tool := range plugins {
+		if _, ok := a.tools[id]; ok {
+			log.Printf("skipping plugin `%s`, as there's a built-in tool with the same name", id)
+			continue
+		}
+		a.tools[id] = tool
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cuttlefish/database"
+)
+
+// The headless server exposes the same operations as the desktop frontend over HTTP/JSON,
+// with events streamed over Server-Sent Events on /api/events.
+
+type event struct {
+	Name string        `json:"name"`
+	Data []interface{} `json:"data,omitempty"`
+}
+
+// EventBroker fans out events to all connected event streams.
+type EventBroker struct {
+	m           sync.Mutex
+	subscribers map[chan event]struct{}
+}
+
+func NewEventBroker() *EventBroker {
+	return &EventBroker{
+		subscribers: map[chan event]struct{}{},
+	}
+}
+
+func (b *EventBroker) Emit(eventName string, data ...interface{}) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	for subscriber := range b.subscribers {
+		select {
+		case subscriber <- event{Name: eventName, Data: data}:
+		default:
+			// The client isn't keeping up. Events only signal that something changed, so it'll catch up with the next one.
+		}
+	}
+}
+
+func (b *EventBroker) subscribe() chan event {
+	ch := make(chan event, 64)
+	b.m.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.m.Unlock()
+	return ch
+}
+
+func (b *EventBroker) unsubscribe(ch chan event) {
+	b.m.Lock()
+	delete(b.subscribers, ch)
+	b.m.Unlock()
+}
+
+type Server struct {
+	app    *App
+	events *EventBroker
+	// Requests have to include it as a bearer token.
+	token  string
+	routes []route
+}
+
+type route struct {
+	method string
+	// Path segments, with "{}" matching a numeric id, and "{*}" matching any single segment.
+	pattern []string
+	handler func(r *http.Request, params []string) (interface{}, error)
+}
+
+func NewServer(app *App, events *EventBroker, token string) *Server {
+	s := &Server{
+		app:    app,
+		events: events,
+		token:  token,
+	}
+	s.handle(http.MethodGet, "/api/conversations", func(r *http.Request, params []string) (interface{}, error) {
+		return app.Conversations()
+	})
+	// Starts a new conversation, optionally from a template.
+	s.handle(http.MethodPost, "/api/conversations", func(r *http.Request, params []string) (interface{}, error) {
+		var body struct {
+			Content    string `json:"content"`
+			TemplateID *int   `json:"templateID"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		if body.TemplateID != nil {
+			return app.SendMessageFromTemplate(*body.TemplateID, body.Content)
+		}
+		return app.SendMessage(-1, body.Content)
+	})
+	s.handle(http.MethodGet, "/api/conversations/{}", func(r *http.Request, params []string) (interface{}, error) {
+		return app.GetConversation(intParam(params[0]))
+	})
+	s.handle(http.MethodDelete, "/api/conversations/{}", func(r *http.Request, params []string) (interface{}, error) {
+		return nil, app.DeleteConversation(intParam(params[0]))
+	})
+	s.handle(http.MethodGet, "/api/conversations/{}/messages", func(r *http.Request, params []string) (interface{}, error) {
+		return app.Messages(intParam(params[0]))
+	})
+	s.handle(http.MethodPost, "/api/conversations/{}/messages", func(r *http.Request, params []string) (interface{}, error) {
+		var body struct {
+			Content string `json:"content"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return app.SendMessage(intParam(params[0]), body.Content)
+	})
+	s.handle(http.MethodPut, "/api/conversations/{}/messages/{}", func(r *http.Request, params []string) (interface{}, error) {
+		var body struct {
+			Content string `json:"content"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return app.EditMessage(intParam(params[0]), intParam(params[1]), body.Content)
+	})
+	s.handle(http.MethodPost, "/api/conversations/{}/messages/{}/rerun", func(r *http.Request, params []string) (interface{}, error) {
+		return nil, app.RerunFromMessage(intParam(params[0]), intParam(params[1]))
+	})
+	s.handle(http.MethodPost, "/api/conversations/{}/cancel", func(r *http.Request, params []string) (interface{}, error) {
+		app.CancelGeneration(intParam(params[0]))
+		return nil, nil
+	})
+	s.handle(http.MethodGet, "/api/conversations/{}/branches", func(r *http.Request, params []string) (interface{}, error) {
+		return app.ListBranches(intParam(params[0]))
+	})
+	s.handle(http.MethodPost, "/api/conversations/{}/branches/{}/switch", func(r *http.Request, params []string) (interface{}, error) {
+		return nil, app.SwitchBranch(intParam(params[0]), intParam(params[1]))
+	})
+	s.handle(http.MethodGet, "/api/conversations/{}/branches/{}/compare/{}", func(r *http.Request, params []string) (interface{}, error) {
+		return app.CompareBranches(intParam(params[0]), intParam(params[1]), intParam(params[2]))
+	})
+	s.handle(http.MethodGet, "/api/conversations/{}/sub-conversations", func(r *http.Request, params []string) (interface{}, error) {
+		return app.ListSubConversations(intParam(params[0]))
+	})
+	s.handle(http.MethodGet, "/api/conversations/{}/approvals", func(r *http.Request, params []string) (interface{}, error) {
+		return app.ListApprovalRequests(intParam(params[0]))
+	})
+	s.handle(http.MethodPost, "/api/conversations/{}/approvals/{*}/approve", func(r *http.Request, params []string) (interface{}, error) {
+		return nil, app.Approve(intParam(params[0]), params[1])
+	})
+	s.handle(http.MethodPost, "/api/conversations/{}/approvals/{*}/approve-always", func(r *http.Request, params []string) (interface{}, error) {
+		return nil, app.ApproveAlways(intParam(params[0]), params[1])
+	})
+	s.handle(http.MethodPost, "/api/conversations/{}/approvals/{*}/approve-with-edits", func(r *http.Request, params []string) (interface{}, error) {
+		var body struct {
+			Args map[string]interface{} `json:"args"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return nil, app.ApproveWithEdits(intParam(params[0]), params[1], body.Args)
+	})
+	s.handle(http.MethodPost, "/api/conversations/{}/approvals/{*}/reject", func(r *http.Request, params []string) (interface{}, error) {
+		var body struct {
+			Reason string `json:"reason"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return nil, app.Reject(intParam(params[0]), params[1], body.Reason)
+	})
+	s.handle(http.MethodGet, "/api/conversations/{}/tool-executions", func(r *http.Request, params []string) (interface{}, error) {
+		return app.ListConversationToolExecutions(intParam(params[0]))
+	})
+	s.handle(http.MethodGet, "/api/tool-executions", func(r *http.Request, params []string) (interface{}, error) {
+		limit := 100
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil {
+				return nil, &badRequestError{err: fmt.Errorf("invalid limit: %w", err)}
+			}
+		}
+		return app.ListToolExecutions(limit)
+	})
+	// Exports the tool executions of the conversation given as ?conversation=, or of all of them, as ?format=jsonl or csv.
+	s.handle(http.MethodGet, "/api/tool-executions/export", func(r *http.Request, params []string) (interface{}, error) {
+		conversationID := -1
+		if value := r.URL.Query().Get("conversation"); value != "" {
+			var err error
+			if conversationID, err = strconv.Atoi(value); err != nil {
+				return nil, &badRequestError{err: fmt.Errorf("invalid conversation: %w", err)}
+			}
+		}
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = "jsonl"
+		}
+		contentType, ok := map[string]string{"jsonl": "application/jsonl", "csv": "text/csv"}[format]
+		if !ok {
+			return nil, &badRequestError{err: fmt.Errorf("unknown format `%s`, it has to be jsonl or csv", format)}
+		}
+		content, err := app.ExportToolExecutions(conversationID, format)
+		if err != nil {
+			return nil, err
+		}
+		return &rawResponse{contentType: contentType, body: content}, nil
+	})
+	s.handle(http.MethodGet, "/api/conversation-settings/default", func(r *http.Request, params []string) (interface{}, error) {
+		return app.GetDefaultConversationSettings()
+	})
+	s.handle(http.MethodPut, "/api/conversation-settings/default", func(r *http.Request, params []string) (interface{}, error) {
+		var body database.CreateDefaultConversationSettingsParams
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return app.SetDefaultConversationSettings(body)
+	})
+	s.handle(http.MethodDelete, "/api/conversation-settings/default", func(r *http.Request, params []string) (interface{}, error) {
+		return app.ResetDefaultConversationSettings()
+	})
+	s.handle(http.MethodGet, "/api/conversation-settings/{}", func(r *http.Request, params []string) (interface{}, error) {
+		return app.GetConversationSettings(intParam(params[0]))
+	})
+	s.handle(http.MethodPut, "/api/conversation-settings/{}", func(r *http.Request, params []string) (interface{}, error) {
+		var body database.UpdateConversationSettingsParams
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		body.ID = intParam(params[0])
+		return app.UpdateConversationSettings(body)
+	})
+	s.handle(http.MethodGet, "/api/templates", func(r *http.Request, params []string) (interface{}, error) {
+		return app.ListConversationTemplates()
+	})
+	s.handle(http.MethodPost, "/api/templates", func(r *http.Request, params []string) (interface{}, error) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return app.CreateConversationTemplate(body.Name)
+	})
+	s.handle(http.MethodPut, "/api/templates/{}", func(r *http.Request, params []string) (interface{}, error) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		return app.UpdateConversationTemplate(intParam(params[0]), body.Name)
+	})
+	s.handle(http.MethodDelete, "/api/templates/{}", func(r *http.Request, params []string) (interface{}, error) {
+		return nil, app.DeleteConversationTemplate(intParam(params[0]))
+	})
+	s.handle(http.MethodGet, "/api/settings", func(r *http.Request, params []string) (interface{}, error) {
+		return app.GetSettings()
+	})
+	s.handle(http.MethodPut, "/api/settings", func(r *http.Request, params []string) (interface{}, error) {
+		var body database.Settings
+		if err := decodeBody(r, &body); err != nil {
+			return nil, err
+		}
+		if _, err := app.SaveSettings(body); err != nil {
+			return nil, err
+		}
+		// The saved settings include the API keys, which are masked when getting them.
+		return app.GetSettings()
+	})
+	s.handle(http.MethodGet, "/api/tools", func(r *http.Request, params []string) (interface{}, error) {
+		return app.GetAvailableTools(), nil
+	})
+	s.handle(http.MethodGet, "/api/providers", func(r *http.Request, params []string) (interface{}, error) {
+		return app.GetAvailableProviders(), nil
+	})
+	return s
+}
+
+func (s *Server) handle(method, path string, handler func(r *http.Request, params []string) (interface{}, error)) {
+	s.routes = append(s.routes, route{
+		method:  method,
+		pattern: strings.Split(strings.Trim(path, "/"), "/"),
+		handler: handler,
+	})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+		writeError(w, http.StatusUnauthorized, errors.New("invalid or missing bearer token"))
+		return
+	}
+	// Web pages the user visits can send requests to the server too, so requests from other origins,
+	// and ones which aren't JSON, i.e. plain text or form posts, which browsers send without asking, are refused.
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			writeError(w, http.StatusForbidden, fmt.Errorf("requests from origin `%s` aren't allowed", origin))
+			return
+		}
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		contentType := r.Header.Get("Content-Type")
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if (contentType != "" || r.ContentLength != 0) && mediaType != "application/json" {
+			writeError(w, http.StatusUnsupportedMediaType, errors.New("request bodies have to be application/json"))
+			return
+		}
+	}
+
+	if r.URL.Path == "/api/events" {
+		s.serveEvents(w, r)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	pathMatched := false
+	for _, route := range s.routes {
+		params, ok := matchRoute(route.pattern, segments)
+		if !ok {
+			continue
+		}
+		pathMatched = true
+		if route.method != r.Method {
+			continue
+		}
+		res, err := route.handler(r, params)
+		if err != nil {
+			status := http.StatusInternalServerError
+			var badRequest *badRequestError
+			if errors.As(err, &badRequest) {
+				status = http.StatusBadRequest
+			}
+			writeError(w, status, err)
+			return
+		}
+		if res == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if raw, ok := res.(*rawResponse); ok {
+			w.Header().Set("Content-Type", raw.contentType)
+			if _, err := io.WriteString(w, raw.body); err != nil {
+				log.Printf("couldn't write response: %v", err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(res); err != nil {
+			log.Printf("couldn't write response: %v", err)
+		}
+		return
+	}
+	if pathMatched {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint: %s", r.URL.Path))
+}
+
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming isn't supported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	events := s.events.subscribe()
+	defer s.events.unsubscribe(events)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-events:
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("couldn't encode event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Name, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func matchRoute(pattern, segments []string) ([]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	var params []string
+	for i := range pattern {
+		switch pattern[i] {
+		case "{}":
+			if _, err := strconv.Atoi(segments[i]); err != nil {
+				return nil, false
+			}
+			params = append(params, segments[i])
+		case "{*}":
+			params = append(params, segments[i])
+		default:
+			if pattern[i] != segments[i] {
+				return nil, false
+			}
+		}
+	}
+	return params, true
+}
+
+// intParam converts a "{}" path parameter, which has already been validated to be numeric.
+func intParam(param string) int {
+	out, _ := strconv.Atoi(param)
+	return out
+}
+
+type badRequestError struct {
+	err error
+}
+
+func (e *badRequestError) Error() string {
+	return e.err.Error()
+}
+
+// rawResponse is written as it is, instead of being encoded as JSON, i.e. for exports.
+type rawResponse struct {
+	contentType string
+	body        string
+}
+
+func decodeBody(r *http.Request, out interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(out); err != nil {
+		return &badRequestError{err: fmt.Errorf("couldn't decode request body: %w", err)}
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// Serve runs the HTTP server until the context is cancelled.
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	if s.token == "" {
+		return errors.New("the API server needs a token")
+	}
+	httpServer := &http.Server{
+		Addr:    addr,
+		Handler: s,
+	}
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+	log.Printf("Serving the API on http://%s", addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+// Sub-conversations are started by the assistant tool, to let the Assistant delegate a task.
+// They're regular conversations linked to the message with the tool call, so they can be inspected,
+// but they're not listed in the sidebar, their approval requests show up in the root conversation,
+// and they work in the root conversation's workspace.
+
+const (
+	defaultSubConversationMaxDepth = 2
+	defaultSubConversationMaxSteps = 10
+)
+
+func (a *App) ListSubConversations(conversationID int) ([]database.Conversation, error) {
+	return a.queries.ListSubConversations(a.ctx, conversationID)
+}
+
+func (a *App) runSubConversation(ctx context.Context, parentConversationID int, subConversation tools.SubConversation) (string, error) {
+	parentMessageID, ok := ctx.Value(toolCallMessageKey{}).(int)
+	if !ok {
+		return "", fmt.Errorf("sub-conversations can only be started by tool calls")
+	}
+	settings, err := a.getSettingsRaw()
+	if err != nil {
+		return "", fmt.Errorf("couldn't get settings: %w", err)
+	}
+	depth, err := a.conversationDepth(ctx, parentConversationID)
+	if err != nil {
+		return "", err
+	}
+	maxDepth := settings.Assistant.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultSubConversationMaxDepth
+	}
+	maxSteps := settings.Assistant.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultSubConversationMaxSteps
+	}
+	if depth+1 > maxDepth {
+		return "", fmt.Errorf("sub-conversations can only be nested %d levels deep, please work on the task yourself", maxDepth)
+	}
+
+	parentConversation, err := a.queries.GetConversation(ctx, parentConversationID)
+	if err != nil {
+		return "", fmt.Errorf("couldn't get conversation: %w", err)
+	}
+	conversationSettings, err := a.queries.GetConversationSettings(ctx, parentConversation.ConversationSettingsID)
+	if err != nil {
+		return "", fmt.Errorf("couldn't get conversation settings: %w", err)
+	}
+	// Only tools which are enabled in the parent conversation and still exist, i.e. of an MCP server that's gone, can be passed on.
+	availableTools := a.availableTools()
+	var available, unknown []string
+	for _, tool := range conversationSettings.ToolsEnabled {
+		if _, ok := availableTools[tool]; ok {
+			available = append(available, tool)
+		}
+	}
+	for _, tool := range subConversation.Tools {
+		if !slices.Contains(available, tool) {
+			unknown = append(unknown, "`"+tool+"`")
+		}
+	}
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown tools %s, available ones are: %s", strings.Join(unknown, ", "), strings.Join(available, ", "))
+	}
+	conversationSettings.ToolsEnabled = subConversation.Tools
+	if instructions := strings.TrimSpace(subConversation.Instructions); instructions != "" {
+		// Quoted as a template string, so that the instructions are used as they are.
+		conversationSettings.SystemPromptTemplate += "\n\n{{" + strconv.Quote(instructions) + "}}"
+	}
+	conversationSettings, err = a.copyConversationSettings(ctx, conversationSettings)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create conversation settings: %w", err)
+	}
+
+	conversation, err := a.queries.CreateConversation(ctx, database.CreateConversationParams{
+		ConversationSettingsID: conversationSettings.ID,
+		Title:                  conversationTitle(subConversation.Task),
+		LastMessageTime:        time.Now(),
+		ParentMessageID:        sql.NullInt64{Int64: int64(parentMessageID), Valid: true},
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't create sub-conversation: %w", err)
+	}
+	a.events.Emit(fmt.Sprintf("conversation-%d-updated", parentConversationID))
+	if _, err := a.addMessage(ctx, database.CreateMessageParams{
+		ConversationID: conversation.ID,
+		Content:        subConversation.Task,
+		Author:         "user",
+	}); err != nil {
+		return "", fmt.Errorf("couldn't create task message: %w", err)
+	}
+
+	a.m.Lock()
+	a.subConversationParents[conversation.ID] = parentConversationID
+	a.m.Unlock()
+	defer func() {
+		a.m.Lock()
+		delete(a.subConversationParents, conversation.ID)
+		a.m.Unlock()
+		// The sub-conversation is done, so whatever its tools started isn't needed anymore.
+		a.shutdownToolInstances(conversation.ID)
+	}()
+
+	if err := a.runChainOfMessagesWithLimit(ctx, conversation.ID, maxSteps); err != nil {
+		return "", fmt.Errorf("sub-conversation %d failed: %w", conversation.ID, err)
+	}
+
+	messages, err := a.activeBranchMessages(ctx, conversation.ID)
+	if err != nil {
+		return "", err
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Author != "assistant" {
+		return "", fmt.Errorf("sub-conversation %d ended without a final answer", conversation.ID)
+	}
+	return messages[len(messages)-1].Content, nil
+}
+
+// conversationDepth returns how deeply the conversation is nested, with top-level conversations being at depth 0.
+func (a *App) conversationDepth(ctx context.Context, conversationID int) (int, error) {
+	depth := 0
+	for {
+		conversation, err := a.queries.GetConversation(ctx, conversationID)
+		if err != nil {
+			return 0, fmt.Errorf("couldn't get conversation: %w", err)
+		}
+		if !conversation.ParentMessageID.Valid {
+			return depth, nil
+		}
+		parentMessage, err := a.queries.GetMessage(ctx, int(conversation.ParentMessageID.Int64))
+		if err != nil {
+			return 0, fmt.Errorf("couldn't get parent message: %w", err)
+		}
+		conversationID = parentMessage.ConversationID
+		depth++
+	}
+}
+
+// rootConversationID returns the top-level conversation of a running sub-conversation, which is where the user will be looking,
+// and whose workspace the sub-conversation shares.
+func (a *App) rootConversationID(conversationID int) int {
+	a.m.Lock()
+	defer a.m.Unlock()
+	for {
+		parentID, ok := a.subConversationParents[conversationID]
+		if !ok {
+			return conversationID
+		}
+		conversationID = parentID
+	}
+}
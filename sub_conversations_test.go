@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cuttlefish/tools"
+)
+
+func TestSubConversationUnknownTools(t *testing.T) {
+	a := newTestApp(t, &fakeProvider{})
+	conversationID := newTestConversation(t, a, "hello")
+	messages, err := a.activeBranchMessages(a.ctx, conversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), toolCallMessageKey{}, messages[0].ID)
+
+	_, err = a.runSubConversation(ctx, conversationID, tools.SubConversation{
+		Task:  "do something",
+		Tools: []string{"terminal", "nope", "files"},
+	})
+	if err == nil {
+		t.Fatal("expected an error for unknown tools")
+	}
+	// files exists, but isn't enabled by default.
+	for _, name := range []string{"`nope`", "`files`"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error %q doesn't report %s", err, name)
+		}
+	}
+	if strings.Contains(err.Error(), "`terminal`") {
+		t.Errorf("error %q reports terminal, which is available", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"cuttlefish/database"
+)
+
+// Conversation templates are named sets of conversation settings, i.e. personalities, to start new conversations with.
+// Conversations get a copy of the template's settings, so that editing one doesn't affect the other.
+
+func (a *App) ListConversationTemplates() ([]database.ConversationTemplate, error) {
+	return a.queries.ListConversationTemplates(a.ctx)
+}
+
+// CreateConversationTemplate creates a template starting out with the default conversation settings.
+func (a *App) CreateConversationTemplate(name string) (database.ConversationTemplate, error) {
+	defaultConversationSettings, err := a.GetDefaultConversationSettings()
+	if err != nil {
+		return database.ConversationTemplate{}, fmt.Errorf("couldn't get default conversation settings: %w", err)
+	}
+	settings, err := a.copyConversationSettings(a.ctx, defaultConversationSettings)
+	if err != nil {
+		return database.ConversationTemplate{}, fmt.Errorf("couldn't create conversation settings: %w", err)
+	}
+	template, err := a.queries.CreateConversationTemplate(a.ctx, database.CreateConversationTemplateParams{
+		Name:                   name,
+		ConversationSettingsID: settings.ID,
+	})
+	if err != nil {
+		return database.ConversationTemplate{}, fmt.Errorf("couldn't create conversation template: %w", err)
+	}
+	a.events.Emit("conversation-templates-updated")
+	return template, nil
+}
+
+// UpdateConversationTemplate renames the template. Its settings are updated using UpdateConversationSettings.
+func (a *App) UpdateConversationTemplate(templateID int, name string) (database.ConversationTemplate, error) {
+	template, err := a.queries.UpdateConversationTemplate(a.ctx, database.UpdateConversationTemplateParams{
+		Name: name,
+		ID:   templateID,
+	})
+	if err != nil {
+		return database.ConversationTemplate{}, fmt.Errorf("couldn't update conversation template: %w", err)
+	}
+	a.events.Emit("conversation-templates-updated")
+	return template, nil
+}
+
+func (a *App) DeleteConversationTemplate(templateID int) error {
+	template, err := a.queries.GetConversationTemplate(a.ctx, templateID)
+	if err != nil {
+		return fmt.Errorf("couldn't get conversation template: %w", err)
+	}
+	// The template gets deleted along with its settings. Conversations started from it have their own copy.
+	if err := a.queries.DeleteConversationSettings(a.ctx, template.ConversationSettingsID); err != nil {
+		return fmt.Errorf("couldn't delete conversation template: %w", err)
+	}
+	a.events.Emit("conversation-templates-updated")
+	return nil
+}
+
+// SendMessageFromTemplate starts a new conversation with a copy of the template's settings.
+func (a *App) SendMessageFromTemplate(templateID int, content string) (database.Message, error) {
+	template, err := a.queries.GetConversationTemplate(a.ctx, templateID)
+	if err != nil {
+		return database.Message{}, fmt.Errorf("couldn't get conversation template: %w", err)
+	}
+	settings, err := a.queries.CloneConversationSettings(a.ctx, template.ConversationSettingsID)
+	if err != nil {
+		return database.Message{}, fmt.Errorf("couldn't clone conversation settings: %w", err)
+	}
+	conversationID, err := a.createConversation(content, settings.ID)
+	if err != nil {
+		return database.Message{}, err
+	}
+
+	return a.sendMessage(conversationID, content)
+}
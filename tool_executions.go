@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+// Every tool call with valid arguments is logged as a tool execution, along with how it got approved and how it went,
+// so the user can always check what the Assistant ran, also once the conversation or the observation message is gone.
+
+const (
+	executionApprovalNotRequired       = "not_required"
+	executionApprovalPending           = "pending" // Asked for, but not answered, i.e. because the generation got cancelled.
+	executionApprovalAllowedByRule     = "allowed_by_rule"
+	executionApprovalDeniedByRule      = "denied_by_rule"
+	executionApprovalApproved          = "approved"
+	executionApprovalApprovedWithEdits = "approved_with_edits"
+	executionApprovalRejected          = "rejected"
+)
+
+const (
+	executionStatusSucceeded = "succeeded"
+	executionStatusFailed    = "failed"
+	executionStatusDenied    = "denied"
+	executionStatusRejected  = "rejected"
+	executionStatusCancelled = "cancelled"
+)
+
+// The log is about what ran, the full output is in the conversation.
+const maxLoggedToolOutput = 16 * 1024
+
+func (a *App) startToolExecution(ctx context.Context, conversationID int, messageID int, toolCall database.ToolCall) (int, error) {
+	execution, err := a.queries.CreateToolExecution(ctx, database.CreateToolExecutionParams{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		ToolCallID:     toolCall.ID,
+		Tool:           toolCall.Tool,
+		Args:           toolCall.Args,
+		StartedAt:      time.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("couldn't log tool execution: %w", err)
+	}
+	return execution.ID, nil
+}
+
+// finishToolExecution records how the tool call went. The tool has already run by then, so failing to log it only gets reported.
+func (a *App) finishToolExecution(ctx context.Context, executionID int, state *toolCallState, result *tools.RunResult, runErr error) {
+	params := database.FinishToolExecutionParams{
+		ID:         executionID,
+		Args:       state.toolCall.Args,
+		Approval:   state.approval,
+		ApprovedBy: state.approvedBy,
+		FinishedAt: sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	var rejected *tools.RejectedError
+	switch {
+	case ctx.Err() != nil:
+		params.Status = executionStatusCancelled
+	case state.approval == executionApprovalDeniedByRule:
+		params.Status = executionStatusDenied
+	case errors.As(runErr, &rejected):
+		params.Status = executionStatusRejected
+	case runErr != nil:
+		params.Status = executionStatusFailed
+	default:
+		params.Status = executionStatusSucceeded
+	}
+	if runErr != nil {
+		params.Error = runErr.Error()
+	}
+	if result != nil {
+		params.Result = result.Result
+		params.Output = result.Output
+		if len(params.Output) > maxLoggedToolOutput {
+			params.Output = tools.Truncate(params.Output, maxLoggedToolOutput) + "\n... (truncated)"
+		}
+		if result.ExitCode != nil {
+			params.ExitCode = sql.NullInt64{Int64: int64(*result.ExitCode), Valid: true}
+		}
+	}
+	// The generation's context may be done already, i.e. if the tool got cancelled.
+	if err := a.queries.FinishToolExecution(context.Background(), params); err != nil {
+		log.Printf("couldn't log tool execution %d: %s", executionID, err)
+		a.events.Emit("async-error", fmt.Errorf("couldn't log tool execution: %w", err).Error())
+	}
+}
+
+// approver is who approved a tool call through the app, which is whoever the app runs as, also if it's approved over the API.
+func approver() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+// ListToolExecutions lists the latest tool executions of all conversations, newest first, or all of them if limit is negative.
+func (a *App) ListToolExecutions(limit int) ([]database.ToolExecution, error) {
+	executions, err := a.queries.ListToolExecutions(a.ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list tool executions: %w", err)
+	}
+	return executions, nil
+}
+
+// ListConversationToolExecutions lists the tool executions of the conversation, oldest first.
+// Those of its sub-conversations are listed with the sub-conversations.
+func (a *App) ListConversationToolExecutions(conversationID int) ([]database.ToolExecution, error) {
+	executions, err := a.queries.ListConversationToolExecutions(a.ctx, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list tool executions: %w", err)
+	}
+	return executions, nil
+}
+
+// exportedToolExecution is a tool execution with plain values instead of sql.Null types, which reads better in exports.
+type exportedToolExecution struct {
+	ID             int                    `json:"id"`
+	ConversationID int                    `json:"conversationID"`
+	MessageID      int                    `json:"messageID"`
+	ToolCallID     string                 `json:"toolCallID"`
+	Tool           string                 `json:"tool"`
+	Args           map[string]interface{} `json:"args"`
+	Approval       string                 `json:"approval"`
+	ApprovedBy     string                 `json:"approvedBy"`
+	Status         string                 `json:"status"`
+	StartedAt      time.Time              `json:"startedAt"`
+	FinishedAt     *time.Time             `json:"finishedAt"`
+	ExitCode       *int64                 `json:"exitCode"`
+	Result         string                 `json:"result"`
+	Output         string                 `json:"output"`
+	Error          string                 `json:"error"`
+}
+
+func exportToolExecution(execution database.ToolExecution) exportedToolExecution {
+	out := exportedToolExecution{
+		ID:             execution.ID,
+		ConversationID: execution.ConversationID,
+		MessageID:      execution.MessageID,
+		ToolCallID:     execution.ToolCallID,
+		Tool:           execution.Tool,
+		Args:           execution.Args,
+		Approval:       execution.Approval,
+		ApprovedBy:     execution.ApprovedBy,
+		Status:         execution.Status,
+		StartedAt:      execution.StartedAt,
+		Result:         execution.Result,
+		Output:         execution.Output,
+		Error:          execution.Error,
+	}
+	if execution.FinishedAt.Valid {
+		out.FinishedAt = &execution.FinishedAt.Time
+	}
+	if execution.ExitCode.Valid {
+		out.ExitCode = &execution.ExitCode.Int64
+	}
+	return out
+}
+
+// ExportToolExecutions returns the tool executions of the conversation, or of all conversations if it's -1, oldest first,
+// as either JSON Lines (jsonl) or CSV (csv).
+func (a *App) ExportToolExecutions(conversationID int, format string) (string, error) {
+	var executions []database.ToolExecution
+	var err error
+	if conversationID == -1 {
+		executions, err = a.ListToolExecutions(-1)
+		for i, j := 0, len(executions)-1; i < j; i, j = i+1, j-1 {
+			executions[i], executions[j] = executions[j], executions[i]
+		}
+	} else {
+		executions, err = a.ListConversationToolExecutions(conversationID)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case "jsonl":
+		encoder := json.NewEncoder(&buf)
+		for _, execution := range executions {
+			if err := encoder.Encode(exportToolExecution(execution)); err != nil {
+				return "", fmt.Errorf("couldn't encode tool execution %d: %w", execution.ID, err)
+			}
+		}
+	case "csv":
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"id", "conversation_id", "message_id", "tool_call_id", "tool", "args", "approval", "approved_by", "status", "started_at", "finished_at", "exit_code", "result", "output", "error"})
+		for _, execution := range executions {
+			exported := exportToolExecution(execution)
+			args, err := json.Marshal(exported.Args)
+			if err != nil {
+				return "", fmt.Errorf("couldn't encode arguments of tool execution %d: %w", execution.ID, err)
+			}
+			finishedAt, exitCode := "", ""
+			if exported.FinishedAt != nil {
+				finishedAt = exported.FinishedAt.Format(time.RFC3339)
+			}
+			if exported.ExitCode != nil {
+				exitCode = strconv.FormatInt(*exported.ExitCode, 10)
+			}
+			w.Write([]string{
+				strconv.Itoa(exported.ID),
+				strconv.Itoa(exported.ConversationID),
+				strconv.Itoa(exported.MessageID),
+				exported.ToolCallID,
+				exported.Tool,
+				string(args),
+				exported.Approval,
+				exported.ApprovedBy,
+				exported.Status,
+				exported.StartedAt.Format(time.RFC3339),
+				finishedAt,
+				exitCode,
+				exported.Result,
+				exported.Output,
+				exported.Error,
+			})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return "", fmt.Errorf("couldn't write csv: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("unknown export format `%s`, it has to be jsonl or csv", format)
+	}
+	return buf.String(), nil
+}
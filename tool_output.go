@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+// Output is written to the database and sent to the frontend at most this often, however quickly the tool prints.
+const toolOutputFlushInterval = 250 * time.Millisecond
+
+// Only so much output is shown while the tool runs, the tool's result decides what the model gets to see anyway.
+const maxStreamedToolOutput = 64 * 1024
+
+type toolOutputKey struct{}
+
+// toolOutput streams the output of a running tool into its observation message.
+// The message is only created once there's output, so tools which don't write any keep working as before.
+type toolOutput struct {
+	app            *App
+	ctx            context.Context
+	conversationID int
+	author         string
+	toolCallID     string
+
+	m         sync.Mutex
+	messageID int
+	// Written, but not flushed yet.
+	pending strings.Builder
+	// Flushed so far.
+	output    strings.Builder
+	truncated bool
+	timer     *time.Timer
+	closed    bool
+}
+
+func (a *App) newToolOutput(ctx context.Context, conversationID int, author string, toolCallID string) *toolOutput {
+	return &toolOutput{
+		app:            a,
+		ctx:            ctx,
+		conversationID: conversationID,
+		author:         author,
+		toolCallID:     toolCallID,
+	}
+}
+
+// Write never fails, so a tool doesn't fail just because its output couldn't be shown.
+func (o *toolOutput) Write(p []byte) (int, error) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	if o.closed || o.truncated {
+		return len(p), nil
+	}
+	o.pending.Write(p)
+	if o.timer == nil {
+		o.timer = time.AfterFunc(toolOutputFlushInterval, func() {
+			o.m.Lock()
+			defer o.m.Unlock()
+			o.timer = nil
+			if !o.closed {
+				o.flush()
+			}
+		})
+	}
+	return len(p), nil
+}
+
+// flush writes the pending output to the observation message, creating it first if there's none yet.
+// It has to be called with the lock held.
+func (o *toolOutput) flush() {
+	chunk := o.pending.String()
+	o.pending.Reset()
+	if chunk == "" {
+		return
+	}
+	if remaining := maxStreamedToolOutput - o.output.Len(); len(chunk) > remaining {
+		chunk = tools.Truncate(chunk, remaining) + "\n... (more output will be shown once the tool finishes)"
+		o.truncated = true
+	}
+	o.output.WriteString(chunk)
+	if o.ctx.Err() != nil {
+		return
+	}
+
+	if o.messageID == 0 {
+		msg, err := o.app.addMessage(o.ctx, database.CreateMessageParams{
+			ConversationID: o.conversationID,
+			Content:        "Observation: running\n```\n" + chunk,
+			Author:         o.author,
+			ToolCallID:     o.toolCallID,
+		})
+		if err != nil {
+			log.Printf("couldn't create observation message for streamed tool output: %s", err)
+			return
+		}
+		o.messageID = msg.ID
+	} else if _, err := o.app.queries.AppendMessage(o.ctx, database.AppendMessageParams{
+		ID:      o.messageID,
+		Content: chunk,
+	}); err != nil {
+		log.Printf("couldn't append streamed tool output: %s", err)
+		return
+	}
+	o.app.events.Emit(fmt.Sprintf("conversation-%d-updated", o.conversationID))
+}
+
+// close flushes whatever's left, after which anything written, i.e. by a process left running in the background, is dropped.
+// It returns the ID of the observation message, if any output was streamed into one.
+// Tools which finish before their first output gets flushed don't get one, there's no point in showing output that's replaced right away.
+func (o *toolOutput) close() (messageID int, ok bool) {
+	o.m.Lock()
+	defer o.m.Unlock()
+	if o.timer != nil {
+		o.timer.Stop()
+		o.timer = nil
+	}
+	if !o.closed && o.messageID != 0 {
+		o.flush()
+	}
+	o.closed = true
+	return o.messageID, o.messageID != 0
+}
+
+// finish replaces the streamed output with the final observation, or adds the observation as a new message if nothing was streamed.
+func (o *toolOutput) finish(ctx context.Context, observation string) error {
+	if messageID, ok := o.close(); ok {
+		return o.app.queries.SetMessageContent(ctx, database.SetMessageContentParams{
+			ID:      messageID,
+			Content: observation,
+		})
+	}
+	_, err := o.app.addMessage(ctx, database.CreateMessageParams{
+		ConversationID: o.conversationID,
+		Content:        observation,
+		Author:         o.author,
+		ToolCallID:     o.toolCallID,
+	})
+	return err
+}
+
+// cancel closes the observation message of a tool which got cancelled, so that it doesn't look like it's still running.
+func (o *toolOutput) cancel() {
+	messageID, ok := o.close()
+	if !ok {
+		return
+	}
+	o.m.Lock()
+	output := o.output.String()
+	o.m.Unlock()
+	// The generation's context is done already.
+	if err := o.app.queries.SetMessageContent(context.Background(), database.SetMessageContentParams{
+		ID: messageID,
+		Content: formatObservation(&tools.RunResult{
+			Result: "cancelled",
+			Output: output,
+		}),
+	}); err != nil {
+		log.Printf("couldn't close observation message of cancelled tool: %s", err)
+	}
+}
@@ -0,0 +1,80 @@
+package assistant
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+type Tool struct {
+}
+
+func (t *Tool) Name() string {
+	return "Assistant"
+}
+
+func (t *Tool) Description() string {
+	return "delegate a self-contained task to another assistant, which works on it in its own conversation and returns its final answer; useful for splitting up big tasks"
+}
+
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"task":         {Type: "string", Description: "the task, including all the context the other assistant needs, as it doesn't see this conversation"},
+		"instructions": {Type: "string", Description: "additional instructions for the other assistant's system prompt, i.e. a role to take on", Default: ""},
+		"tools":        {Type: "array", Items: &tools.Schema{Type: "string"}, Description: "names of the tools the other assistant may use, out of the ones available to you", Default: []interface{}{}},
+	}, "task")
+}
+
+func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
+	return &ToolInstance{
+		runtime: runtime,
+	}, nil
+}
+
+type ToolInstance struct {
+	runtime tools.AppRuntime
+}
+
+func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
+	task, ok := args["task"].(string)
+	if !ok {
+		return nil, fmt.Errorf("task is not a string")
+	}
+	instructions, ok := args["instructions"].(string)
+	if !ok {
+		return nil, fmt.Errorf("instructions is not a string")
+	}
+	toolNames, ok := args["tools"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("tools is not an array")
+	}
+	if strings.TrimSpace(task) == "" {
+		return nil, fmt.Errorf("task can't be empty")
+	}
+
+	enabledTools := make([]string, len(toolNames))
+	for i, name := range toolNames {
+		if enabledTools[i], ok = name.(string); !ok {
+			return nil, fmt.Errorf("tools[%d] is not a string", i)
+		}
+	}
+	answer, err := t.runtime.RunSubConversation(ctx, tools.SubConversation{
+		Task:         task,
+		Instructions: instructions,
+		Tools:        enabledTools,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tools.RunResult{
+		Result: "the other assistant's final answer",
+		Output: answer + "\n",
+	}, nil
+}
+
+func (t *ToolInstance) Shutdown() error {
+	return nil
+}
@@ -20,10 +20,10 @@ func (t *Tool) Description() string {
 	return "plot data on charts using Apache ECharts"
 }
 
-func (t *Tool) ArgumentDescriptions() map[string]string {
-	return map[string]string{
-		"options": "Options to pass to the Apache ECharts chart.",
-	}
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"options": {Type: "object", Description: "Options to pass to the Apache ECharts chart."},
+	}, "options")
 }
 
 func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
@@ -22,10 +22,10 @@ func (t *Tool) Description() string {
 	return "generate images using dalle2"
 }
 
-func (t *Tool) ArgumentDescriptions() map[string]string {
-	return map[string]string{
-		"prompt": "prompt to use to generate the image; the prompt should be detailed, and include keywords regarding styling; it shouldn't be a proper sentence, rather, a bag of keywords",
-	}
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"prompt": {Type: "string", Description: "prompt to use to generate the image; the prompt should be detailed, and include keywords regarding styling; it shouldn't be a proper sentence, rather, a bag of keywords"},
+	}, "prompt")
 }
 
 func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
@@ -43,15 +43,22 @@ type ToolInstance struct {
 }
 
 func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
+	prompt, ok := args["prompt"].(string)
+	if !ok {
+		return nil, fmt.Errorf("prompt is not a string")
+	}
 	res, err := t.dalleCli.Create(
 		ctx,
-		args["prompt"].(string),
+		prompt,
 		dalle2.WithFormat(dalle2.URL),
 		dalle2.WithSize(dalle2.LARGE),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("error creating image: %s", err)
 	}
+	if len(res.Data) == 0 {
+		return nil, fmt.Errorf("no image was generated")
+	}
 
 	data, err := json.MarshalIndent(map[string]interface{}{
 		"image_url": res.Data[0].Url,
@@ -0,0 +1,337 @@
+package duckdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+const (
+	defaultExecutablePath = "duckdb"
+	defaultMaxRows        = 200
+	// Results are cut down to this size, so a wide table can't blow up the context window.
+	maxOutputBytes = 32 * 1024
+	maxListedFiles = 200
+)
+
+var dataFileExtensions = []string{".csv", ".tsv", ".parquet", ".json", ".jsonl", ".ndjson"}
+
+type Tool struct {
+}
+
+func (t *Tool) Name() string {
+	return "DuckDB"
+}
+
+func (t *Tool) Description() string {
+	return "query local csv, parquet and json files with DuckDB SQL, i.e. SELECT region, sum(amount) FROM '/data/sales.csv' GROUP BY region; " +
+		"results are json rows, which can be used as the chart tool's dataset.source as they are"
+}
+
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"action": {Type: "string", Enum: []interface{}{"list_files", "describe", "query"}},
+		"input":  {Type: "string", Description: "the file path for describe, and the sql query for query", Default: ""},
+	}, "action")
+}
+
+func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
+	var directories []string
+	for _, dir := range settings.DuckDB.Directories {
+		dir, err := resolvePath(dir)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't resolve data directory: %w", err)
+		}
+		directories = append(directories, dir)
+	}
+	executablePath := settings.DuckDB.ExecutablePath
+	if executablePath == "" {
+		executablePath = defaultExecutablePath
+	}
+	maxRows := settings.DuckDB.MaxRows
+	if maxRows <= 0 {
+		maxRows = defaultMaxRows
+	}
+	return &ToolInstance{
+		executablePath: executablePath,
+		directories:    directories,
+		maxRows:        maxRows,
+	}, nil
+}
+
+type ToolInstance struct {
+	executablePath string
+	// The data files have to be in one of these, both for this tool and for DuckDB itself.
+	directories []string
+	maxRows     int
+}
+
+func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
+	action, ok := args["action"].(string)
+	if !ok {
+		return nil, fmt.Errorf("action is not a string")
+	}
+	input, ok := args["input"].(string)
+	if !ok {
+		return nil, fmt.Errorf("input is not a string")
+	}
+	if len(t.directories) == 0 {
+		return nil, fmt.Errorf("there are no data directories configured, the user can add them in the settings")
+	}
+
+	switch action {
+	case "list_files":
+		return t.listFiles()
+	case "describe":
+		path, err := t.checkPath(input)
+		if err != nil {
+			return nil, err
+		}
+		source := quoteString(path)
+		results, err := t.run(ctx, fmt.Sprintf("DESCRIBE SELECT * FROM %s;\nSELECT count(*) AS row_count FROM %s;\nSELECT * FROM %s LIMIT 5;", source, source, source))
+		if err != nil {
+			return nil, err
+		}
+		var output strings.Builder
+		for _, rows := range results {
+			output.WriteString(formatRows(rows) + "\n")
+		}
+		return &tools.RunResult{
+			Result: "the columns, row count and first rows of `" + path + "`",
+			Output: output.String(),
+		}, nil
+	case "query":
+		query, err := singleStatement(input)
+		if err != nil {
+			return nil, err
+		}
+		query = strings.TrimSpace(query)
+		if query == "" {
+			return nil, fmt.Errorf("input has to be the sql query to run")
+		}
+		if isSelect(query) {
+			// One more than the limit, to know whether anything got cut off.
+			query = fmt.Sprintf("SELECT * FROM (%s\n) LIMIT %d", query, t.maxRows+1)
+		}
+		results, err := t.run(ctx, query+";")
+		if err != nil {
+			return nil, err
+		}
+		if len(results) == 0 {
+			return &tools.RunResult{
+				Result: "successfully ran `" + input + "`",
+			}, nil
+		}
+		rows := results[len(results)-1]
+		return t.rowsResult(input, rows), nil
+	default:
+		return nil, fmt.Errorf("unknown action `%s`, it has to be one of list_files, describe or query", action)
+	}
+}
+
+func (t *ToolInstance) rowsResult(query string, rows []json.RawMessage) *tools.RunResult {
+	result := fmt.Sprintf("`%s` returned %d rows", query, len(rows))
+	if len(rows) > t.maxRows {
+		rows = rows[:t.maxRows]
+		result = fmt.Sprintf("`%s` returned more than %d rows, only the first %d are shown; use aggregations, filters or LIMIT to narrow it down", query, t.maxRows, t.maxRows)
+	}
+	output := formatRows(rows)
+	if len(output) > maxOutputBytes {
+		for len(rows) > 1 && len(output) > maxOutputBytes {
+			rows = rows[:len(rows)/2]
+			output = formatRows(rows)
+		}
+		result = fmt.Sprintf("`%s` returned too much data, only the first %d rows are shown; select fewer columns or use aggregations to narrow it down", query, len(rows))
+	}
+	return &tools.RunResult{
+		Result: result,
+		Output: output + "\n",
+	}
+}
+
+// formatRows formats the rows as a json array with a row per line, which is still easy to read, but doesn't waste space.
+func formatRows(rows []json.RawMessage) string {
+	if len(rows) == 0 {
+		return "[]"
+	}
+	var sb strings.Builder
+	sb.WriteString("[\n")
+	for i, row := range rows {
+		sb.Write(row)
+		if i < len(rows)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// run runs the statements in an in-memory DuckDB, which can only read files in the configured directories, and returns the rows of each result.
+func (t *ToolInstance) run(ctx context.Context, statements string) ([][]json.RawMessage, error) {
+	var quotedDirectories []string
+	for _, dir := range t.directories {
+		quotedDirectories = append(quotedDirectories, quoteString(dir))
+	}
+	// Locking the configuration keeps the query from lifting the restrictions again.
+	script := fmt.Sprintf("SET allowed_directories = [%s];\nSET enable_external_access = false;\nSET lock_configuration = true;\n%s\n", strings.Join(quotedDirectories, ", "), statements)
+
+	cmd := t.command(ctx, script)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, errors.New(message)
+		}
+		return nil, fmt.Errorf("couldn't run duckdb: %w", err)
+	}
+
+	// Each result is printed as a separate json array.
+	var results [][]json.RawMessage
+	decoder := json.NewDecoder(&stdout)
+	for {
+		var rows []json.RawMessage
+		if err := decoder.Decode(&rows); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("couldn't decode duckdb output: %w", err)
+		}
+		results = append(results, rows)
+	}
+	return results, nil
+}
+
+// command runs the script with the DuckDB CLI in safe mode, which disables the dot commands reaching the host, like `.shell`.
+func (t *ToolInstance) command(ctx context.Context, script string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, t.executablePath, "-safe", "-json", "-bail", ":memory:")
+	// So that relative paths in queries are relative to the first data directory.
+	cmd.Dir = t.directories[0]
+	cmd.Stdin = strings.NewReader(script)
+	return cmd
+}
+
+var errTooManyFiles = errors.New("too many files")
+
+func (t *ToolInstance) listFiles() (*tools.RunResult, error) {
+	var files []string
+	truncated := false
+	for _, dir := range t.directories {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// Unreadable subdirectories are skipped.
+				return nil
+			}
+			if entry.IsDir() && path != dir && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if entry.IsDir() || !isDataFile(path) {
+				return nil
+			}
+			if _, err := t.checkPath(path); err != nil {
+				// I.e. a symlink pointing outside the data directories.
+				return nil
+			}
+			if len(files) == maxListedFiles {
+				return errTooManyFiles
+			}
+			files = append(files, path)
+			return nil
+		})
+		if errors.Is(err, errTooManyFiles) {
+			truncated = true
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("couldn't list files in %s: %w", dir, err)
+		}
+	}
+
+	result := fmt.Sprintf("found %d data files", len(files))
+	if truncated {
+		result = fmt.Sprintf("found more than %d data files, only the first %d are shown", maxListedFiles, maxListedFiles)
+	}
+	return &tools.RunResult{
+		Result: result,
+		Output: strings.Join(files, "\n") + "\n",
+	}, nil
+}
+
+// checkPath makes sure the file is in one of the data directories, resolving relative paths against the first one.
+func (t *ToolInstance) checkPath(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(t.directories[0], path)
+	}
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't resolve path: %w", err)
+	}
+	for _, dir := range t.directories {
+		if rel, err := filepath.Rel(dir, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("`%s` isn't in any of the data directories: %s", path, strings.Join(t.directories, ", "))
+}
+
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(path)
+}
+
+func isDataFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(strings.TrimSuffix(path, ".gz")))
+	for _, dataExt := range dataFileExtensions {
+		if ext == dataExt {
+			return true
+		}
+	}
+	return false
+}
+
+func isSelect(query string) bool {
+	fields := strings.Fields(strings.TrimLeft(query, "("))
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(fields[0]) {
+	case "select", "with", "from", "values":
+		return true
+	}
+	return false
+}
+
+// singleStatement returns the query without its trailing semicolons,
+// as it's wrapped in a limit, which only works for a single statement.
+// Lines starting with a dot are rejected, as the CLI would run them as dot commands, i.e. `.shell`, instead of sql.
+func singleStatement(query string) (string, error) {
+	for _, line := range strings.Split(query, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ".") {
+			return "", errors.New("input can't have lines starting with a dot, which the duckdb cli runs as dot commands, only sql")
+		}
+	}
+	return tools.SingleStatement(query)
+}
+
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func (t *ToolInstance) Shutdown() error {
+	return nil
+}
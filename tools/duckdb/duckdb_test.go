@@ -0,0 +1,56 @@
+package duckdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSingleStatement(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "SELECT 1", want: "SELECT 1"},
+		{query: "SELECT 1;", want: "SELECT 1"},
+		{query: "SELECT 1;;  \n", want: "SELECT 1"},
+		{query: "SELECT 'a' AS x -- done", want: "SELECT 'a' AS x -- done"},
+		{query: "SELECT 1; SELECT 2", wantErr: true},
+		{query: "SELECT 'it''s'; DROP TABLE x", wantErr: true},
+		{query: ".shell echo pwned", wantErr: true},
+		{query: ".shell echo pwned;", wantErr: true},
+		{query: "SELECT 1;\n.shell echo pwned", wantErr: true},
+		{query: "SELECT 1\n  .system echo pwned", wantErr: true},
+		{query: "SELECT 1;\r\n.read other.sql", wantErr: true},
+		{query: "SELECT 1.5", want: "SELECT 1.5"},
+	}
+	for _, tt := range tests {
+		got, err := singleStatement(tt.query)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("singleStatement(%q) = %q, want an error", tt.query, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("singleStatement(%q): unexpected error: %s", tt.query, err)
+		} else if got != tt.want {
+			t.Errorf("singleStatement(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCommandIsSafe(t *testing.T) {
+	tool := &ToolInstance{executablePath: "duckdb", directories: []string{"/data"}}
+	cmd := tool.command(context.Background(), "SELECT 1;")
+	safe := false
+	for _, arg := range cmd.Args[1:] {
+		safe = safe || arg == "-safe"
+	}
+	if !safe {
+		t.Errorf("duckdb runs without -safe, with args %q", cmd.Args)
+	}
+	if cmd.Dir != "/data" {
+		t.Errorf("duckdb runs in %q, want the first data directory", cmd.Dir)
+	}
+}
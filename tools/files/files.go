@@ -0,0 +1,367 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+const (
+	// Reads without a line range are cut off after this many lines, so a big file can't blow up the context window.
+	maxReadLines   = 500
+	maxListedFiles = 200
+)
+
+type Tool struct {
+}
+
+func (t *Tool) Name() string {
+	return "Files"
+}
+
+func (t *Tool) Description() string {
+	return "list, read, write and patch files in this conversation's workspace directory; read shows line numbers, which aren't part of the file; " +
+		"prefer patch over write for small changes to existing files; writes and patches need the user's approval"
+}
+
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"action": {Type: "string", Enum: []interface{}{"list", "read", "write", "patch"}},
+		"path":   {Type: "string", Description: "path of the file, or of the directory for list, relative to the workspace; empty for the workspace itself", Default: ""},
+		"input":  {Type: "string", Description: "a line range like 10-40 for read, or empty for the whole file; the new contents of the file for write; a unified diff of the file for patch", Default: ""},
+	}, "action")
+}
+
+func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
+	return &ToolInstance{
+		runtime: runtime,
+	}, nil
+}
+
+type ToolInstance struct {
+	runtime tools.AppRuntime
+}
+
+func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
+	action, ok := args["action"].(string)
+	if !ok {
+		return nil, fmt.Errorf("action is not a string")
+	}
+	path, ok := args["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("path is not a string")
+	}
+	input, ok := args["input"].(string)
+	if !ok {
+		return nil, fmt.Errorf("input is not a string")
+	}
+
+	// The workspace is looked up here, rather than when instantiating, as that happens with the app locked.
+	root, err := t.runtime.Workspace()
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't resolve workspace: %w", err)
+	}
+	fullPath, err := resolve(root, path)
+	if err != nil {
+		return nil, err
+	}
+	relPath, err := filepath.Rel(root, fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get relative path: %w", err)
+	}
+	relPath = filepath.ToSlash(relPath)
+
+	switch action {
+	case "list":
+		return listFiles(root, fullPath)
+	case "read":
+		return readFile(fullPath, relPath, input)
+	case "write":
+		old, err := readExisting(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		return t.write(ctx, root, fullPath, relPath, old, input)
+	case "patch":
+		old, err := readExisting(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		patched, err := applyPatch(old, input)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't apply patch to `%s`: %w", relPath, err)
+		}
+		return t.write(ctx, root, fullPath, relPath, old, patched)
+	default:
+		return nil, fmt.Errorf("unknown action `%s`, it has to be one of list, read, write or patch", action)
+	}
+}
+
+// resolve returns the absolute path, making sure it's in the workspace, also once symlinks are followed.
+func resolve(root, path string) (string, error) {
+	fullPath := filepath.Clean(path)
+	if !filepath.IsAbs(path) {
+		fullPath = filepath.Join(root, path)
+	}
+	// Only the part of the path that exists can be resolved, files that are about to be written may not yet.
+	existing := fullPath
+	var rest []string
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			fullPath = filepath.Join(append([]string{resolved}, rest...)...)
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("couldn't resolve path: %w", err)
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = append([]string{filepath.Base(existing)}, rest...)
+		existing = parent
+	}
+	if rel, err := filepath.Rel(root, fullPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("`%s` is outside of the workspace, only files in the workspace can be used", path)
+	}
+	return fullPath, nil
+}
+
+var errTooManyFiles = errors.New("too many files")
+
+func listFiles(root, dir string) (*tools.RunResult, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list files: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("`%s` is a file, use read to see its contents", filepath.Base(dir))
+	}
+
+	var files []string
+	truncated := false
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subdirectories are skipped.
+			return nil
+		}
+		if path == dir {
+			return nil
+		}
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), ".") {
+			// I.e. .git or .venv, which are too big to be of any use listed.
+			return filepath.SkipDir
+		}
+		if len(files) == maxListedFiles {
+			return errTooManyFiles
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			rel += "/"
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if errors.Is(err, errTooManyFiles) {
+		truncated = true
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't list files: %w", err)
+	}
+
+	result := fmt.Sprintf("found %d files and directories", len(files))
+	if len(files) == 0 {
+		result = "the directory is empty"
+	} else if truncated {
+		result = fmt.Sprintf("found more than %d files and directories, only the first %d are shown; list a subdirectory to see more", maxListedFiles, maxListedFiles)
+	}
+	return &tools.RunResult{
+		Result: result,
+		Output: strings.Join(files, "\n") + "\n",
+	}, nil
+}
+
+func readFile(fullPath, relPath, lineRange string) (*tools.RunResult, error) {
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read `%s`: %w", relPath, err)
+	}
+	if bytes.IndexByte(content, 0) != -1 {
+		return nil, fmt.Errorf("`%s` is a binary file", relPath)
+	}
+	lines := splitLines(string(content))
+
+	start, end, err := parseLineRange(lineRange, len(lines))
+	if err != nil {
+		return nil, err
+	}
+	var result string
+	switch {
+	case len(lines) == 0:
+		result = "`" + relPath + "` is empty"
+	case lineRange == "" && end-start+1 > maxReadLines:
+		end = start + maxReadLines - 1
+		result = fmt.Sprintf("lines %d-%d of `%s`, which has %d lines; read the rest with a line range", start, end, relPath, len(lines))
+	default:
+		result = fmt.Sprintf("lines %d-%d of `%s`, which has %d lines", start, end, relPath, len(lines))
+	}
+
+	var output strings.Builder
+	for i := start; i <= end && i <= len(lines); i++ {
+		fmt.Fprintf(&output, "%d\t%s\n", i, lines[i-1])
+	}
+	return &tools.RunResult{
+		Result: result,
+		Output: output.String(),
+	}, nil
+}
+
+// parseLineRange parses ranges like 10-40, 10- or 10, with lines counted from 1, an empty range being the whole file.
+func parseLineRange(lineRange string, lineCount int) (int, int, error) {
+	lineRange = strings.TrimSpace(lineRange)
+	if lineRange == "" {
+		return 1, lineCount, nil
+	}
+	startString, endString, isRange := strings.Cut(lineRange, "-")
+	start, err := strconv.Atoi(strings.TrimSpace(startString))
+	if err != nil || start < 1 {
+		return 0, 0, fmt.Errorf("invalid line range `%s`, it has to look like 10-40", lineRange)
+	}
+	end := start
+	if isRange {
+		end = lineCount
+		if endString = strings.TrimSpace(endString); endString != "" {
+			if end, err = strconv.Atoi(endString); err != nil || end < start {
+				return 0, 0, fmt.Errorf("invalid line range `%s`, it has to look like 10-40", lineRange)
+			}
+		}
+	}
+	if start > lineCount {
+		return 0, 0, fmt.Errorf("the file only has %d lines", lineCount)
+	}
+	if end > lineCount {
+		end = lineCount
+	}
+	return start, end, nil
+}
+
+// readExisting returns the contents of the file about to be written, or nothing if it doesn't exist yet.
+func readExisting(fullPath string) (string, error) {
+	content, err := os.ReadFile(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("couldn't read file: %w", err)
+	}
+	return string(content), nil
+}
+
+// write asks the user to approve the change, showing them its diff, and then writes the file.
+// If the user edits the arguments instead, i.e. the content, the call runs again with those.
+func (t *ToolInstance) write(ctx context.Context, root, fullPath, relPath, old, content string) (*tools.RunResult, error) {
+	_, err := os.Stat(fullPath)
+	exists := err == nil
+	fromFile := "a/" + relPath
+	if !exists {
+		fromFile = "/dev/null"
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        diffLines(old),
+		B:        diffLines(content),
+		FromFile: fromFile,
+		ToFile:   "b/" + relPath,
+		Context:  3,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't diff `%s`: %w", relPath, err)
+	}
+	if exists && old == content {
+		return &tools.RunResult{
+			Result: "`" + relPath + "` already has these contents, nothing was changed",
+		}, nil
+	}
+
+	risk, riskReason := tools.RiskLow, "creates a new file"
+	if exists {
+		risk, riskReason = tools.RiskMedium, "changes an existing file"
+	}
+	approval, err := t.runtime.WaitForApproval(ctx, tools.ApprovalRequest{
+		Message:          fmt.Sprintf("write `%s`", relPath),
+		WorkingDirectory: root,
+		Risk:             risk,
+		RiskReason:       riskReason,
+		Preview:          diff,
+		PreviewType:      tools.PreviewTypeDiff,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("user did not approve: %w", err)
+	}
+	if err := approval.Rejected(); err != nil {
+		return nil, err
+	}
+	if approval.Args != nil {
+		return t.Run(ctx, approval.Args)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(fullPath); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return nil, fmt.Errorf("couldn't create directory: %w", err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), mode); err != nil {
+		return nil, fmt.Errorf("couldn't write `%s`: %w", relPath, err)
+	}
+
+	result := fmt.Sprintf("successfully wrote `%s`, which now has %d lines", relPath, len(splitLines(content)))
+	if !exists {
+		result = fmt.Sprintf("successfully created `%s` with %d lines", relPath, len(splitLines(content)))
+	}
+	return &tools.RunResult{
+		Result: result,
+	}, nil
+}
+
+// splitLines splits the content into lines without their line endings.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	content = strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	return strings.Split(content, "\n")
+}
+
+// diffLines splits the content into lines for difflib, which expects every line to end with a newline.
+func diffLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+func (t *ToolInstance) Shutdown() error {
+	return nil
+}
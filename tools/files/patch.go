@@ -0,0 +1,174 @@
+package files
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunk is a single @@ section of a unified diff.
+type hunk struct {
+	// The line the hunk starts at in the original file, counted from 1, or -1 if the header doesn't say.
+	oldStart int
+	oldLines []string
+	newLines []string
+}
+
+var hunkHeaderRegexp = regexp.MustCompile(`^@@ -(\d+)`)
+
+// applyPatch applies a unified diff to the content.
+// Models tend to get line numbers and counts wrong, so hunks are located by their context and removed lines instead,
+// preferring the match closest to where the header says the hunk should be.
+func applyPatch(content, diff string) (string, error) {
+	hunks, newlineAtEOF, err := parsePatch(diff)
+	if err != nil {
+		return "", err
+	}
+
+	lineEnding := "\n"
+	if strings.Contains(content, "\r\n") {
+		lineEnding = "\r\n"
+	}
+	lines := splitLines(content)
+	if newlineAtEOF == nil {
+		keep := content == "" || strings.HasSuffix(content, "\n")
+		newlineAtEOF = &keep
+	}
+
+	var out []string
+	pos := 0
+	for i, h := range hunks {
+		at, ok := findHunk(lines, pos, h)
+		if !ok {
+			return "", fmt.Errorf("the context and removed lines of hunk %d don't match the file, read the file again and make a new patch", i+1)
+		}
+		out = append(out, lines[pos:at]...)
+		out = append(out, h.newLines...)
+		pos = at + len(h.oldLines)
+	}
+	out = append(out, lines[pos:]...)
+
+	if len(out) == 0 {
+		return "", nil
+	}
+	patched := strings.Join(out, lineEnding)
+	if *newlineAtEOF {
+		patched += lineEnding
+	}
+	return patched, nil
+}
+
+// parsePatch parses the hunks of the diff, ignoring its file headers, as the file is given separately.
+// It also returns whether the patched file ends with a newline, or nil if the diff doesn't say.
+func parsePatch(diff string) ([]hunk, *bool, error) {
+	var hunks []hunk
+	var newlineAtEOF *bool
+	var current *hunk
+	lastOp := byte(0)
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			hunks = append(hunks, hunk{oldStart: -1})
+			current = &hunks[len(hunks)-1]
+			if match := hunkHeaderRegexp.FindStringSubmatch(line); match != nil {
+				current.oldStart, _ = strconv.Atoi(match[1])
+			}
+			lastOp = 0
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "),
+			strings.HasPrefix(line, "+++ ") && i > 0 && strings.HasPrefix(lines[i-1], "--- "):
+			// The headers of the next file's diff, or of the only one.
+			current = nil
+		case current == nil:
+			// I.e. "diff --git" or "index" lines.
+		case strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file" refers to the line before it. After a removed line, it's only the original file that lacks the newline.
+			newline := lastOp == '-'
+			if !newline || newlineAtEOF == nil {
+				newlineAtEOF = &newline
+			}
+		case line == "" && i == len(lines)-1:
+			// The diff's trailing newline.
+		case line == "" || line[0] == ' ':
+			// Empty context lines usually had their leading space stripped along with trailing whitespace.
+			text := strings.TrimPrefix(line, " ")
+			current.oldLines = append(current.oldLines, text)
+			current.newLines = append(current.newLines, text)
+			lastOp = ' '
+		case line[0] == '-':
+			current.oldLines = append(current.oldLines, line[1:])
+			lastOp = '-'
+		case line[0] == '+':
+			current.newLines = append(current.newLines, line[1:])
+			lastOp = '+'
+		default:
+			return nil, nil, fmt.Errorf("line %d of the patch doesn't start with a space, - or +: `%s`", i+1, line)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, nil, fmt.Errorf("the patch has no hunks, it has to be a unified diff with @@ headers")
+	}
+	return hunks, newlineAtEOF, nil
+}
+
+// findHunk returns where the hunk's old lines are in the file, looking only from pos on, as hunks are applied in order.
+// Exact matches are preferred, but differences in trailing whitespace are tolerated.
+func findHunk(lines []string, pos int, h hunk) (int, bool) {
+	if len(h.oldLines) == 0 {
+		// A pure insertion has nothing to match, so it goes after the line the header says, or at the end without one.
+		if h.oldStart < 0 {
+			return len(lines), true
+		}
+		return clamp(h.oldStart, pos, len(lines)), true
+	}
+	expected := pos
+	if h.oldStart > 0 {
+		expected = clamp(h.oldStart-1, pos, len(lines))
+	}
+
+	for _, equal := range []func(a, b string) bool{
+		func(a, b string) bool { return a == b },
+		func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	} {
+		best := -1
+		for at := pos; at+len(h.oldLines) <= len(lines); at++ {
+			if !matchesAt(lines, at, h.oldLines, equal) {
+				continue
+			}
+			if best == -1 || distance(at, expected) < distance(best, expected) {
+				best = at
+			}
+		}
+		if best != -1 {
+			return best, true
+		}
+	}
+	return 0, false
+}
+
+func matchesAt(lines []string, at int, want []string, equal func(a, b string) bool) bool {
+	for i, line := range want {
+		if !equal(lines[at+i], line) {
+			return false
+		}
+	}
+	return true
+}
+
+func distance(a, b int) int {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
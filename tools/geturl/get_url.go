@@ -25,10 +25,10 @@ func (t *Tool) Description() string {
 	return "fetch the contents of a url; useful for getting details about a search result"
 }
 
-func (t *Tool) ArgumentDescriptions() map[string]string {
-	return map[string]string{
-		"url": "url to get",
-	}
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"url": {Type: "string", Description: "url to get"},
+	}, "url")
 }
 
 func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
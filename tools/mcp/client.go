@@ -0,0 +1,313 @@
+// Package mcp is a Model Context Protocol client, which makes the tools of MCP servers available as Cuttlefish tools.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+const protocolVersion = "2025-06-18"
+
+// message is a JSON-RPC 2.0 request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+func (m *message) isRequest() bool {
+	return m.Method != "" && len(m.ID) > 0
+}
+
+func (m *message) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// transport delivers messages to the server. Messages from the server are passed to the handler it was created with.
+type transport interface {
+	send(ctx context.Context, msg *message) error
+	close() error
+}
+
+// ErrClosed is returned for calls on a client whose connection is gone, i.e. because the server exited.
+var ErrClosed = errors.New("connection to mcp server closed")
+
+// Client is a connection to a single MCP server.
+type Client struct {
+	transport transport
+
+	m       sync.Mutex
+	nextID  int64
+	pending map[int64]chan *message
+	closed  bool
+	err     error
+}
+
+// Connect starts or connects to the server and goes through the initialization handshake.
+func Connect(ctx context.Context, server database.MCPServer) (*Client, error) {
+	c := &Client{
+		pending: map[int64]chan *message{},
+	}
+	var err error
+	switch {
+	case server.Command != "":
+		c.transport, err = startStdio(server.Command, server.Env, c.handle, c.fail)
+	case server.URL != "":
+		c.transport, err = newHTTP(server.URL, server.Headers, c.handle)
+	default:
+		return nil, fmt.Errorf("there's neither a command nor a url")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var initializeResult struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if err := c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo": map[string]interface{}{
+			"name":    "cuttlefish",
+			"version": "0.0.1",
+		},
+	}, &initializeResult); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("couldn't initialize: %w", err)
+	}
+	if t, ok := c.transport.(*httpTransport); ok {
+		t.setProtocolVersion(initializeResult.ProtocolVersion)
+	}
+	if err := c.notify(ctx, "notifications/initialized", nil); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("couldn't initialize: %w", err)
+	}
+	return c, nil
+}
+
+type ToolInfo struct {
+	Name        string        `json:"name"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	InputSchema *tools.Schema `json:"inputSchema"`
+	Annotations struct {
+		// Hints about what the tool does, which are only used to hint at the risk in approval requests, as servers could be lying.
+		ReadOnlyHint    *bool `json:"readOnlyHint"`
+		DestructiveHint *bool `json:"destructiveHint"`
+	} `json:"annotations"`
+}
+
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var out []ToolInfo
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var result struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		out = append(out, result.Tools...)
+		if result.NextCursor == "" {
+			return out, nil
+		}
+		cursor = result.NextCursor
+	}
+}
+
+type CallToolResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent"`
+	IsError           bool            `json:"isError"`
+}
+
+// Content is a text, image, audio, resource link or embedded resource content block.
+type Content struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+	URI      string `json:"uri"`
+	Resource *struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"resource"`
+}
+
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (*CallToolResult, error) {
+	var result CallToolResult
+	if err := c.call(ctx, "tools/call", map[string]interface{}{
+		"name":      name,
+		"arguments": args,
+	}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// call sends a request and waits for its response, telling the server to stop working on it if the context gets cancelled.
+func (c *Client) call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	c.m.Lock()
+	if c.closed {
+		c.m.Unlock()
+		return c.err
+	}
+	c.nextID++
+	id := c.nextID
+	responseChan := make(chan *message, 1)
+	c.pending[id] = responseChan
+	c.m.Unlock()
+	defer func() {
+		c.m.Lock()
+		delete(c.pending, id)
+		c.m.Unlock()
+	}()
+
+	msg, err := newMessage(method, params)
+	if err != nil {
+		return err
+	}
+	msg.ID = json.RawMessage(strconv.FormatInt(id, 10))
+	if err := c.transport.send(ctx, msg); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrClosed) {
+			c.fail(err)
+		}
+		return err
+	}
+
+	select {
+	case response, ok := <-responseChan:
+		if !ok {
+			// The connection closed while waiting.
+			c.m.Lock()
+			defer c.m.Unlock()
+			return c.err
+		}
+		if response.Error != nil {
+			return response.Error
+		}
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return fmt.Errorf("couldn't decode %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		// Best-effort, the server may well have finished already.
+		go c.notify(context.Background(), "notifications/cancelled", map[string]interface{}{
+			"requestId": id,
+			"reason":    ctx.Err().Error(),
+		})
+		return ctx.Err()
+	}
+}
+
+func (c *Client) notify(ctx context.Context, method string, params interface{}) error {
+	msg, err := newMessage(method, params)
+	if err != nil {
+		return err
+	}
+	return c.transport.send(ctx, msg)
+}
+
+func newMessage(method string, params interface{}) (*message, error) {
+	msg := &message{
+		JSONRPC: "2.0",
+		Method:  method,
+	}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't encode %s params: %w", method, err)
+		}
+		msg.Params = data
+	}
+	return msg, nil
+}
+
+// handle dispatches a message from the server.
+func (c *Client) handle(msg *message) {
+	switch {
+	case msg.isResponse():
+		id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+		if err != nil {
+			return
+		}
+		c.m.Lock()
+		responseChan, ok := c.pending[id]
+		delete(c.pending, id)
+		c.m.Unlock()
+		if ok {
+			responseChan <- msg
+		}
+	case msg.isRequest():
+		// None of the client capabilities, like sampling, are supported, but servers may still check whether the client is alive.
+		response := &message{
+			JSONRPC: "2.0",
+			ID:      msg.ID,
+		}
+		if msg.Method == "ping" {
+			response.Result = json.RawMessage("{}")
+		} else {
+			response.Error = &rpcError{Code: -32601, Message: "method not found"}
+		}
+		go c.transport.send(context.Background(), response)
+	default:
+		// Notifications, i.e. logs or progress, aren't shown anywhere.
+	}
+}
+
+// fail closes the client because the connection is gone, failing all pending calls.
+func (c *Client) fail(err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.err = err
+	if !errors.Is(err, ErrClosed) {
+		c.err = fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	for id, responseChan := range c.pending {
+		close(responseChan)
+		delete(c.pending, id)
+	}
+}
+
+// Closed returns whether the connection is gone, in which case a new client has to be connected.
+func (c *Client) Closed() bool {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.closed
+}
+
+func (c *Client) Close() error {
+	c.fail(errors.New("closed by client"))
+	return c.transport.close()
+}
@@ -0,0 +1,174 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// httpTransport uses the streamable HTTP transport, where each message is POSTed to the server,
+// which answers requests either with a JSON response, or with a stream of server-sent events ending with the response.
+type httpTransport struct {
+	url     string
+	headers http.Header
+	handle  func(*message)
+	client  *http.Client
+
+	m               sync.Mutex
+	sessionID       string
+	protocolVersion string
+}
+
+func newHTTP(url string, headers []string, handle func(*message)) (*httpTransport, error) {
+	t := &httpTransport{
+		url:     url,
+		headers: http.Header{},
+		handle:  handle,
+		client:  &http.Client{},
+	}
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return nil, fmt.Errorf("header `%s` has to look like Name: value", header)
+		}
+		t.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return t, nil
+}
+
+func (t *httpTransport) setProtocolVersion(version string) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.protocolVersion = version
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	t.m.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	if t.protocolVersion != "" {
+		req.Header.Set("MCP-Protocol-Version", t.protocolVersion)
+	}
+	t.m.Unlock()
+	return req, nil
+}
+
+func (t *httpTransport) send(ctx context.Context, msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("couldn't encode message: %w", err)
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	res, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't send message: %w", err)
+	}
+	defer res.Body.Close()
+
+	if sessionID := res.Header.Get("Mcp-Session-Id"); sessionID != "" {
+		t.m.Lock()
+		t.sessionID = sessionID
+		t.m.Unlock()
+	}
+	switch {
+	case res.StatusCode == http.StatusAccepted:
+		// Notifications and responses don't get one.
+		return nil
+	case res.StatusCode == http.StatusNotFound && req.Header.Get("Mcp-Session-Id") != "":
+		return fmt.Errorf("%w: the server ended the session", ErrClosed)
+	case res.StatusCode < 200 || res.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("server responded with %s: %s", res.Status, strings.TrimSpace(string(body)))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var response message
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			return fmt.Errorf("couldn't decode response: %w", err)
+		}
+		t.handle(&response)
+		return nil
+	case "text/event-stream":
+		return t.readEvents(res.Body, msg.ID)
+	default:
+		if msg.isRequest() {
+			return fmt.Errorf("server responded with unexpected content type `%s`", mediaType)
+		}
+		return nil
+	}
+}
+
+// readEvents passes the messages in the event stream to the handler, until the response to the request with the given ID.
+func (t *httpTransport) readEvents(r io.Reader, id json.RawMessage) error {
+	reader := bufio.NewReader(r)
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			data.WriteString("\n")
+		case line == "" && data.Len() > 0:
+			var msg message
+			if json.Unmarshal([]byte(data.String()), &msg) == nil {
+				t.handle(&msg)
+				if msg.isResponse() && bytes.Equal(msg.ID, id) {
+					return nil
+				}
+			}
+			data.Reset()
+		}
+		if err == io.EOF {
+			if len(id) > 0 {
+				return fmt.Errorf("event stream ended without a response")
+			}
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("couldn't read event stream: %w", err)
+		}
+	}
+}
+
+// close ends the session, which the server may not support, so any errors are ignored.
+func (t *httpTransport) close() error {
+	t.m.Lock()
+	sessionID := t.sessionID
+	t.m.Unlock()
+	if sessionID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := t.newRequest(ctx, http.MethodDelete, nil)
+	if err != nil {
+		return nil
+	}
+	if res, err := t.client.Do(req); err == nil {
+		res.Body.Close()
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/kballard/go-shellquote"
+
+	"cuttlefish/tools/process"
+)
+
+// stdioTransport talks to a server it started as a subprocess, with a JSON-RPC message per line on its stdin and stdout.
+type stdioTransport struct {
+	cmd *exec.Cmd
+
+	m     sync.Mutex
+	stdin io.WriteCloser
+}
+
+func startStdio(command string, env []string, handle func(*message), fail func(error)) (*stdioTransport, error) {
+	args, err := shellquote.Split(command)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse command: %w", err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("command is empty")
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	process.SetProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create stdout pipe: %w", err)
+	}
+	// The end of stderr explains why the server exited, if it does.
+	stderr := &tailBuffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("couldn't start `%s`: %w", command, err)
+	}
+
+	t := &stdioTransport{
+		cmd:   cmd,
+		stdin: stdin,
+	}
+	go func() {
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				var msg message
+				// Servers aren't supposed to print anything else to stdout, but if they do, it's skipped.
+				if json.Unmarshal(line, &msg) == nil {
+					handle(&msg)
+				}
+			}
+			if err != nil {
+				break
+			}
+		}
+		err := cmd.Wait()
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			err = fmt.Errorf("%v: %s", err, message)
+		}
+		fail(fmt.Errorf("server exited: %w", err))
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) send(ctx context.Context, msg *message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("couldn't encode message: %w", err)
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", ErrClosed, err)
+	}
+	return nil
+}
+
+// close kills the server along with anything it started, there's no state in it worth waiting for.
+func (t *stdioTransport) close() error {
+	t.m.Lock()
+	t.stdin.Close()
+	t.m.Unlock()
+	if err := process.KillProcessGroup(t.cmd); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+const maxStderrTail = 2048
+
+type tailBuffer struct {
+	m   sync.Mutex
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > maxStderrTail {
+		b.buf = b.buf[len(b.buf)-maxStderrTail:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.m.Lock()
+	defer b.m.Unlock()
+	return string(b.buf)
+}
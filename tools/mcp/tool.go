@@ -0,0 +1,273 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+// Results are cut down to this size, so a chatty server can't blow up the context window.
+const maxOutputBytes = 32 * 1024
+
+// Server is a configured MCP server.
+// Each conversation using its tools gets its own session, which the tools share, so that i.e. a browser's state carries over between them.
+type Server struct {
+	config database.MCPServer
+
+	m        sync.Mutex
+	sessions map[int]*session
+}
+
+func NewServer(config database.MCPServer) *Server {
+	return &Server{
+		config:   config,
+		sessions: map[int]*session{},
+	}
+}
+
+// Tools connects to the server just to list its tools.
+func (s *Server) Tools(ctx context.Context) ([]*Tool, error) {
+	client, err := Connect(ctx, s.config)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	infos, err := client.ListTools(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't list tools: %w", err)
+	}
+	out := make([]*Tool, len(infos))
+	for i, info := range infos {
+		if info.InputSchema == nil {
+			info.InputSchema = &tools.Schema{}
+		}
+		info.InputSchema.Type = "object"
+		out[i] = &Tool{
+			server: s,
+			info:   info,
+		}
+	}
+	return out, nil
+}
+
+// session is a conversation's connection to the server, which is made when the first tool gets called,
+// and closed once the last of the conversation's tool instances is shut down.
+type session struct {
+	// Held while connecting, so that concurrent calls don't start the server twice.
+	m      sync.Mutex
+	client *Client
+	refs   int
+}
+
+func (s *Server) acquire(conversationID int) *session {
+	s.m.Lock()
+	defer s.m.Unlock()
+	sess, ok := s.sessions[conversationID]
+	if !ok {
+		sess = &session{}
+		s.sessions[conversationID] = sess
+	}
+	sess.refs++
+	return sess
+}
+
+func (s *Server) release(conversationID int) error {
+	s.m.Lock()
+	sess, ok := s.sessions[conversationID]
+	if !ok {
+		s.m.Unlock()
+		return nil
+	}
+	sess.refs--
+	if sess.refs > 0 {
+		s.m.Unlock()
+		return nil
+	}
+	delete(s.sessions, conversationID)
+	s.m.Unlock()
+
+	sess.m.Lock()
+	defer sess.m.Unlock()
+	if sess.client == nil {
+		return nil
+	}
+	return sess.client.Close()
+}
+
+// connection returns the session's client, (re)connecting if there's none or the server went away.
+func (sess *session) connection(ctx context.Context, config database.MCPServer) (*Client, error) {
+	sess.m.Lock()
+	defer sess.m.Unlock()
+	if sess.client != nil && !sess.client.Closed() {
+		return sess.client, nil
+	}
+	if sess.client != nil {
+		sess.client.Close()
+		sess.client = nil
+	}
+	client, err := Connect(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	sess.client = client
+	return client, nil
+}
+
+type Tool struct {
+	server *Server
+	info   ToolInfo
+}
+
+var invalidIDCharacters = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// maxIDLength is the longest function name the model providers accept.
+const maxIDLength = 64
+
+// ID is what the tool is enabled and called by. It has to be a valid function name for the model providers.
+// Long IDs are cut down and end with a hash of the full names instead, so that tools sharing a long prefix stay apart.
+func (t *Tool) ID() string {
+	id := "mcp_" + invalidIDCharacters.ReplaceAllString(t.server.config.Name, "_") + "_" + invalidIDCharacters.ReplaceAllString(t.info.Name, "_")
+	if len(id) > maxIDLength {
+		hash := sha256.Sum256([]byte(t.server.config.Name + "\x00" + t.info.Name))
+		suffix := "_" + hex.EncodeToString(hash[:4])
+		id = id[:maxIDLength-len(suffix)] + suffix
+	}
+	return id
+}
+
+func (t *Tool) Name() string {
+	name := t.info.Title
+	if name == "" {
+		name = t.info.Name
+	}
+	return t.server.config.Name + ": " + name
+}
+
+func (t *Tool) Description() string {
+	return t.info.Description
+}
+
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return t.info.InputSchema
+}
+
+// risk is based on the server's hints, so it's only as trustworthy as the server.
+func (t *Tool) risk() (tools.Risk, string) {
+	annotations := t.info.Annotations
+	switch {
+	case annotations.ReadOnlyHint != nil && *annotations.ReadOnlyHint:
+		return tools.RiskLow, "the server says it only reads"
+	case annotations.DestructiveHint != nil && *annotations.DestructiveHint:
+		return tools.RiskHigh, "the server says it may delete or overwrite data"
+	}
+	return tools.RiskMedium, ""
+}
+
+func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
+	conversationID := runtime.ConversationID()
+	return &ToolInstance{
+		tool:           t,
+		runtime:        runtime,
+		conversationID: conversationID,
+		session:        t.server.acquire(conversationID),
+	}, nil
+}
+
+type ToolInstance struct {
+	tool           *Tool
+	runtime        tools.AppRuntime
+	conversationID int
+	session        *session
+}
+
+func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
+	config := t.tool.server.config
+	if config.RequireApproval {
+		argsJSON, err := json.MarshalIndent(args, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("couldn't encode arguments: %w", err)
+		}
+		risk, riskReason := t.tool.risk()
+		approval, err := t.runtime.WaitForApproval(ctx, tools.ApprovalRequest{
+			Message:     fmt.Sprintf("call `%s` on mcp server `%s`", t.tool.info.Name, config.Name),
+			Risk:        risk,
+			RiskReason:  riskReason,
+			Preview:     string(argsJSON),
+			PreviewType: tools.PreviewTypeJSON,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("user did not approve: %w", err)
+		}
+		if err := approval.Rejected(); err != nil {
+			return nil, err
+		}
+		if approval.Args != nil {
+			return t.Run(ctx, approval.Args)
+		}
+	}
+
+	client, err := t.session.connection(ctx, config)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't connect to mcp server `%s`: %w", config.Name, err)
+	}
+	result, err := client.CallTool(ctx, t.tool.info.Name, args)
+	if errors.Is(err, ErrClosed) {
+		return nil, fmt.Errorf("%w, it will be restarted for the next call", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	output := formatContent(result)
+	if result.IsError {
+		return nil, errors.New(strings.TrimSpace(output))
+	}
+	if len(output) > maxOutputBytes {
+		output = tools.Truncate(output, maxOutputBytes) + "\n... (the rest was cut off)"
+	}
+	return &tools.RunResult{
+		Result: fmt.Sprintf("successfully called `%s` on `%s`", t.tool.info.Name, config.Name),
+		Output: output + "\n",
+	}, nil
+}
+
+// formatContent turns the result's content into text, as that's all the models get to see.
+func formatContent(result *CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		switch content.Type {
+		case "text":
+			parts = append(parts, content.Text)
+		case "resource":
+			if content.Resource != nil && content.Resource.Text != "" {
+				parts = append(parts, content.Resource.Text)
+			} else if content.Resource != nil {
+				parts = append(parts, fmt.Sprintf("[binary resource %s]", content.Resource.URI))
+			}
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource %s]", content.URI))
+		default:
+			// Images and audio are base64, which would be a waste of the context window.
+			parts = append(parts, fmt.Sprintf("[%s content of type %s]", content.Type, content.MimeType))
+		}
+	}
+	if len(parts) == 0 && len(result.StructuredContent) > 0 {
+		return string(result.StructuredContent)
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (t *ToolInstance) Shutdown() error {
+	if err := t.tool.server.release(t.conversationID); err != nil {
+		return fmt.Errorf("couldn't stop mcp server `%s`: %w", t.tool.server.config.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Package plugin makes executables in the plugins directory available as tools.
+//
+// A plugin is run with a single argument, which is the command:
+//   - `describe` prints a json object describing the tool, with its name, description, arguments,
+//     a map of argument names to their descriptions, and optionally requireApproval. Instead of arguments,
+//     it may have an argumentSchema, which is a JSON Schema of the arguments object.
+//   - `run` reads the arguments as a json object from stdin, and prints a json object with the result,
+//     which has a result and optionally an output and customResultTag. On failure, it either prints
+//     a json object with an error, or exits with a non-zero exit code and the error on stderr.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+	"cuttlefish/tools/process"
+)
+
+// Plugins are described in parallel when the app starts, so a broken one can only delay it this much.
+const describeTimeout = 10 * time.Second
+
+type description struct {
+	Name            string            `json:"name"`
+	Description     string            `json:"description"`
+	Arguments       map[string]string `json:"arguments"`
+	ArgumentSchema  *tools.Schema     `json:"argumentSchema"`
+	RequireApproval bool              `json:"requireApproval"`
+}
+
+type runResponse struct {
+	Result          string `json:"result"`
+	CustomResultTag string `json:"customResultTag"`
+	Output          string `json:"output"`
+	Error           string `json:"error"`
+}
+
+var invalidIDCharacters = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Load describes all the executables in the directory, and returns the resulting tools by ID,
+// which is the executable's file name without its extension. Plugins that fail to describe themselves are skipped,
+// and so are plugins sharing an ID, i.e. `foo.sh` and `foo.py`, as it's unclear which one is meant.
+func Load(ctx context.Context, dir string) (map[string]tools.Tool, []error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, []error{fmt.Errorf("couldn't list plugins: %w", err)}
+	}
+
+	var ids []string
+	names := map[string][]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !isExecutable(info) {
+			continue
+		}
+		id := invalidIDCharacters.ReplaceAllString(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())), "_")
+		if _, ok := names[id]; !ok {
+			ids = append(ids, id)
+		}
+		names[id] = append(names[id], entry.Name())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, describeTimeout)
+	defer cancel()
+
+	var m sync.Mutex
+	out := map[string]tools.Tool{}
+	var errs []error
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		id := id
+		if len(names[id]) > 1 {
+			m.Lock()
+			errs = append(errs, fmt.Errorf("couldn't load plugins `%s`, as they all have the id `%s`, rename all but one of them", strings.Join(names[id], "`, `"), id))
+			m.Unlock()
+			continue
+		}
+		name := names[id][0]
+		path := filepath.Join(dir, name)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tool, err := describe(ctx, path)
+			m.Lock()
+			defer m.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("couldn't load plugin `%s`: %w", name, err))
+				return
+			}
+			out[id] = tool
+		}()
+	}
+	wg.Wait()
+	return out, errs
+}
+
+func isExecutable(info os.FileInfo) bool {
+	if runtime.GOOS == "windows" {
+		switch strings.ToLower(filepath.Ext(info.Name())) {
+		case ".exe", ".bat", ".cmd":
+			return true
+		}
+		return false
+	}
+	return info.Mode().IsRegular() && info.Mode().Perm()&0111 != 0
+}
+
+func describe(ctx context.Context, path string) (*Tool, error) {
+	stdout, err := run(ctx, path, "describe", nil)
+	if err != nil {
+		return nil, err
+	}
+	var desc description
+	if err := json.Unmarshal(stdout, &desc); err != nil {
+		return nil, fmt.Errorf("couldn't decode description: %w", err)
+	}
+	if desc.Name == "" {
+		return nil, fmt.Errorf("description has no name")
+	}
+	if desc.ArgumentSchema != nil {
+		desc.ArgumentSchema.Type = "object"
+	}
+	return &Tool{
+		path:        path,
+		description: desc,
+	}, nil
+}
+
+// run runs the plugin, returning its stdout, or its stderr as the error if it fails.
+func run(ctx context.Context, path string, command string, stdin []byte) ([]byte, error) {
+	cmd := exec.Command(path, command)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdin = bytes.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	process.SetProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("couldn't start plugin: %w", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				return nil, errors.New(message)
+			}
+			return nil, fmt.Errorf("plugin failed: %w", err)
+		}
+		return stdout.Bytes(), nil
+	case <-ctx.Done():
+		// Whatever the plugin started goes down with it.
+		process.KillProcessGroup(cmd)
+		<-done
+		return nil, ctx.Err()
+	}
+}
+
+type Tool struct {
+	path        string
+	description description
+}
+
+func (t *Tool) Name() string {
+	return t.description.Name
+}
+
+func (t *Tool) Description() string {
+	return t.description.Description
+}
+
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	if t.description.ArgumentSchema != nil {
+		return t.description.ArgumentSchema
+	}
+	// Arguments only described by name can have any type, but all of them have to be set.
+	properties := map[string]*tools.Schema{}
+	var required []string
+	for name, description := range t.description.Arguments {
+		properties[name] = &tools.Schema{Description: description}
+		required = append(required, name)
+	}
+	sort.Strings(required)
+	return tools.ObjectSchema(properties, required...)
+}
+
+func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
+	return &ToolInstance{
+		tool:    t,
+		runtime: runtime,
+	}, nil
+}
+
+// ToolInstance runs the plugin anew for each call, so there's nothing to keep around.
+type ToolInstance struct {
+	tool    *Tool
+	runtime tools.AppRuntime
+}
+
+func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
+	if t.tool.description.RequireApproval {
+		argsJSON, err := json.MarshalIndent(args, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("couldn't encode arguments: %w", err)
+		}
+		approval, err := t.runtime.WaitForApproval(ctx, tools.ApprovalRequest{
+			Message:     fmt.Sprintf("run plugin `%s`", t.tool.description.Name),
+			Preview:     string(argsJSON),
+			PreviewType: tools.PreviewTypeJSON,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("user did not approve: %w", err)
+		}
+		if err := approval.Rejected(); err != nil {
+			return nil, err
+		}
+		if approval.Args != nil {
+			return t.Run(ctx, approval.Args)
+		}
+	}
+
+	input, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode arguments: %w", err)
+	}
+	stdout, err := run(ctx, t.tool.path, "run", input)
+	if err != nil {
+		return nil, err
+	}
+	var res runResponse
+	if err := json.Unmarshal(stdout, &res); err != nil {
+		return nil, fmt.Errorf("couldn't decode plugin result: %w", err)
+	}
+	if res.Error != "" {
+		return nil, errors.New(res.Error)
+	}
+	return &tools.RunResult{
+		Result:          res.Result,
+		CustomResultTag: res.CustomResultTag,
+		Output:          res.Output,
+	}, nil
+}
+
+func (t *ToolInstance) Shutdown() error {
+	return nil
+}
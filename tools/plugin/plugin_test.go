@@ -0,0 +1,41 @@
+//go:build !windows
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writePlugin(t *testing.T, dir string, name string, toolName string) {
+	script := "#!/bin/sh\necho '{\"name\": \"" + toolName + "\", \"description\": \"test\"}'\n"
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSkipsCollidingIDs(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "foo.sh", "Foo Shell")
+	writePlugin(t, dir, "foo.py", "Foo Python")
+	writePlugin(t, dir, "bar.sh", "Bar")
+
+	loaded, errs := Load(context.Background(), dir)
+	if _, ok := loaded["foo"]; ok {
+		t.Errorf("loaded one of the plugins with the id foo, want both skipped")
+	}
+	if _, ok := loaded["bar"]; !ok {
+		t.Errorf("didn't load bar, which doesn't collide")
+	}
+	if len(errs) != 1 {
+		t.Fatalf("got errors %v, want one about the collision", errs)
+	}
+	for _, name := range []string{"foo.sh", "foo.py"} {
+		if !strings.Contains(errs[0].Error(), name) {
+			t.Errorf("error %q doesn't name %s", errs[0], name)
+		}
+	}
+}
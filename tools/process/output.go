@@ -0,0 +1,112 @@
+package process
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+var ErrExited = errors.New("process exited")
+
+// NewMarker returns a random string to mark the end of a command's output with, which the command itself won't print by accident.
+func NewMarker() (string, error) {
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("couldn't generate marker: %w", err)
+	}
+	return "__cuttlefish_done_" + hex.EncodeToString(nonce), nil
+}
+
+// MarkedOutput reads the output of a long-lived process that runs one command after another,
+// with the process printing a line starting with the marker after each command.
+// It keeps consuming output, also between commands, so that background processes never block on a full pipe.
+// Anything printed in the background ends up in the output of the next command.
+type MarkedOutput struct {
+	marker string
+
+	m      sync.Mutex
+	lines  []string
+	exited bool
+	// Signalled whenever there are new lines or the process exited.
+	updated chan struct{}
+}
+
+func NewMarkedOutput(r io.ReadCloser, marker string) *MarkedOutput {
+	o := &MarkedOutput{
+		marker:  marker,
+		updated: make(chan struct{}, 1),
+	}
+	go o.read(r)
+	return o
+}
+
+func (o *MarkedOutput) read(r io.ReadCloser) {
+	defer r.Close()
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		o.m.Lock()
+		if line != "" {
+			o.lines = append(o.lines, strings.TrimSuffix(line, "\n"))
+		}
+		if err != nil {
+			o.exited = true
+		}
+		o.m.Unlock()
+
+		select {
+		case o.updated <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Wait waits for the next marker line, and returns the output before it and the rest of the marker line.
+// The process is expected to print a newline before the marker, which isn't part of the output.
+// If the process exits first, it returns whatever it printed along with ErrExited.
+// Meanwhile, complete lines are written to w as they come in, if it isn't nil.
+func (o *MarkedOutput) Wait(ctx context.Context, w io.Writer) (output string, status string, err error) {
+	written := 0
+	for {
+		o.m.Lock()
+		for i, line := range o.lines {
+			if !strings.HasPrefix(line, o.marker) {
+				continue
+			}
+			output = strings.Join(o.lines[:i], "\n")
+			status = strings.TrimPrefix(strings.TrimPrefix(line, o.marker), " ")
+			o.lines = o.lines[i+1:]
+			o.m.Unlock()
+			return output, status, nil
+		}
+		if o.exited {
+			output = strings.Join(o.lines, "\n")
+			o.lines = nil
+			o.m.Unlock()
+			return output, "", ErrExited
+		}
+		newLines := o.lines[written:]
+		written = len(o.lines)
+		o.m.Unlock()
+
+		if w != nil {
+			for _, line := range newLines {
+				io.WriteString(w, line+"\n")
+			}
+		}
+		select {
+		case <-o.updated:
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		}
+	}
+}
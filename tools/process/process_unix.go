@@ -0,0 +1,26 @@
+//go:build !windows
+
+// Package process has helpers for the long-lived processes tools keep around, like the terminal's shell.
+package process
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// SetProcessGroup puts the process into its own process group, so that killing it also stops whatever it started.
+func SetProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func KillProcessGroup(cmd *exec.Cmd) error {
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
+
+// Interrupt sends SIGINT to the process only, so it can handle it, i.e. by aborting what it's currently doing.
+func Interrupt(cmd *exec.Cmd) error {
+	return cmd.Process.Signal(syscall.SIGINT)
+}
@@ -0,0 +1,21 @@
+package process
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func SetProcessGroup(cmd *exec.Cmd) {
+}
+
+func KillProcessGroup(cmd *exec.Cmd) error {
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+func Interrupt(cmd *exec.Cmd) error {
+	return errors.New("interrupting processes isn't supported on Windows")
+}
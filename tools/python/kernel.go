@@ -0,0 +1,136 @@
+package python
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+	"cuttlefish/tools/process"
+	"cuttlefish/tools/sandbox"
+)
+
+//go:embed kernel.py
+var kernelSource string
+
+// How long an interrupted cell gets to stop before the kernel is killed instead.
+const interruptTimeout = 5 * time.Second
+
+// kernel is a long-lived Python interpreter running code cells in a shared namespace, so that i.e. loaded data is kept between cells.
+type kernel struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	output *process.MarkedOutput
+	exited bool
+	// Signals don't make it through bubblewrap, so a sandboxed kernel can't be interrupted, only killed.
+	sandboxed bool
+}
+
+func startKernel(interpreterPath string, sandboxSettings database.SandboxSettings, runtime tools.AppRuntime) (*kernel, error) {
+	marker, err := process.NewMarker()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(interpreterPath, "-u", "-c", kernelSource, marker)
+	cmd, err = sandbox.Command(cmd, sandboxSettings, runtime)
+	if err != nil {
+		return nil, err
+	}
+	process.SetProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create kernel stdin: %w", err)
+	}
+	outputReader, outputWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create kernel output pipe: %w", err)
+	}
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+	if err := cmd.Start(); err != nil {
+		outputReader.Close()
+		outputWriter.Close()
+		return nil, fmt.Errorf("couldn't start python interpreter: %w", err)
+	}
+	// The interpreter and its children hold their own copies now.
+	outputWriter.Close()
+	go cmd.Wait()
+
+	return &kernel{
+		cmd:       cmd,
+		stdin:     stdin,
+		output:    process.NewMarkedOutput(outputReader, marker),
+		sandboxed: sandboxSettings.Enabled,
+	}, nil
+}
+
+type cellRequest struct {
+	Code string `json:"code"`
+}
+
+type cellResult struct {
+	Output string `json:"-"`
+	// The repr of the cell's last expression, unless it's None or the cell doesn't end with an expression.
+	Value *string `json:"value"`
+	// The name of the exception the cell raised.
+	Error *string `json:"error"`
+}
+
+// run runs the cell and waits for it to finish, writing what it prints to w in the meantime. If the context is cancelled,
+// the cell gets interrupted, and if it doesn't stop in time, the kernel gets killed, losing its state.
+func (k *kernel) run(ctx context.Context, code string, w io.Writer) (*cellResult, error) {
+	request, err := json.Marshal(cellRequest{Code: code})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't encode cell: %w", err)
+	}
+	if _, err := k.stdin.Write(append(request, '\n')); err != nil {
+		k.exited = true
+		return &cellResult{}, process.ErrExited
+	}
+
+	output, status, err := k.output.Wait(ctx, w)
+	if errors.Is(err, process.ErrExited) {
+		k.exited = true
+		return &cellResult{Output: output}, err
+	} else if err != nil {
+		k.interrupt()
+		return nil, err
+	}
+
+	result := &cellResult{Output: output}
+	if err := json.Unmarshal([]byte(status), result); err != nil {
+		return nil, fmt.Errorf("couldn't decode cell result: %w", err)
+	}
+	return result, nil
+}
+
+func (k *kernel) interrupt() {
+	if k.sandboxed {
+		k.close()
+		return
+	}
+	if err := process.Interrupt(k.cmd); err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), interruptTimeout)
+		defer cancel()
+		// The interrupted cell's output is dropped, the model is told about the cancellation anyway.
+		if _, _, err := k.output.Wait(ctx, nil); err == nil {
+			return
+		}
+	}
+	k.close()
+}
+
+// close kills the interpreter along with anything it started.
+func (k *kernel) close() error {
+	k.exited = true
+	k.stdin.Close()
+	return process.KillProcessGroup(k.cmd)
+}
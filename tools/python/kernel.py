@@ -0,0 +1,77 @@
+# The Python tool's kernel. It runs code cells sent over stdin in a shared namespace.
+#
+# Each request is a json line with the code to run. The cell's output goes to stdout and stderr like usual,
+# and is followed by a line with the marker passed as the first argument and a json object with the result:
+# the repr of the last expression's value, if any, and the name of the exception the cell raised, if any.
+
+import ast
+import json
+import linecache
+import os
+import sys
+import traceback
+
+marker = sys.argv[1]
+
+# The requests come in over stdin, so the cells get /dev/null instead, to not swallow the following requests.
+requests = os.fdopen(os.dup(0), "r")
+devnull = os.open(os.devnull, os.O_RDONLY)
+os.dup2(devnull, 0)
+os.close(devnull)
+sys.stdin = open(os.devnull, "r")
+
+namespace = {"__name__": "__main__", "__builtins__": __builtins__}
+
+
+def run_cell(filename, code):
+    # Makes tracebacks show the lines of code, also for functions defined in earlier cells.
+    linecache.cache[filename] = (len(code), None, code.splitlines(True), filename)
+    tree = ast.parse(code, filename, "exec")
+    last_expression = None
+    if tree.body and isinstance(tree.body[-1], ast.Expr):
+        last_expression = ast.Expression(tree.body.pop().value)
+    exec(compile(tree, filename, "exec"), namespace)
+    if last_expression is None:
+        return None
+    value = eval(compile(last_expression, filename, "eval"), namespace)
+    if value is None:
+        return None
+    namespace["_"] = value
+    return repr(value)
+
+
+def print_exception(e):
+    # The kernel's own frames are of no interest to whoever wrote the cell.
+    tb = e.__traceback__
+    while tb is not None and not tb.tb_frame.f_code.co_filename.startswith("<cell-"):
+        tb = tb.tb_next
+    traceback.print_exception(type(e), e, tb)
+
+
+def main():
+    cell_count = 0
+    while True:
+        try:
+            line = requests.readline()
+        except KeyboardInterrupt:
+            # An interrupt that came in too late for the cell it was meant for.
+            continue
+        if not line:
+            return
+        request = json.loads(line)
+        cell_count += 1
+
+        response = {"value": None, "error": None}
+        try:
+            response["value"] = run_cell("<cell-%d>" % cell_count, request["code"])
+        # This includes SystemExit, as exiting would lose all state, and KeyboardInterrupt, which is how cells get interrupted.
+        except BaseException as e:
+            print_exception(e)
+            response["error"] = type(e).__name__
+        sys.stdout.flush()
+        sys.stderr.flush()
+        # The leading newline makes sure the marker starts a line, even if the output doesn't end with one.
+        os.write(1, ("\n" + marker + " " + json.dumps(response) + "\n").encode())
+
+
+main()
@@ -1,13 +1,17 @@
 package python
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"reflect"
+	"strings"
+	"sync"
 
 	"cuttlefish/database"
 	"cuttlefish/tools"
+	"cuttlefish/tools/process"
+	"cuttlefish/tools/sandbox"
 )
 
 type Tool struct {
@@ -18,49 +22,111 @@ func (t *Tool) Name() string {
 }
 
 func (t *Tool) Description() string {
-	return "run python3 scripts"
+	return "run python3 code in a persistent interpreter, where variables, imports and functions are kept between runs; the value of the last expression is shown like in a notebook"
 }
 
-func (t *Tool) ArgumentDescriptions() map[string]string {
-	return map[string]string{
-		"code": "python code to run; keep in mind that this is a json string, so you'll need to escape newlines and relevant special characters",
-	}
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"code": {Type: "string", Description: "python code to run; keep in mind that this is a json string, so you'll need to escape newlines and relevant special characters"},
+	}, "code")
 }
 
 func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
+	// The kernel is started by the first cell, as it depends on the conversation's sandbox settings.
 	return &ToolInstance{
+		runtime:               runtime,
 		pythonInterpreterPath: settings.Python.InterpreterPath,
 	}, nil
 }
 
+// ToolInstance runs all code in the same kernel, which lives as long as the instance, and gets restarted if the interpreter dies,
+// or if the conversation's sandbox settings change.
 type ToolInstance struct {
+	runtime               tools.AppRuntime
 	pythonInterpreterPath string
+
+	m      sync.Mutex
+	kernel *kernel
+	// The sandbox settings the kernel was started with.
+	sandbox database.SandboxSettings
 }
 
 func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
 	code, ok := args["code"].(string)
 	if !ok {
-		return nil, fmt.Errorf("command is not a string")
+		return nil, fmt.Errorf("code is not a string")
 	}
-	cmd := exec.CommandContext(ctx, t.pythonInterpreterPath, "-c", code)
-	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	err := cmd.Run()
-	var result string
-	if err == context.Canceled {
+
+	sandboxSettings, err := t.runtime.Sandbox()
+	if err != nil {
 		return nil, err
+	}
+
+	t.m.Lock()
+	defer t.m.Unlock()
+	restarted := ""
+	if t.kernel != nil && !reflect.DeepEqual(sandboxSettings, t.sandbox) {
+		t.kernel.close()
+		t.kernel = nil
+		restarted = "the sandbox settings changed"
+	}
+	if t.kernel == nil || t.kernel.exited {
+		if t.kernel != nil {
+			t.kernel.close()
+			restarted = "the previous one had exited"
+		}
+		k, err := startKernel(t.pythonInterpreterPath, sandboxSettings, t.runtime)
+		if err != nil {
+			return nil, err
+		}
+		t.kernel = k
+		t.sandbox = sandboxSettings
+	}
+
+	runCtx, cancel := sandbox.WithTimeout(ctx, t.sandbox)
+	defer cancel()
+	res, err := t.kernel.run(runCtx, code, t.runtime.OutputWriter(ctx))
+	if sandbox.TimedOut(ctx, err) {
+		return nil, fmt.Errorf("the code didn't finish within the sandbox's time limit of %s, so the interpreter was stopped, and its state is lost", sandbox.Timeout(t.sandbox))
+	} else if errors.Is(err, process.ErrExited) {
+		return &tools.RunResult{
+			Result: "the python interpreter exited while running the code, it will be restarted with a fresh state for the next code",
+			Output: res.Output,
+		}, nil
 	} else if err != nil {
-		result = err.Error()
+		return nil, err
+	}
+
+	var result string
+	if res.Error != nil {
+		result = "executing `" + code + "` raised " + *res.Error
 	} else {
 		result = "successfully executed `" + code + "`"
 	}
+	if restarted != "" {
+		result += ", in a new interpreter, as " + restarted + ", so the previous state is lost"
+	}
+	output := res.Output
+	if res.Value != nil {
+		if output != "" && !strings.HasSuffix(output, "\n") {
+			output += "\n"
+		}
+		output += *res.Value
+	}
 	return &tools.RunResult{
 		Result: result,
-		Output: buf.String(),
+		Output: output,
 	}, nil
 }
 
 func (t *ToolInstance) Shutdown() error {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if t.kernel == nil {
+		return nil
+	}
+	if err := t.kernel.close(); err != nil {
+		return fmt.Errorf("couldn't stop python interpreter: %w", err)
+	}
 	return nil
 }
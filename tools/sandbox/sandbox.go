@@ -0,0 +1,247 @@
+// Package sandbox runs the long-lived processes of the terminal and python tools in a bubblewrap sandbox,
+// where they only see the system directories and the mounted paths, and only reach the network if it's enabled.
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+// systemPaths are mounted read-only, so that programs and their libraries can be found.
+// On merged /usr systems, /bin, /lib and the like are symlinks into /usr, and are recreated as such.
+var systemPaths = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc", "/opt"}
+
+type mount struct {
+	path     string
+	readOnly bool
+}
+
+// Command returns cmd wrapped in bubblewrap if the sandbox is enabled, or cmd itself if it isn't.
+// The command is looked up in the sandbox, and runs in cmd.Dir there, or in the conversation's workspace if that's empty.
+// extraReadOnlyMounts are mounted along with the configured ones, i.e. for files the tool hands to the process.
+// The sandbox doesn't inherit the app's environment, which has i.e. API keys, only a few harmless variables,
+// along with cmd.Env, which should only have the tool's own variables.
+// The wrapped command has to be set up, i.e. with process.SetProcessGroup, after it's wrapped.
+func Command(cmd *exec.Cmd, settings database.SandboxSettings, runtime tools.AppRuntime, extraReadOnlyMounts ...string) (*exec.Cmd, error) {
+	if !settings.Enabled {
+		return cmd, nil
+	}
+	if goruntime.GOOS != "linux" {
+		return nil, errors.New("the sandbox is only supported on Linux")
+	}
+	bwrap, err := exec.LookPath("bwrap")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find bubblewrap, which the sandbox needs, i.e. `apt install bubblewrap` installs it: %w", err)
+	}
+	workspace, err := runtime.Workspace()
+	if err != nil {
+		return nil, err
+	}
+	dir := cmd.Dir
+	if dir == "" {
+		dir = workspace
+	}
+
+	mounts := []mount{{path: workspace}}
+	for _, path := range settings.ReadOnlyMounts {
+		mounts = append(mounts, mount{path: path, readOnly: true})
+	}
+	for _, path := range extraReadOnlyMounts {
+		mounts = append(mounts, mount{path: path, readOnly: true})
+	}
+	for _, path := range settings.ReadWriteMounts {
+		mounts = append(mounts, mount{path: path})
+	}
+	args, err := bwrapArgs(settings, workspace, mounts, dir, cmd.Env)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, "--")
+	// cmd.Path is resolved on the host, the command's name is resolved in the sandbox instead.
+	name := bwrap
+	memoryScope := false
+	if settings.MemoryMB > 0 {
+		if systemdRun := userScopes(); systemdRun != "" {
+			// The scope is created before bubblewrap starts, so that the whole sandbox is in it.
+			args = append(append(scopeArgs(settings.MemoryMB), bwrap), args...)
+			name = systemdRun
+			memoryScope = true
+		}
+	}
+	args = append(args, limitedCommand(settings, memoryScope, cmd.Args)...)
+
+	// bubblewrap and systemd-run get the app's environment, i.e. to reach the user's service manager, but don't pass it on.
+	return exec.Command(name, args...), nil
+}
+
+// Variables passed from the app's environment into the sandbox, which don't hold any secrets.
+var passedEnv = []string{"PATH", "LANG", "LC_ALL", "TERM"}
+
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+func bwrapArgs(settings database.SandboxSettings, workspace string, mounts []mount, dir string, env []string) ([]string, error) {
+	// The sandbox dies along with the app, and everything in it with it, as it has its own PID namespace.
+	args := []string{"--die-with-parent", "--unshare-all", "--clearenv"}
+	for _, name := range passedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			args = append(args, "--setenv", name, value)
+		} else if name == "PATH" {
+			args = append(args, "--setenv", name, defaultPath)
+		}
+	}
+	for _, variable := range env {
+		if name, value, ok := strings.Cut(variable, "="); ok {
+			args = append(args, "--setenv", name, value)
+		}
+	}
+	if settings.Network {
+		args = append(args, "--share-net")
+	}
+	for _, path := range systemPaths {
+		if target, err := os.Readlink(path); err == nil {
+			args = append(args, "--symlink", target, path)
+		} else {
+			args = append(args, "--ro-bind-try", path, path)
+		}
+	}
+	if settings.Network {
+		// /etc/resolv.conf is often a symlink to i.e. /run/systemd/resolve, which isn't mounted otherwise.
+		if resolvConf, err := filepath.EvalSymlinks("/etc/resolv.conf"); err == nil && !strings.HasPrefix(resolvConf, "/etc/") {
+			args = append(args, "--ro-bind", resolvConf, resolvConf)
+		}
+	}
+	args = append(args, "--proc", "/proc", "--dev", "/dev", "--tmpfs", "/tmp")
+
+	for i := range mounts {
+		path, err := hostPath(mounts[i].path)
+		if err != nil {
+			return nil, err
+		}
+		mounts[i].path = path
+	}
+	// Parents are mounted before the paths within them, so that i.e. a read-only directory within a read-write one stays read-only.
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return len(mounts[i].path) < len(mounts[j].path)
+	})
+	home, _ := homedir.Dir()
+	homeMounted := false
+	for _, m := range mounts {
+		if m.readOnly {
+			args = append(args, "--ro-bind", m.path, m.path)
+		} else {
+			args = append(args, "--bind", m.path, m.path)
+		}
+		homeMounted = homeMounted || m.path == home
+	}
+	if homeMounted {
+		args = append(args, "--setenv", "HOME", home)
+	} else {
+		// The workspace stands in for the home directory, which programs expect to be able to write to.
+		args = append(args, "--setenv", "HOME", workspace)
+	}
+	return append(args, "--chdir", dir), nil
+}
+
+// hostPath returns the absolute path of the path to mount, which has to exist.
+func hostPath(path string) (string, error) {
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't expand `%s`: %w", path, err)
+	}
+	expanded, err = filepath.Abs(expanded)
+	if err != nil {
+		return "", fmt.Errorf("couldn't get absolute path of `%s`: %w", path, err)
+	}
+	if _, err := os.Stat(expanded); err != nil {
+		return "", fmt.Errorf("couldn't mount `%s` in the sandbox: %w", path, err)
+	}
+	return expanded, nil
+}
+
+var (
+	userScopesOnce sync.Once
+	systemdRun     string
+)
+
+// userScopes returns the path of systemd-run if it can run commands in a scope of the user's service manager,
+// whose cgroup limits the memory they use, or "" if it can't, i.e. without systemd or without a user session.
+func userScopes() string {
+	userScopesOnce.Do(func() {
+		path, err := exec.LookPath("systemd-run")
+		if err != nil {
+			return
+		}
+		// The memory controller has to be delegated to the user's service manager, otherwise the limit is silently ignored.
+		controllers, err := os.ReadFile(fmt.Sprintf("/sys/fs/cgroup/user.slice/user-%d.slice/user@%d.service/cgroup.controllers", os.Getuid(), os.Getuid()))
+		if err != nil || !strings.Contains(" "+strings.TrimSpace(string(controllers))+" ", " memory ") {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := exec.CommandContext(ctx, path, append(scopeArgs(64), "true")...).Run(); err != nil {
+			log.Printf("couldn't run a command in a systemd scope, limiting the address space of sandboxed processes instead of their memory: %s", err)
+			return
+		}
+		systemdRun = path
+	})
+	return systemdRun
+}
+
+// scopeArgs are the arguments of systemd-run for a scope limiting the memory of everything in it, without swapping it out instead.
+func scopeArgs(memoryMB int) []string {
+	return []string{"--user", "--scope", "--quiet", "-p", fmt.Sprintf("MemoryMax=%dM", memoryMB), "-p", "MemorySwapMax=0", "--"}
+}
+
+// limitedCommand wraps the command in a shell setting the sandbox's resource limits, which everything the command starts inherits.
+// Without a scope limiting the memory, the memory limit is a limit of each process's address space,
+// which is larger than the memory it uses, i.e. by whatever it maps without touching it.
+func limitedCommand(settings database.SandboxSettings, memoryScope bool, args []string) []string {
+	var limits []string
+	if settings.CPUSeconds > 0 {
+		limits = append(limits, fmt.Sprintf("ulimit -t %d", settings.CPUSeconds))
+	}
+	if settings.MemoryMB > 0 && !memoryScope {
+		limits = append(limits, fmt.Sprintf("ulimit -v %d", settings.MemoryMB*1024))
+	}
+	if len(limits) == 0 {
+		return args
+	}
+	script := strings.Join(limits, " && ") + ` && exec "$@"`
+	return append([]string{"/bin/sh", "-c", script, "sh"}, args...)
+}
+
+// Timeout returns the sandbox's time limit for a single command or cell, or 0 if it has none.
+func Timeout(settings database.SandboxSettings) time.Duration {
+	if !settings.Enabled || settings.TimeoutSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(settings.TimeoutSeconds) * time.Second
+}
+
+// WithTimeout limits ctx to the sandbox's time limit, if it has one.
+func WithTimeout(ctx context.Context, settings database.SandboxSettings) (context.Context, context.CancelFunc) {
+	if timeout := Timeout(settings); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// TimedOut reports whether err is due to the sandbox's time limit, rather than ctx, the tool call's own context, being done.
+func TimedOut(ctx context.Context, err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil
+}
@@ -0,0 +1,68 @@
+package sandbox
+
+import (
+	"strings"
+	"testing"
+
+	"cuttlefish/database"
+)
+
+// setenvArgs returns the variables the args set in the sandbox.
+func setenvArgs(args []string) map[string]string {
+	env := map[string]string{}
+	for i := 0; i+2 < len(args); i++ {
+		if args[i] == "--setenv" {
+			env[args[i+1]] = args[i+2]
+			i += 2
+		}
+	}
+	return env
+}
+
+func TestBwrapArgsEnvironment(t *testing.T) {
+	t.Setenv("CUTTLEFISH_API_TOKEN", "secret-token")
+	t.Setenv("OPENAI_API_KEY", "secret-key")
+	t.Setenv("PATH", "/usr/bin:/bin")
+	t.Setenv("LANG", "C.UTF-8")
+	workspace := t.TempDir()
+
+	args, err := bwrapArgs(database.SandboxSettings{Enabled: true, Network: true}, workspace, []mount{{path: workspace}}, workspace, []string{"TOOL_VARIABLE=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clearenv := -1
+	for i, arg := range args {
+		if arg == "--clearenv" {
+			clearenv = i
+		}
+		if strings.Contains(arg, "secret") {
+			t.Errorf("args pass a secret from the app's environment: %q", arg)
+		}
+	}
+	if clearenv < 0 {
+		t.Fatalf("args don't clear the environment: %q", args)
+	}
+	for i, arg := range args[:clearenv] {
+		if arg == "--setenv" {
+			t.Errorf("variable %s is set before the environment is cleared", args[i+1])
+		}
+	}
+
+	env := setenvArgs(args)
+	want := map[string]string{
+		"PATH":          "/usr/bin:/bin",
+		"LANG":          "C.UTF-8",
+		"HOME":          workspace,
+		"TOOL_VARIABLE": "1",
+	}
+	for name, value := range want {
+		if env[name] != value {
+			t.Errorf("%s is %q in the sandbox, want %q", name, env[name], value)
+		}
+	}
+	for name := range env {
+		if _, ok := want[name]; !ok && name != "TERM" && name != "LC_ALL" {
+			t.Errorf("unexpected variable %s in the sandbox", name)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Schema is a JSON Schema, describing a tool's arguments, or a single one of them.
+// Only the keywords below are validated, any others, like anyOf or format, are kept in Other, and passed on to the model as they are.
+type Schema struct {
+	// One of string, number, integer, boolean, object, array or null.
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []interface{}      `json:"enum,omitempty"`
+	Default     interface{}        `json:"default,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+
+	Other map[string]interface{} `json:"-"`
+}
+
+// ObjectSchema is a shorthand for the usual arguments schema, an object with the given properties, of which the required ones have to be set.
+func ObjectSchema(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{
+		Type:       "object",
+		Properties: properties,
+		Required:   required,
+	}
+}
+
+// Map returns the schema as decoded JSON, which is what the model providers' function definitions take.
+func (s *Schema) Map() map[string]interface{} {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return out
+}
+
+// schemaFields has the same fields as Schema, without its methods, so it can be encoded and decoded as usual.
+type schemaFields Schema
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{}
+	for key, value := range s.Other {
+		out[key] = value
+	}
+	data, err := json.Marshal((*schemaFields)(s))
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for key, value := range fields {
+		out[key] = value
+	}
+	// Some providers reject object schemas without properties, which is what i.e. tools without arguments would have.
+	if s.Type == "object" && s.Properties == nil {
+		if _, ok := out["properties"]; !ok {
+			out["properties"] = map[string]interface{}{}
+		}
+	}
+	return json.Marshal(out)
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	// true and false are valid schemas as well, accepting anything or nothing, and the former is the closest we can get.
+	if trimmed := bytes.TrimSpace(data); bytes.Equal(trimmed, []byte("true")) || bytes.Equal(trimmed, []byte("false")) {
+		*s = Schema{}
+		return nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	known := map[string]json.RawMessage{}
+	for _, key := range []string{"type", "description", "enum", "default", "properties", "required", "items"} {
+		value, ok := raw[key]
+		if !ok {
+			continue
+		}
+		// I.e. ["string", "null"] isn't supported, so such a type is passed on, but not validated.
+		var name string
+		if key == "type" && json.Unmarshal(value, &name) != nil {
+			continue
+		}
+		known[key] = value
+		delete(raw, key)
+	}
+	data, err := json.Marshal(known)
+	if err != nil {
+		return err
+	}
+	var fields schemaFields
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	*s = Schema(fields)
+	if len(raw) > 0 {
+		s.Other = map[string]interface{}{}
+		for key, value := range raw {
+			var decoded interface{}
+			if err := json.Unmarshal(value, &decoded); err != nil {
+				return err
+			}
+			s.Other[key] = decoded
+		}
+	}
+	return nil
+}
+
+// ValidateArguments checks the arguments against the tool's schema, and returns them with the defaults of missing ones filled in.
+// The error lists everything that's wrong, so the model can fix it all at once.
+func ValidateArguments(schema *Schema, args map[string]interface{}) (map[string]interface{}, error) {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	if schema == nil {
+		return args, nil
+	}
+	var problems []string
+	out := schema.validate("", args, &problems)
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("invalid arguments: %s", strings.Join(problems, "; "))
+	}
+	return out.(map[string]interface{}), nil
+}
+
+// validate appends what's wrong with the value to problems, and returns it with defaults filled in.
+func (s *Schema) validate(path string, value interface{}, problems *[]string) interface{} {
+	name := "arguments"
+	if path != "" {
+		name = "`" + path + "`"
+	}
+	if !s.hasType(value) {
+		*problems = append(*problems, fmt.Sprintf("%s has to be of type %s, not %s", name, s.Type, typeOf(value)))
+		return value
+	}
+	if len(s.Enum) > 0 && !s.inEnum(value) {
+		options := make([]string, len(s.Enum))
+		for i, option := range s.Enum {
+			data, _ := json.Marshal(option)
+			options[i] = string(data)
+		}
+		*problems = append(*problems, fmt.Sprintf("%s has to be one of %s", name, strings.Join(options, ", ")))
+		return value
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		if s.Properties == nil && len(s.Required) == 0 {
+			return value
+		}
+		out := make(map[string]interface{}, len(value))
+		for key, propertyValue := range value {
+			out[key] = propertyValue
+		}
+		for _, key := range sortedKeys(s.Properties) {
+			property := s.Properties[key]
+			propertyValue, ok := value[key]
+			// Models tend to pass null for optional arguments they don't want to set.
+			if ok && propertyValue == nil && property.Type != "null" {
+				ok = false
+				delete(out, key)
+			}
+			if !ok {
+				if property.Default != nil {
+					out[key] = decoded(property.Default)
+				}
+				continue
+			}
+			out[key] = property.validate(join(path, key), propertyValue, problems)
+		}
+		for _, key := range s.Required {
+			if _, ok := out[key]; !ok {
+				*problems = append(*problems, fmt.Sprintf("`%s` is required", join(path, key)))
+			}
+		}
+		return out
+	case []interface{}:
+		if s.Items == nil {
+			return value
+		}
+		out := make([]interface{}, len(value))
+		for i, item := range value {
+			out[i] = s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+		}
+		return out
+	}
+	return value
+}
+
+func (s *Schema) hasType(value interface{}) bool {
+	switch s.Type {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	// Comparing the JSON makes i.e. an int option equal to the float64 the arguments were decoded to.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	for _, option := range s.Enum {
+		optionData, err := json.Marshal(option)
+		if err == nil && bytes.Equal(data, optionData) {
+			return true
+		}
+	}
+	return false
+}
+
+// decoded returns the value as if it was decoded from JSON, like the arguments are, so i.e. an int default becomes a float64.
+func decoded(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return value
+	}
+	return out
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case float32:
+		return float64(value), true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if _, ok := toFloat(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
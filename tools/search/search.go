@@ -27,10 +27,10 @@ func (t *Tool) Description() string {
 	return "search using google; when the user asks about facts, or you need facts to answer, double-check them here instead of relying on your memory; you can also use it to find web pages relevant to a topic"
 }
 
-func (t *Tool) ArgumentDescriptions() map[string]string {
-	return map[string]string{
-		"query": "phrase to search for",
-	}
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"query": {Type: "string", Description: "phrase to search for"},
+	}, "query")
 }
 
 func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
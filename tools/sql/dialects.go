@@ -0,0 +1,185 @@
+package sql
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	"cuttlefish/tools"
+)
+
+// dialect holds the driver-specific queries for inspecting a database's schema.
+type dialect struct {
+	listTables    string
+	describeTable func(table string) (string, []interface{})
+}
+
+var dialects = map[string]dialect{
+	"sqlite": {
+		listTables: `SELECT 'main' AS "schema", name, type FROM sqlite_master WHERE type IN ('table', 'view') AND name NOT LIKE 'sqlite_%' ORDER BY name`,
+		describeTable: func(table string) (string, []interface{}) {
+			// SQLite only has the one schema anyway.
+			_, table = splitSchema(table)
+			return `SELECT name, type, "notnull" AS not_null, dflt_value AS "default", pk AS primary_key FROM pragma_table_info(?)`, []interface{}{table}
+		},
+	},
+	"postgres": {
+		listTables: `SELECT table_schema AS "schema", table_name AS name, table_type AS type FROM information_schema.tables WHERE table_schema NOT IN ('pg_catalog', 'information_schema') ORDER BY 1, 2`,
+		describeTable: func(table string) (string, []interface{}) {
+			schema, table := splitSchema(table)
+			query := `SELECT column_name AS name, data_type AS type, is_nullable AS nullable, column_default AS "default" FROM information_schema.columns WHERE table_name = $1 AND `
+			if schema == "" {
+				return query + `table_schema = ANY(current_schemas(false)) ORDER BY ordinal_position`, []interface{}{table}
+			}
+			return query + `table_schema = $2 ORDER BY ordinal_position`, []interface{}{table, schema}
+		},
+	},
+	"mysql": {
+		listTables: "SELECT table_schema AS `schema`, table_name AS name, table_type AS type FROM information_schema.tables WHERE table_schema NOT IN ('mysql', 'information_schema', 'performance_schema', 'sys') ORDER BY 1, 2",
+		describeTable: func(table string) (string, []interface{}) {
+			schema, table := splitSchema(table)
+			query := "SELECT column_name AS name, column_type AS type, is_nullable AS nullable, column_default AS `default`, column_key AS `key` FROM information_schema.columns WHERE table_name = ? AND "
+			if schema == "" {
+				return query + "table_schema = DATABASE() ORDER BY ordinal_position", []interface{}{table}
+			}
+			return query + "table_schema = ? ORDER BY ordinal_position", []interface{}{table, schema}
+		},
+	},
+}
+
+func splitSchema(table string) (schema string, name string) {
+	if i := strings.LastIndex(table, "."); i != -1 {
+		return table[:i], table[i+1:]
+	}
+	return "", table
+}
+
+// isReadOnlyStatement is a best-effort check based on the first keyword. Anything it lets through still runs read-only.
+func isReadOnlyStatement(query string) bool {
+	switch statementKeyword(query) {
+	case "select", "with", "show", "describe", "desc", "explain", "values", "table":
+		return true
+	}
+	return false
+}
+
+// statementRisk hints at how much damage a write statement could do, for its approval request.
+func statementRisk(query string) (tools.Risk, string) {
+	switch keyword := statementKeyword(query); keyword {
+	case "drop", "truncate", "delete":
+		return tools.RiskHigh, keyword + " deletes data"
+	case "alter":
+		return tools.RiskHigh, "alter changes the schema"
+	}
+	return tools.RiskMedium, "writes to the database"
+}
+
+// statementKeyword returns the first keyword of the statement, in lower case.
+func statementKeyword(query string) string {
+	query = strings.TrimLeft(stripLeadingComments(query), "( \t\r\n")
+	end := strings.IndexFunc(query, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+	if end == -1 {
+		end = len(query)
+	}
+	return strings.ToLower(query[:end])
+}
+
+func stripLeadingComments(query string) string {
+	for {
+		query = strings.TrimSpace(query)
+		switch {
+		case strings.HasPrefix(query, "--"):
+			end := strings.Index(query, "\n")
+			if end == -1 {
+				return ""
+			}
+			query = query[end+1:]
+		case strings.HasPrefix(query, "/*"):
+			end := strings.Index(query, "*/")
+			if end == -1 {
+				return ""
+			}
+			query = query[end+2:]
+		default:
+			return query
+		}
+	}
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*gosql.Rows, error)
+}
+
+// queryTable runs the query and formats the result as a markdown table, with at most maxRows rows, or all of them if it's 0.
+func queryTable(ctx context.Context, q queryer, maxRows int, query string, args ...interface{}) (string, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	sb.WriteString("|")
+	for _, column := range columns {
+		sb.WriteString(" " + markdownCell(column) + " |")
+	}
+	sb.WriteString("\n|")
+	for range columns {
+		sb.WriteString(" --- |")
+	}
+	sb.WriteString("\n")
+
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	count := 0
+	truncated := false
+	for rows.Next() {
+		if maxRows > 0 && count == maxRows {
+			truncated = true
+			break
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return "", err
+		}
+		sb.WriteString("|")
+		for _, value := range values {
+			sb.WriteString(" " + markdownCell(formatValue(value)) + " |")
+		}
+		sb.WriteString("\n")
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if truncated {
+		fmt.Fprintf(&sb, "\nOnly the first %d rows are shown, use LIMIT, filters or aggregates to narrow the result down.\n", maxRows)
+	}
+	return sb.String(), nil
+}
+
+func formatValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		return string(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\r\n", " ")
+	return strings.ReplaceAll(s, "\n", " ")
+}
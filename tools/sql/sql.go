@@ -0,0 +1,225 @@
+package sql
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+const defaultMaxRows = 100
+
+type Tool struct {
+}
+
+func (t *Tool) Name() string {
+	return "SQL"
+}
+
+func (t *Tool) Description() string {
+	return "inspect and query the sqlite, postgres and mysql databases configured by the user; queries are read-only, unless the user approves a write"
+}
+
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"database": {Type: "string", Description: "name of the database to use, not needed for list_databases", Default: ""},
+		"action":   {Type: "string", Enum: []interface{}{"list_databases", "list_tables", "describe_table", "query"}},
+		"input":    {Type: "string", Description: "the table name for describe_table, optionally prefixed with the schema, and the sql statement for query", Default: ""},
+	}, "action")
+}
+
+func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
+	maxRows := settings.SQL.MaxRows
+	if maxRows <= 0 {
+		maxRows = defaultMaxRows
+	}
+	return &ToolInstance{
+		runtime:     runtime,
+		databases:   settings.SQL.Databases,
+		maxRows:     maxRows,
+		connections: map[string]*gosql.DB{},
+	}, nil
+}
+
+type ToolInstance struct {
+	runtime   tools.AppRuntime
+	databases []database.SQLDatabase
+	maxRows   int
+
+	// Connections are opened on first use and kept for as long as the instance.
+	connections map[string]*gosql.DB
+}
+
+func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
+	name, ok := args["database"].(string)
+	if !ok {
+		return nil, fmt.Errorf("database is not a string")
+	}
+	action, ok := args["action"].(string)
+	if !ok {
+		return nil, fmt.Errorf("action is not a string")
+	}
+	input, ok := args["input"].(string)
+	if !ok {
+		return nil, fmt.Errorf("input is not a string")
+	}
+
+	if action == "list_databases" {
+		return t.listDatabases(), nil
+	}
+
+	db, conn, err := t.connection(name)
+	if err != nil {
+		return nil, err
+	}
+	d := dialects[db.Driver]
+
+	switch action {
+	case "list_tables":
+		table, err := queryTable(ctx, conn, t.maxRows, d.listTables)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't list tables: %w", err)
+		}
+		return &tools.RunResult{
+			Result: "tables in `" + name + "`",
+			Output: table,
+		}, nil
+	case "describe_table":
+		query, args := d.describeTable(input)
+		table, err := queryTable(ctx, conn, 0, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't describe table: %w", err)
+		}
+		return &tools.RunResult{
+			Result: "columns of `" + input + "`",
+			Output: table,
+		}, nil
+	case "query":
+		// A second statement would run without approval, and could i.e. commit the read-only transaction first.
+		query, err := tools.SingleStatement(input)
+		if err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(query) == "" {
+			return nil, fmt.Errorf("input has to be the sql statement to run")
+		}
+		if isReadOnlyStatement(query) {
+			table, err := t.read(ctx, db, conn, query)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't run query: %w", err)
+			}
+			return &tools.RunResult{
+				Result: "successfully ran `" + query + "`",
+				Output: table,
+			}, nil
+		}
+		risk, riskReason := statementRisk(query)
+		approval, err := t.runtime.WaitForApproval(ctx, tools.ApprovalRequest{
+			Message:     fmt.Sprintf("run a write statement on database `%s`", name),
+			Risk:        risk,
+			RiskReason:  riskReason,
+			Preview:     query,
+			PreviewType: tools.PreviewTypeSQL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("user did not approve: %w", err)
+		}
+		if err := approval.Rejected(); err != nil {
+			return nil, err
+		}
+		if approval.Args != nil {
+			return t.Run(ctx, approval.Args)
+		}
+		res, err := conn.ExecContext(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't run statement: %w", err)
+		}
+		result := "successfully ran `" + query + "`"
+		if rowsAffected, err := res.RowsAffected(); err == nil {
+			result += fmt.Sprintf(", %d rows affected", rowsAffected)
+		}
+		return &tools.RunResult{
+			Result: result,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown action `%s`, it has to be one of list_databases, list_tables, describe_table or query", action)
+	}
+}
+
+func (t *ToolInstance) listDatabases() *tools.RunResult {
+	var sb strings.Builder
+	sb.WriteString("| name | driver |\n| --- | --- |\n")
+	for _, db := range t.databases {
+		fmt.Fprintf(&sb, "| %s | %s |\n", db.Name, db.Driver)
+	}
+	return &tools.RunResult{
+		Result: fmt.Sprintf("%d databases configured", len(t.databases)),
+		Output: sb.String(),
+	}
+}
+
+func (t *ToolInstance) connection(name string) (database.SQLDatabase, *gosql.DB, error) {
+	var names []string
+	for _, db := range t.databases {
+		if db.Name != name {
+			names = append(names, "`"+db.Name+"`")
+			continue
+		}
+		if _, ok := dialects[db.Driver]; !ok {
+			return database.SQLDatabase{}, nil, fmt.Errorf("database `%s` has the unsupported driver `%s`", name, db.Driver)
+		}
+		if conn, ok := t.connections[name]; ok {
+			return db, conn, nil
+		}
+		conn, err := gosql.Open(db.Driver, db.DSN)
+		if err != nil {
+			return database.SQLDatabase{}, nil, fmt.Errorf("couldn't open database `%s`: %w", name, err)
+		}
+		t.connections[name] = conn
+		return db, conn, nil
+	}
+	if len(names) == 0 {
+		return database.SQLDatabase{}, nil, fmt.Errorf("there are no databases configured, the user can add them in the settings")
+	}
+	return database.SQLDatabase{}, nil, fmt.Errorf("there's no database `%s`, available ones are: %s", name, strings.Join(names, ", "))
+}
+
+// read runs the query so that the database itself rejects any writes, in case they're hidden i.e. in a CTE.
+func (t *ToolInstance) read(ctx context.Context, db database.SQLDatabase, conn *gosql.DB, query string) (string, error) {
+	if db.Driver == "sqlite" {
+		// SQLite ignores read-only transactions, so the connection is switched to read-only instead.
+		c, err := conn.Conn(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer c.Close()
+		if _, err := c.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+			return "", err
+		}
+		defer c.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+		return queryTable(ctx, c, t.maxRows, query)
+	}
+
+	tx, err := conn.BeginTx(ctx, &gosql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	return queryTable(ctx, tx, t.maxRows, query)
+}
+
+func (t *ToolInstance) Shutdown() error {
+	for name, conn := range t.connections {
+		if err := conn.Close(); err != nil {
+			return fmt.Errorf("couldn't close database `%s`: %w", name, err)
+		}
+	}
+	return nil
+}
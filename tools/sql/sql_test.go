@@ -0,0 +1,166 @@
+package sql
+
+import (
+	"context"
+	gosql "database/sql"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+)
+
+// testRuntime answers approval requests with approval, and records them.
+type testRuntime struct {
+	approval tools.Approval
+	requests []tools.ApprovalRequest
+}
+
+func (r *testRuntime) WaitForApproval(ctx context.Context, request tools.ApprovalRequest) (tools.Approval, error) {
+	r.requests = append(r.requests, request)
+	return r.approval, nil
+}
+
+func (r *testRuntime) RunSubConversation(ctx context.Context, subConversation tools.SubConversation) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (r *testRuntime) Workspace() (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (r *testRuntime) ConversationID() int {
+	return 1
+}
+
+func (r *testRuntime) OutputWriter(ctx context.Context) io.Writer {
+	return io.Discard
+}
+
+func (r *testRuntime) Sandbox() (database.SandboxSettings, error) {
+	return database.SandboxSettings{}, nil
+}
+
+// newTestInstance returns an instance with a single sqlite database `test`, which has a users table with one row.
+func newTestInstance(t *testing.T, runtime *testRuntime) (*ToolInstance, string) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	db, err := gosql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("CREATE TABLE users (name TEXT); INSERT INTO users VALUES ('alice')"); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := database.Settings{}
+	settings.SQL.Databases = []database.SQLDatabase{{Name: "test", Driver: "sqlite", DSN: dsn}}
+	instance, err := (&Tool{}).Instantiate(context.Background(), settings, runtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		instance.Shutdown()
+	})
+	return instance.(*ToolInstance), dsn
+}
+
+func countUsers(t *testing.T, dsn string) int {
+	db, err := gosql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow("SELECT count(*) FROM users").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func query(instance *ToolInstance, input string) (*tools.RunResult, error) {
+	return instance.Run(context.Background(), map[string]interface{}{
+		"database": "test",
+		"action":   "query",
+		"input":    input,
+	})
+}
+
+func TestReadOnlyQuery(t *testing.T) {
+	runtime := &testRuntime{}
+	instance, _ := newTestInstance(t, runtime)
+
+	for _, input := range []string{"SELECT name FROM users", "SELECT name FROM users;\n"} {
+		result, err := query(instance, input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		if !strings.Contains(result.Output, "alice") {
+			t.Errorf("%q: output %q doesn't have the row", input, result.Output)
+		}
+	}
+	if len(runtime.requests) != 0 {
+		t.Errorf("read-only queries asked for approval %d times", len(runtime.requests))
+	}
+}
+
+func TestHiddenWritesAreRejected(t *testing.T) {
+	runtime := &testRuntime{approval: tools.Approval{Approved: true}}
+	instance, dsn := newTestInstance(t, runtime)
+
+	for _, input := range []string{
+		"SELECT 1; DROP TABLE users",
+		"SELECT 1; COMMIT; DROP TABLE users",
+		"SELECT 1;\nDELETE FROM users;",
+		// Only the first keyword is checked, so this takes the read-only path, where the database rejects the write.
+		"WITH x AS (SELECT 1) INSERT INTO users SELECT 'eve' FROM x",
+	} {
+		if _, err := query(instance, input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+	if len(runtime.requests) != 0 {
+		t.Errorf("hidden writes asked for approval %d times, instead of being rejected", len(runtime.requests))
+	}
+	if count := countUsers(t, dsn); count != 1 {
+		t.Errorf("users has %d rows after the rejected writes, want 1", count)
+	}
+}
+
+func TestWriteRequiresApproval(t *testing.T) {
+	runtime := &testRuntime{approval: tools.Approval{Approved: false, Reason: "not now"}}
+	instance, dsn := newTestInstance(t, runtime)
+
+	_, err := query(instance, "DELETE FROM users")
+	var rejected *tools.RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("rejected write returned %v, want a RejectedError", err)
+	}
+	if len(runtime.requests) != 1 {
+		t.Fatalf("write asked for approval %d times, want once", len(runtime.requests))
+	}
+	if request := runtime.requests[0]; request.Risk != tools.RiskHigh || request.Preview != "DELETE FROM users" {
+		t.Errorf("approval request has risk %q and preview %q", request.Risk, request.Preview)
+	}
+	if count := countUsers(t, dsn); count != 1 {
+		t.Errorf("users has %d rows after the rejected delete, want 1", count)
+	}
+
+	runtime.approval = tools.Approval{Approved: true}
+	result, err := query(instance, "INSERT INTO users VALUES ('bob');")
+	if err != nil {
+		t.Fatalf("approved write: unexpected error: %s", err)
+	}
+	if !strings.Contains(result.Result, "1 rows affected") {
+		t.Errorf("approved write has result %q", result.Result)
+	}
+	if len(runtime.requests) != 2 {
+		t.Errorf("writes asked for approval %d times, want twice", len(runtime.requests))
+	}
+	if count := countUsers(t, dsn); count != 2 {
+		t.Errorf("users has %d rows after the approved insert, want 2", count)
+	}
+}
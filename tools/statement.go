@@ -0,0 +1,20 @@
+package tools
+
+import (
+	"errors"
+	"strings"
+)
+
+// SingleStatement returns the sql statement without its trailing semicolons, or an error if there's more than one.
+// Semicolons are only allowed at the end, not even within string literals or comments,
+// as telling those apart depends on each database's quoting rules, i.e. backslash escapes or dollar quotes,
+// and getting them wrong would let a second statement through.
+func SingleStatement(query string) (string, error) {
+	statement := strings.TrimRightFunc(query, func(r rune) bool {
+		return r == ';' || r == ' ' || r == '\t' || r == '\r' || r == '\n'
+	})
+	if strings.Contains(statement, ";") {
+		return "", errors.New("input has to be a single sql statement without semicolons within it, run multiple statements one at a time")
+	}
+	return statement, nil
+}
@@ -0,0 +1,38 @@
+package tools
+
+import "testing"
+
+func TestSingleStatement(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{query: "SELECT 1", want: "SELECT 1"},
+		{query: "SELECT 1;", want: "SELECT 1"},
+		{query: "SELECT 1 ;; \r\n", want: "SELECT 1"},
+		{query: "", want: ""},
+		{query: "SELECT 1; SELECT 2", wantErr: true},
+		{query: "SELECT 1; COMMIT; DROP TABLE users", wantErr: true},
+		{query: "SELECT 1;\nDROP TABLE users;", wantErr: true},
+		// Quoting differs between databases, so semicolons are rejected even where they might be within a literal.
+		{query: "SELECT ';'", wantErr: true},
+		{query: `SELECT E'\''; DROP TABLE users; -- '`, wantErr: true},
+		{query: `SELECT $$ ' $$; DROP TABLE users; -- '`, wantErr: true},
+		{query: "SELECT 1 /* ; */", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := SingleStatement(tt.query)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("SingleStatement(%q) = %q, want an error", tt.query, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SingleStatement(%q): unexpected error: %s", tt.query, err)
+		} else if got != tt.want {
+			t.Errorf("SingleStatement(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
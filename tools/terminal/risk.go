@@ -0,0 +1,204 @@
+package terminal
+
+import (
+	"path"
+	"strings"
+
+	"cuttlefish/tools"
+)
+
+// The risk of a command is a rough hint for the user, based on the programs it runs.
+// It's no safety mechanism, there are countless ways to do damage that aren't caught here.
+
+// Programs that can do lasting damage, with what they do.
+var riskyPrograms = map[string]string{
+	"rm":       "deletes files",
+	"rmdir":    "deletes directories",
+	"shred":    "deletes files",
+	"dd":       "writes raw data",
+	"mkfs":     "formats a filesystem",
+	"fdisk":    "changes partitions",
+	"chmod":    "changes permissions",
+	"chown":    "changes ownership",
+	"sudo":     "runs as root",
+	"su":       "runs as another user",
+	"kill":     "stops processes",
+	"killall":  "stops processes",
+	"pkill":    "stops processes",
+	"shutdown": "shuts down the computer",
+	"reboot":   "reboots the computer",
+	"crontab":  "changes scheduled jobs",
+}
+
+// Programs that only read, unless their output is redirected to a file.
+var readOnlyPrograms = map[string]bool{
+	"ls": true, "cat": true, "head": true, "tail": true, "less": true, "pwd": true, "echo": true, "grep": true,
+	"find": true, "wc": true, "which": true, "whoami": true, "date": true, "df": true, "du": true, "ps": true,
+	"uname": true, "file": true, "stat": true, "tree": true, "sort": true, "uniq": true, "cd": true,
+}
+
+// Programs that run another command, which is what the risk is based on, i.e. `xargs rm`, with their options taking an argument.
+var wrapperPrograms = map[string][]string{
+	"sudo":    {"-u", "-g", "-C", "-D", "-h", "-p", "-r", "-t", "-U", "--user", "--group"},
+	"env":     {"-u", "-C", "-S", "--unset", "--chdir", "--split-string"},
+	"xargs":   {"-I", "-n", "-P", "-L", "-d", "-E", "-s", "-a", "--max-args", "--max-procs", "--delimiter", "--arg-file"},
+	"nice":    {"-n", "--adjustment"},
+	"ionice":  {"-c", "-n", "--class", "--classdata"},
+	"timeout": {"-s", "-k", "--signal", "--kill-after"},
+	"stdbuf":  {"-i", "-o", "-e"},
+	"time":    {"-f", "-o", "--format", "--output"},
+	"nohup":   nil,
+	"exec":    {"-a"},
+	"command": nil,
+}
+
+// Options of find that run a command on the files found, or delete them.
+var findActions = map[string]string{
+	"-delete":  "deletes files",
+	"-exec":    "runs a command on the files found",
+	"-execdir": "runs a command on the files found",
+	"-ok":      "runs a command on the files found",
+	"-okdir":   "runs a command on the files found",
+}
+
+// Subcommands of git that only read.
+var readOnlyGitCommands = map[string]bool{
+	"status": true, "log": true, "diff": true, "show": true, "branch": true, "remote": true, "blame": true,
+}
+
+func commandRisk(command string) (tools.Risk, string) {
+	var reasons []string
+	addReason := func(reason string) {
+		for _, existing := range reasons {
+			if existing == reason {
+				return
+			}
+		}
+		reasons = append(reasons, reason)
+	}
+
+	readOnly := !strings.Contains(command, ">")
+	for _, words := range simpleCommands(command) {
+		program := path.Base(words[0])
+		for {
+			options, ok := wrapperPrograms[program]
+			if !ok {
+				break
+			}
+			if reason, ok := riskyPrograms[program]; ok {
+				addReason(reason)
+			}
+			wrapped := wrappedCommand(words, options)
+			if wrapped == nil {
+				// i.e. `env` on its own, which prints the environment.
+				break
+			}
+			words = wrapped
+			program = path.Base(words[0])
+		}
+		if reason, ok := riskyPrograms[program]; ok {
+			addReason(reason)
+		}
+		if program == "find" {
+			for i, word := range words {
+				reason, ok := findActions[word]
+				if !ok {
+					continue
+				}
+				addReason(reason)
+				if i+1 < len(words) {
+					if reason, ok := riskyPrograms[path.Base(words[i+1])]; ok {
+						addReason(reason)
+					}
+				}
+			}
+		}
+		switch {
+		case program == "git" && len(words) > 1:
+			if gitDiscardsChanges(words[1:]) {
+				addReason("discards git changes or history")
+			}
+			if !readOnlyGitCommands[words[1]] {
+				readOnly = false
+			}
+		case !readOnlyPrograms[program]:
+			readOnly = false
+		}
+	}
+	for _, pipe := range []string{"| sh", "|sh", "| bash", "|bash", "| zsh", "|zsh"} {
+		if strings.Contains(command, pipe) {
+			addReason("runs a piped script")
+		}
+	}
+
+	switch {
+	case len(reasons) > 0:
+		return tools.RiskHigh, strings.Join(reasons, ", ")
+	case readOnly:
+		return tools.RiskLow, "only reads"
+	}
+	return tools.RiskMedium, ""
+}
+
+// wrappedCommand returns the words of the command the wrapper runs, skipping its options and their arguments,
+// variable assignments and numbers like durations, or nil if it doesn't run any.
+func wrappedCommand(words []string, options []string) []string {
+	for i := 1; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case strings.HasPrefix(word, "-"):
+			for _, option := range options {
+				if word == option {
+					i++
+					break
+				}
+			}
+		case strings.Contains(word, "="), word[0] >= '0' && word[0] <= '9':
+		default:
+			return words[i:]
+		}
+	}
+	return nil
+}
+
+func gitDiscardsChanges(args []string) bool {
+	has := func(flags ...string) bool {
+		for _, arg := range args[1:] {
+			for _, flag := range flags {
+				if arg == flag || strings.HasPrefix(arg, flag+"=") {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	switch args[0] {
+	case "push":
+		return has("--force", "-f", "--force-with-lease")
+	case "reset":
+		return has("--hard")
+	case "clean":
+		return true
+	case "checkout":
+		return has("--", ".")
+	}
+	return false
+}
+
+// simpleCommands splits the command on shell operators, returning the words of each part, with leading variable assignments skipped.
+func simpleCommands(command string) [][]string {
+	parts := strings.FieldsFunc(command, func(r rune) bool {
+		return strings.ContainsRune(";&|()`\n", r)
+	})
+	var out [][]string
+	for _, part := range parts {
+		words := strings.Fields(strings.ReplaceAll(part, "$", " "))
+		for len(words) > 0 && strings.Contains(words[0], "=") {
+			words = words[1:]
+		}
+		if len(words) > 0 {
+			out = append(out, words)
+		}
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package terminal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"cuttlefish/database"
+	"cuttlefish/tools"
+	"cuttlefish/tools/process"
+	"cuttlefish/tools/sandbox"
+)
+
+// shell is a long-lived bash process, so that the working directory, environment variables
+// and activated virtualenvs carry over from one command to the next.
+type shell struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	// Printed after each command, followed by its exit code and the working directory.
+	marker string
+	output *process.MarkedOutput
+	// Holds the files commands are sourced from, and is mounted read-only if the shell is sandboxed.
+	commandDir string
+}
+
+func startShell(dir string, sandboxSettings database.SandboxSettings, runtime tools.AppRuntime) (*shell, error) {
+	marker, err := process.NewMarker()
+	if err != nil {
+		return nil, err
+	}
+	commandDir, err := os.MkdirTemp("", "cuttlefish-shell-*")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create command directory: %w", err)
+	}
+
+	cmd := exec.Command("bash", "--noprofile", "--norc")
+	cmd.Dir = dir
+	cmd, err = sandbox.Command(cmd, sandboxSettings, runtime, commandDir)
+	if err != nil {
+		os.RemoveAll(commandDir)
+		return nil, err
+	}
+	process.SetProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		os.RemoveAll(commandDir)
+		return nil, fmt.Errorf("couldn't create shell stdin: %w", err)
+	}
+	outputReader, outputWriter, err := os.Pipe()
+	if err != nil {
+		os.RemoveAll(commandDir)
+		return nil, fmt.Errorf("couldn't create shell output pipe: %w", err)
+	}
+	cmd.Stdout = outputWriter
+	cmd.Stderr = outputWriter
+	if err := cmd.Start(); err != nil {
+		outputReader.Close()
+		outputWriter.Close()
+		os.RemoveAll(commandDir)
+		return nil, fmt.Errorf("couldn't start shell: %w", err)
+	}
+	// The shell and its children hold their own copies now.
+	outputWriter.Close()
+	go cmd.Wait()
+
+	return &shell{
+		cmd:        cmd,
+		stdin:      stdin,
+		marker:     marker,
+		output:     process.NewMarkedOutput(outputReader, marker),
+		commandDir: commandDir,
+	}, nil
+}
+
+type commandResult struct {
+	output   string
+	exitCode int
+	dir      string
+}
+
+// run executes the script in the shell itself, rather than a subshell, so its side effects persist.
+// The script is sourced from a file, so that unbalanced quotes can't leave the shell waiting for more input,
+// and stdin is redirected, so that commands reading it don't swallow the marker.
+// The output is written to w as well, line by line, while the script runs.
+func (s *shell) run(ctx context.Context, script string, w io.Writer) (*commandResult, error) {
+	f, err := os.CreateTemp(s.commandDir, "command-*.sh")
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create command file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(script); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("couldn't write command file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("couldn't write command file: %w", err)
+	}
+
+	line := fmt.Sprintf(". %s < /dev/null\nprintf '\\n%s %%d %%s\\n' \"$?\" \"$PWD\"\n", shellQuote(f.Name()), s.marker)
+	if _, err := io.WriteString(s.stdin, line); err != nil {
+		return nil, process.ErrExited
+	}
+
+	output, status, err := s.output.Wait(ctx, w)
+	if err != nil {
+		return &commandResult{output: output, exitCode: -1}, err
+	}
+	exitCodeAndDir := strings.SplitN(status, " ", 2)
+	exitCode, err := strconv.Atoi(exitCodeAndDir[0])
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse exit code: %w", err)
+	}
+	result := &commandResult{output: output, exitCode: exitCode}
+	if len(exitCodeAndDir) == 2 {
+		result.dir = exitCodeAndDir[1]
+	}
+	return result, nil
+}
+
+// close kills the shell along with anything it started.
+func (s *shell) close() error {
+	s.stdin.Close()
+	defer os.RemoveAll(s.commandDir)
+	return process.KillProcessGroup(s.cmd)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -1,13 +1,17 @@
 package terminal
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os/exec"
+	"os"
+	"reflect"
+	"sync"
 
 	"cuttlefish/database"
 	"cuttlefish/tools"
+	"cuttlefish/tools/process"
+	"cuttlefish/tools/sandbox"
 )
 
 type Tool struct {
@@ -21,22 +25,32 @@ func (t *Tool) Description() string {
 	return "run commands in a bash shell"
 }
 
-func (t *Tool) ArgumentDescriptions() map[string]string {
-	return map[string]string{
-		"command": "bash command to run",
-	}
+func (t *Tool) ArgumentSchema() *tools.Schema {
+	return tools.ObjectSchema(map[string]*tools.Schema{
+		"command": {Type: "string", Description: "bash command to run"},
+	}, "command")
 }
 
 func (t *Tool) Instantiate(ctx context.Context, settings database.Settings, runtime tools.AppRuntime) (tools.ToolInstance, error) {
+	// The shell is started by the first command, as it depends on the conversation's sandbox settings.
 	return &ToolInstance{
 		runtime:         runtime,
 		requireApproval: settings.Terminal.RequireApproval,
 	}, nil
 }
 
+// ToolInstance runs all commands in the same shell, which lives as long as the instance.
+// If the shell has to be restarted, i.e. because a command got cancelled, the working directory is kept but the environment is lost.
 type ToolInstance struct {
 	runtime         tools.AppRuntime
 	requireApproval bool
+
+	m     sync.Mutex
+	shell *shell
+	// The shell's last known working directory.
+	dir string
+	// The sandbox settings the shell was started with.
+	sandbox database.SandboxSettings
 }
 
 func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*tools.RunResult, error) {
@@ -45,29 +59,125 @@ func (t *ToolInstance) Run(ctx context.Context, args map[string]interface{}) (*t
 		return nil, fmt.Errorf("command is not a string")
 	}
 	if t.requireApproval {
-		if err := t.runtime.WaitForApproval(ctx, "run terminal command"); err != nil {
+		risk, riskReason := commandRisk(command)
+		approval, err := t.runtime.WaitForApproval(ctx, tools.ApprovalRequest{
+			Message:          "run terminal command",
+			WorkingDirectory: t.workingDirectory(),
+			Risk:             risk,
+			RiskReason:       riskReason,
+			Preview:          command,
+			PreviewType:      tools.PreviewTypeShell,
+		})
+		if err != nil {
 			return nil, fmt.Errorf("user did not approve: %w", err)
 		}
+		if err := approval.Rejected(); err != nil {
+			return nil, err
+		}
+		if approval.Args != nil {
+			return t.Run(ctx, approval.Args)
+		}
 	}
-	cmd := exec.CommandContext(ctx, "bash", "-c", command)
-	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
-	err := cmd.Run()
-	var result string
-	if err == context.Canceled {
+
+	t.m.Lock()
+	defer t.m.Unlock()
+	if err := t.ensureShell(); err != nil {
 		return nil, err
+	}
+
+	runCtx, cancel := sandbox.WithTimeout(ctx, t.sandbox)
+	defer cancel()
+	res, err := t.shell.run(runCtx, command, t.runtime.OutputWriter(ctx))
+	if errors.Is(err, process.ErrExited) {
+		// I.e. the command ran `exit`. The next command gets a fresh shell.
+		t.closeShell()
+		output := ""
+		if res != nil {
+			output = res.output
+		}
+		return &tools.RunResult{
+			Result: "the shell exited while running `" + command + "`, it will be restarted for the next command",
+			Output: output + "\n",
+		}, nil
 	} else if err != nil {
-		result = err.Error()
+		// There's no telling what state the shell is in, so it's replaced along with whatever the command started.
+		t.closeShell()
+		if sandbox.TimedOut(ctx, err) {
+			return nil, fmt.Errorf("`%s` didn't finish within the sandbox's time limit of %s, so it was stopped, and the shell restarted", command, sandbox.Timeout(t.sandbox))
+		}
+		return nil, err
+	}
+	if res.dir != "" {
+		t.dir = res.dir
+	}
+
+	var result string
+	if res.exitCode != 0 {
+		result = fmt.Sprintf("`%s` exited with code %d", command, res.exitCode)
 	} else {
 		result = "successfully executed `" + command + "`"
 	}
 	return &tools.RunResult{
-		Result: result,
-		Output: buf.String() + "\n",
+		Result:   result,
+		Output:   res.output + "\n",
+		ExitCode: &res.exitCode,
 	}, nil
 }
 
+// ensureShell starts a shell if there's none, or if the conversation's sandbox settings changed since the shell was started,
+// in which case the working directory is reset as well, as it may not exist in the new sandbox.
+func (t *ToolInstance) ensureShell() error {
+	sandboxSettings, err := t.runtime.Sandbox()
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(sandboxSettings, t.sandbox) {
+		t.closeShell()
+		t.dir = ""
+		t.sandbox = sandboxSettings
+	}
+	if t.shell != nil {
+		return nil
+	}
+	sh, err := startShell(t.dir, t.sandbox, t.runtime)
+	if err != nil {
+		return err
+	}
+	t.shell = sh
+	return nil
+}
+
+func (t *ToolInstance) closeShell() error {
+	if t.shell == nil {
+		return nil
+	}
+	err := t.shell.close()
+	t.shell = nil
+	return err
+}
+
+// workingDirectory returns the directory the next command will run in, which is the app's own,
+// or the conversation's workspace if the shell is sandboxed, until the shell changes it.
+func (t *ToolInstance) workingDirectory() string {
+	t.m.Lock()
+	dir := t.dir
+	t.m.Unlock()
+	if dir != "" {
+		return dir
+	}
+	if sandboxSettings, err := t.runtime.Sandbox(); err == nil && sandboxSettings.Enabled {
+		dir, _ = t.runtime.Workspace()
+	} else {
+		dir, _ = os.Getwd()
+	}
+	return dir
+}
+
 func (t *ToolInstance) Shutdown() error {
+	t.m.Lock()
+	defer t.m.Unlock()
+	if err := t.closeShell(); err != nil {
+		return fmt.Errorf("couldn't stop shell: %w", err)
+	}
 	return nil
 }
@@ -2,18 +2,105 @@ package tools
 
 import (
 	"context"
+	"io"
 
 	"cuttlefish/database"
 )
 
 type AppRuntime interface {
-	WaitForApproval(ctx context.Context, message string) error
+	// WaitForApproval asks the user to approve what the tool is about to do, and returns their decision.
+	// It only returns an error if the context is done before they decide.
+	// The tool call's ID, name and arguments are added to the request by the app.
+	WaitForApproval(ctx context.Context, request ApprovalRequest) (Approval, error)
+	// RunSubConversation lets the Assistant delegate a task to a new conversation, and returns its final answer.
+	RunSubConversation(ctx context.Context, subConversation SubConversation) (string, error)
+	// Workspace returns the conversation's workspace directory, creating it if it doesn't exist yet.
+	Workspace() (string, error)
+	// ConversationID lets tool instances of the same conversation share state, like a connection to an MCP server.
+	ConversationID() int
+	// OutputWriter returns a writer for the output of the tool call running with the given context,
+	// which is shown in the conversation while the tool runs. Once it finishes, its RunResult replaces whatever was written.
+	OutputWriter(ctx context.Context) io.Writer
+	// Sandbox returns the conversation's sandbox settings, which the terminal and python tools start their processes with.
+	Sandbox() (database.SandboxSettings, error)
+}
+
+// ApprovalRequest describes what a tool is about to do, so that the user knows exactly what they're approving.
+type ApprovalRequest struct {
+	// Message summarizes the request, i.e. "run terminal command".
+	Message string
+	// WorkingDirectory is where the tool call will run, for tools where that matters.
+	WorkingDirectory string
+	Risk             Risk
+	// RiskReason explains the risk, i.e. "deletes files".
+	RiskReason string
+	// Preview shows what exactly is going to happen, like the command, the diff of a file, or the SQL statement.
+	Preview string
+	// PreviewType says how to show the preview, one of the PreviewType constants, or empty for plain text.
+	PreviewType string
+}
+
+// Risk is a rough hint about how much damage the tool call could do, it isn't meant to replace the user's judgement.
+type Risk string
+
+const (
+	RiskLow    Risk = "low"
+	RiskMedium Risk = "medium"
+	RiskHigh   Risk = "high"
+)
+
+const (
+	PreviewTypeShell = "shell"
+	PreviewTypeDiff  = "diff"
+	PreviewTypeSQL   = "sql"
+	PreviewTypeJSON  = "json"
+	PreviewTypeURL   = "url"
+)
+
+// Approval is the user's decision about an approval request.
+type Approval struct {
+	Approved bool
+	// Reason the user gave for rejecting the request, which may be empty.
+	Reason string
+	// Args are the tool call's arguments as the user edited them before approving, already validated against the tool's schema.
+	// They're nil if the user approved the arguments as they were.
+	// Tools should run again with the edited arguments, which won't ask for approval a second time.
+	Args map[string]interface{}
+}
+
+// Rejected returns the error for the tool to fail with if the user rejected the request.
+func (a Approval) Rejected() error {
+	if a.Approved {
+		return nil
+	}
+	return &RejectedError{Reason: a.Reason}
+}
+
+// RejectedError means the user rejected the tool call, which isn't the model's mistake, so it's reported differently than other errors.
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	if e.Reason == "" {
+		return "the user rejected it"
+	}
+	return "the user rejected it: " + e.Reason
+}
+
+type SubConversation struct {
+	Task string
+	// Instructions are added to the system prompt.
+	Instructions string
+	// Tools enabled in the sub-conversation, which have to be enabled in the parent conversation as well.
+	Tools []string
 }
 
 type Tool interface {
 	Name() string
 	Description() string
-	ArgumentDescriptions() map[string]string
+	// ArgumentSchema describes the arguments object, which is validated against it before the tool is run.
+	ArgumentSchema() *Schema
 	Instantiate(ctx context.Context, settings database.Settings, runtime AppRuntime) (ToolInstance, error)
 }
 
@@ -22,11 +109,10 @@ type ToolInstance interface {
 	Shutdown() error
 }
 
-// TODO: In the future we might want Tools to be able to dynamically append to the message.
-//       So basically kind of a result output stream.
-
 type RunResult struct {
 	Result          string
 	CustomResultTag string
 	Output          string
+	// ExitCode is set by tools running a process, i.e. the terminal, for the log of tool executions.
+	ExitCode *int
 }
@@ -0,0 +1,15 @@
+package tools
+
+import "unicode/utf8"
+
+// Truncate returns s cut down to at most n bytes, backing off to the start of a rune split by the limit,
+// so that the result stays valid UTF-8.
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
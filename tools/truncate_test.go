@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{s: "hello", n: 10, want: "hello"},
+		{s: "hello", n: 5, want: "hello"},
+		{s: "hello", n: 3, want: "hel"},
+		{s: "hello", n: 0, want: ""},
+		{s: "héllo", n: 2, want: "h"},
+		{s: "héllo", n: 3, want: "hé"},
+		{s: "日本語", n: 4, want: "日"},
+		{s: "日本語", n: 2, want: ""},
+		{s: "a😀b", n: 4, want: "a"},
+	}
+	for _, tt := range tests {
+		got := Truncate(tt.s, tt.n)
+		if got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("Truncate(%q, %d) = %q, which isn't valid UTF-8", tt.s, tt.n, got)
+		}
+	}
+}